@@ -0,0 +1,250 @@
+// Package backtest runs strategies over historical klines or trades using
+// the paper exchange to simulate the fills.
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/paper"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// fee tiers of the regular accounts.
+var (
+	SpotFees   = paper.Fees{Maker: 0.001, Taker: 0.001}
+	LinearFees = paper.Fees{Maker: 0.0002, Taker: 0.00055}
+)
+
+// Bar candle of Symbol fed to the strategy
+type Bar struct {
+	Symbol string
+	bybitHttp.Candle
+}
+
+// Strategy is called once per bar, at its close, with the exchange used to
+// place orders, the same interface implemented by http.Client.
+type Strategy interface {
+	OnBar(ctx context.Context, exchange bybitHttp.Exchange, bar Bar) error
+}
+
+// StrategyFunc adapts a function to a Strategy
+type StrategyFunc func(ctx context.Context, exchange bybitHttp.Exchange, bar Bar) error
+
+// OnBar calls f
+func (f StrategyFunc) OnBar(ctx context.Context, exchange bybitHttp.Exchange, bar Bar) error {
+	return f(ctx, exchange, bar)
+}
+
+// Backtest simulates the fills of the orders of a strategy bar by bar.
+// Resting limit orders are filled at their price as maker when the range
+// of a bar reaches them, orders placed at the close of a bar are matched
+// against the close price moved by the slippage.
+type Backtest struct {
+	quoteCoin string
+	fees      paper.Fees
+	leverage  float64
+	slippage  float64
+	balances  map[string]float64
+}
+
+// New creates a backtest with the regular spot fees and without balances,
+// opts allow to customize it.
+func New(opts ...Option) *Backtest {
+	b := &Backtest{
+		quoteCoin: "USDT",
+		leverage:  paper.DefaultLeverage,
+		fees:      SpotFees,
+		balances:  make(map[string]float64),
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Run feeds bars of a single symbol and interval, sorted by start time, to
+// strategy and reports the results. interval is a bybit interval or a
+// duration like 10s of the bars of TradeCandleBars, the orders and fills of a bar are stamped at its close and
+// the Sharpe ratio is annualized with it. Use an empty interval for the
+// bars of TradeBars, closed at their start and not annualized.
+func (b *Backtest) Run(ctx context.Context, strategy Strategy, interval string, bars []Bar) (*Report, error) {
+	if len(bars) == 0 {
+		return nil, ErrorNoBars
+	}
+
+	periods, err := periodsPerYear(interval, bars[0].Start)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := bars[0].Symbol
+	base := strings.TrimSuffix(symbol, b.quoteCoin)
+
+	var now time.Time
+	report := &Report{Symbol: symbol}
+
+	dispatcher := bybitWs.NewDispatcher()
+	err = dispatcher.Handle(bybitWs.ExecutionTopic, bybitWs.Func(func(_ context.Context, msg bybitWs.ExecutionMessage) error {
+		for _, execution := range msg.Data {
+			fill, err := newFill(execution)
+			if err != nil {
+				return err
+			}
+
+			report.Fills = append(report.Fills, fill)
+		}
+
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []paper.Option{
+		paper.WithFees(b.fees),
+		paper.WithLeverage(b.leverage),
+		paper.WithDispatcher(dispatcher),
+		paper.WithQuoteCoins(b.quoteCoin),
+		paper.WithClock(func() time.Time { return now }),
+	}
+	for coin, amount := range b.balances {
+		opts = append(opts, paper.WithBalance(coin, amount))
+	}
+	exchange := paper.New(opts...)
+
+	for i, bar := range bars {
+		if bar.Symbol != symbol {
+			return nil, fmt.Errorf("%w: %s and %s", ErrorMixedSymbols, symbol, bar.Symbol)
+		}
+
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		now, err = barClose(interval, bar)
+		if err != nil {
+			return nil, err
+		}
+
+		// the bars are traded in spot and linear, a book crossed by the range
+		// of the bar fills the resting orders at their price.
+		for _, category := range []string{bybitHttp.SpotCategory, bybitHttp.LinearCategory} {
+			exchange.SetOrderBook(category, symbol,
+				[]bybitWs.PriceLevel{{Price: bar.High, Size: math.MaxFloat64}},
+				[]bybitWs.PriceLevel{{Price: bar.Low, Size: math.MaxFloat64}},
+			)
+			exchange.SetOrderBook(category, symbol,
+				[]bybitWs.PriceLevel{{Price: bar.Close * (1 - b.slippage), Size: math.MaxFloat64}},
+				[]bybitWs.PriceLevel{{Price: bar.Close * (1 + b.slippage), Size: math.MaxFloat64}},
+			)
+		}
+
+		if i == 0 {
+			report.InitialEquity, err = b.equity(exchange, base, bar)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = strategy.OnBar(ctx, exchange, bar)
+		if err != nil {
+			return nil, fmt.Errorf("bar %s: %w", bar.Start.Format(time.RFC3339), err)
+		}
+
+		equity, err := b.equity(exchange, base, bar)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Equity = append(report.Equity, EquityPoint{Time: bar.Start, Equity: equity})
+	}
+
+	report.compute(periods)
+
+	return report, nil
+}
+
+// equity value in the quote coin of the balances of the base and quote
+// coins and of the unrealised pnl of the position at the close of bar.
+func (b *Backtest) equity(exchange *paper.Exchange, base string, bar Bar) (float64, error) {
+	quoteFree, quoteLocked := exchange.Balance(b.quoteCoin)
+	baseFree, baseLocked := exchange.Balance(base)
+	equity := quoteFree + quoteLocked + (baseFree+baseLocked)*bar.Close
+
+	positions, err := exchange.GetPositionInfo(bybitHttp.PositionParams{Symbol: bar.Symbol})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, position := range positions {
+		size, err := strconv.ParseFloat(position.Size, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		avgPrice, err := strconv.ParseFloat(position.AvgPrice, 64)
+		if err != nil {
+			return 0, err
+		}
+
+		if position.Side == bybitHttp.SellDirection {
+			size = -size
+		}
+
+		equity += (bar.Close - avgPrice) * size
+	}
+
+	return equity, nil
+}
+
+// barClose time of the close of bar, its start without interval.
+func barClose(interval string, bar Bar) (time.Time, error) {
+	if interval == "" {
+		return bar.Start, nil
+	}
+
+	return intervalEnd(interval, bar.Start)
+}
+
+// periodsPerYear number of bars of interval in a year, 1 without interval.
+func periodsPerYear(interval string, start time.Time) (float64, error) {
+	if interval == "" {
+		return 1, nil
+	}
+
+	end, err := intervalEnd(interval, start)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(365*24*time.Hour) / float64(end.Sub(start)), nil
+}
+
+// intervalEnd end of the bar of interval started at start, interval is a
+// bybit interval or a duration like 10s.
+func intervalEnd(interval string, start time.Time) (time.Time, error) {
+	if interval == bybitHttp.IntervalMonth {
+		return start.AddDate(0, 1, 0), nil
+	}
+
+	d, err := bybitHttp.IntervalDuration(interval)
+	if err == nil {
+		return start.Add(d), nil
+	}
+
+	d, err = time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("%w: %q", bybitHttp.ErrorInvalidInterval, interval)
+	}
+
+	return start.Add(d), nil
+}
@@ -0,0 +1,234 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+func TestReportCompute(t *testing.T) {
+	tests := []struct {
+		name     string
+		equity   []float64
+		ret      float64
+		drawdown float64
+		sharpe   float64
+	}{
+		{name: "flat", equity: []float64{100, 100, 100}},
+		{name: "rising", equity: []float64{110, 121, 133.1}, ret: 0.331},
+		// returns of 0.1, -0.1 and 0.2222, a Sharpe of 0.4554 per bar.
+		{name: "drawdown", equity: []float64{110, 99, 121}, ret: 0.21, drawdown: 0.1, sharpe: 0.4554 * math.Sqrt(365)},
+		{name: "losing", equity: []float64{80, 60, 90}, ret: -0.1, drawdown: 0.4, sharpe: 0.03975 * math.Sqrt(365)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &Report{InitialEquity: 100}
+			for i, equity := range tt.equity {
+				report.Equity = append(report.Equity, EquityPoint{Time: time.Unix(int64(i), 0), Equity: equity})
+			}
+
+			report.compute(365)
+
+			if math.Abs(report.Return-tt.ret) > 1e-9 || math.Abs(report.MaxDrawdown-tt.drawdown) > 1e-9 {
+				t.Fatalf("expected return %v drawdown %v got %v %v", tt.ret, tt.drawdown, report.Return, report.MaxDrawdown)
+			}
+
+			if math.Abs(report.Sharpe-tt.sharpe) > 1e-2 {
+				t.Fatalf("expected sharpe %v got %v", tt.sharpe, report.Sharpe)
+			}
+		})
+	}
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval string
+		expected float64
+	}{
+		{interval: "", expected: 1},
+		{interval: "60", expected: 365 * 24},
+		{interval: "D", expected: 365},
+		{interval: "W", expected: 365.0 / 7},
+		{interval: "10s", expected: 365 * 24 * 360},
+	}
+
+	for _, tt := range tests {
+		periods, err := periodsPerYear(tt.interval, start)
+		if err != nil {
+			t.Fatalf("interval %q: %v", tt.interval, err)
+		}
+
+		if math.Abs(periods-tt.expected) > 1e-9 {
+			t.Fatalf("interval %q expected %v got %v", tt.interval, tt.expected, periods)
+		}
+	}
+
+	_, err := periodsPerYear("7", start)
+	if err == nil {
+		t.Fatal("expected an invalid interval error")
+	}
+}
+
+func hourBars(start time.Time, closes ...float64) []Bar {
+	bars := make([]Bar, 0, len(closes))
+	for i, price := range closes {
+		bars = append(bars, Bar{
+			Symbol: "BTCUSDT",
+			Candle: bybitHttp.Candle{
+				Start: start.Add(time.Duration(i) * time.Hour),
+				Open:  price,
+				High:  price,
+				Low:   price,
+				Close: price,
+			},
+		})
+	}
+
+	return bars
+}
+
+func TestRunStampsFillsAtClose(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	bars := hourBars(start, 100, 110, 121)
+
+	strategy := StrategyFunc(func(_ context.Context, exchange bybitHttp.Exchange, bar Bar) error {
+		if !bar.Start.Equal(start) {
+			return nil
+		}
+
+		_, err := exchange.PlaceOrder(bybitHttp.OrderRequest{
+			Category:  bybitHttp.SpotCategory,
+			Symbol:    bar.Symbol,
+			Side:      bybitHttp.BuyDirection,
+			OrderType: bybitHttp.MarketOrder,
+			Qty:       "1",
+		})
+
+		return err
+	})
+
+	backtest := New(WithBalance("USDT", 100), WithFees(SpotFees))
+	report, err := backtest.Run(context.Background(), strategy, "60", bars)
+	if err != nil {
+		t.Fatalf("running: %v", err)
+	}
+
+	if len(report.Fills) != 1 || !report.Fills[0].Time.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected a fill at the close of the first bar got %+v", report.Fills)
+	}
+
+	// 0.999 BTC valued at the last close.
+	if math.Abs(report.FinalEquity-0.999*121) > 1e-9 || report.InitialEquity != 100 {
+		t.Fatalf("unexpected equity %v to %v", report.InitialEquity, report.FinalEquity)
+	}
+}
+
+// hourlySource serves the hourly klines between from and to, newest first
+type hourlySource struct {
+	from, to time.Time
+	requests int
+}
+
+func (s *hourlySource) GetKline(params bybitHttp.KlineParams) ([]bybitHttp.Kline, error) {
+	s.requests++
+
+	klines := make([]bybitHttp.Kline, 0)
+	for start := s.to; !start.Before(s.from) && len(klines) < params.Limit; start = start.Add(-time.Hour) {
+		ms := start.UnixMilli()
+		if ms < int64(params.Start) || ms > int64(params.End) {
+			continue
+		}
+
+		klines = append(klines, bybitHttp.Kline{strconv.FormatInt(ms, 10), "1", "1", "1", "1", "1", "1"})
+	}
+
+	return klines, nil
+}
+
+func TestFetchBarsPages(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &hourlySource{from: from, to: from.Add(9 * time.Hour)}
+
+	bars, err := FetchBars(context.Background(), source, bybitHttp.KlineParams{
+		Category: "spot",
+		Symbol:   "BTCUSDT",
+		Interval: "60",
+		Start:    int(from.UnixMilli()),
+		End:      int(from.Add(7 * time.Hour).UnixMilli()),
+		Limit:    3,
+	})
+	if err != nil {
+		t.Fatalf("fetching: %v", err)
+	}
+
+	if len(bars) != 8 || source.requests != 3 {
+		t.Fatalf("expected 8 bars in 3 pages got %d in %d", len(bars), source.requests)
+	}
+
+	for i, bar := range bars {
+		if !bar.Start.Equal(from.Add(time.Duration(i) * time.Hour)) {
+			t.Fatalf("unexpected start of bar %d %s", i, bar.Start)
+		}
+	}
+}
+
+func TestFetchBarsDropsOpenKline(t *testing.T) {
+	open := time.Now().UTC().Truncate(time.Hour)
+	source := &hourlySource{from: open.Add(-5 * time.Hour), to: open}
+
+	bars, err := FetchBars(context.Background(), source, bybitHttp.KlineParams{
+		Category: "spot",
+		Symbol:   "BTCUSDT",
+		Interval: "60",
+		End:      int(open.UnixMilli()),
+		Limit:    3,
+	})
+	if err != nil {
+		t.Fatalf("fetching: %v", err)
+	}
+
+	// the latest page without the kline started at the current hour.
+	if len(bars) != 2 || !bars[1].Start.Equal(open.Add(-time.Hour)) {
+		t.Fatalf("expected the 2 closed bars of the page got %+v", bars)
+	}
+}
+
+func TestTradeCandleBars(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := []Trade{
+		{Time: start, Symbol: "BTCUSDT", Price: 100, Size: 1},
+		{Time: start.Add(3 * time.Second), Symbol: "ETHUSDT", Price: 10, Size: 1},
+		{Time: start.Add(4 * time.Second), Symbol: "BTCUSDT", Price: 105, Size: 2},
+		{Time: start.Add(12 * time.Second), Symbol: "BTCUSDT", Price: 99, Size: 1},
+	}
+
+	bars, err := TradeCandleBars("BTCUSDT", trades, "10s")
+	if err != nil {
+		t.Fatalf("building bars: %v", err)
+	}
+
+	if len(bars) != 2 {
+		t.Fatalf("expected 2 bars got %+v", bars)
+	}
+
+	first := bars[0]
+	if first.Symbol != "BTCUSDT" || !first.Start.Equal(start) || first.Open != 100 || first.Close != 105 || first.Volume != 3 {
+		t.Fatalf("unexpected first bar %+v", first)
+	}
+
+	if !bars[1].Start.Equal(start.Add(10*time.Second)) || bars[1].Close != 99 {
+		t.Fatalf("unexpected second bar %+v", bars[1])
+	}
+
+	_, err = TradeCandleBars("BTCUSDT", trades, "0s")
+	if !errors.Is(err, bybitHttp.ErrorInvalidInterval) {
+		t.Fatalf("expected ErrorInvalidInterval got %v", err)
+	}
+}
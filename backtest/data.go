@@ -0,0 +1,304 @@
+package backtest
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Gealber/bybit/history"
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+// KlineSource retrieves a page of klines, implemented by http.Client
+type KlineSource interface {
+	GetKline(queryParams bybitHttp.KlineParams) ([]bybitHttp.Kline, error)
+}
+
+// FetchBars retrieves the closed klines of params sorted by start time.
+// With Start the klines started between Start and End, both in
+// milliseconds, are retrieved in pages of Limit klines from the newest, End
+// defaults to now. Without Start only the latest page is retrieved.
+func FetchBars(ctx context.Context, source KlineSource, params bybitHttp.KlineParams) ([]Bar, error) {
+	now := time.Now()
+	if params.Start == 0 {
+		klines, err := source.GetKline(params)
+		if err != nil {
+			return nil, err
+		}
+
+		bars, err := KlineBars(params.Symbol, klines)
+		if err != nil {
+			return nil, err
+		}
+
+		return closedBars(params.Interval, bars, now)
+	}
+
+	page := params
+	if page.End == 0 {
+		page.End = int(now.UnixMilli())
+	}
+
+	seen := make(map[int64]struct{})
+	bars := make([]Bar, 0)
+	for page.End >= params.Start {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		klines, err := source.GetKline(page)
+		if err != nil {
+			return nil, err
+		}
+
+		pageBars, err := KlineBars(params.Symbol, klines)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pageBars) == 0 {
+			break
+		}
+
+		for _, bar := range pageBars {
+			start := bar.Start.UnixMilli()
+			if _, ok := seen[start]; ok || start < int64(params.Start) {
+				continue
+			}
+
+			seen[start] = struct{}{}
+			bars = append(bars, bar)
+		}
+
+		// the next page ends before the oldest kline, stop when it doesn't move.
+		oldest := int(pageBars[0].Start.UnixMilli())
+		if oldest > page.End {
+			break
+		}
+		page.End = oldest - 1
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool { return bars[i].Start.Before(bars[j].Start) })
+
+	return closedBars(params.Interval, bars, now)
+}
+
+// closedBars drops the bars of interval not closed at now, bybit returns
+// the current kline too.
+func closedBars(interval string, bars []Bar, now time.Time) ([]Bar, error) {
+	for len(bars) > 0 {
+		end, err := barClose(interval, bars[len(bars)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		if !end.After(now) {
+			break
+		}
+
+		bars = bars[:len(bars)-1]
+	}
+
+	return bars, nil
+}
+
+// KlineBars parses klines of symbol into bars sorted by start time
+func KlineBars(symbol string, klines []bybitHttp.Kline) ([]Bar, error) {
+	bars := make([]Bar, 0, len(klines))
+	for _, kline := range klines {
+		candle, err := kline.Candle()
+		if err != nil {
+			return nil, err
+		}
+
+		bars = append(bars, Bar{Symbol: symbol, Candle: candle})
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool { return bars[i].Start.Before(bars[j].Start) })
+
+	return bars, nil
+}
+
+// Trade public trade of the archives in public.bybit.com
+type Trade struct {
+	Time   time.Time
+	Symbol string
+	Side   string
+	Price  float64
+	Size   float64
+}
+
+// LoadTrades reads a trade archive downloaded from public.bybit.com, plain
+// or gzipped CSV. Both the derivatives archives, with timestamps in seconds,
+// and the spot ones, with timestamps in milliseconds and without symbol,
+// are supported. Trades are returned sorted by time.
+func LoadTrades(path string) ([]Trade, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		reader = gz
+	}
+
+	return readTrades(csv.NewReader(reader))
+}
+
+func readTrades(reader *csv.Reader) ([]Trade, error) {
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorInvalidTrades, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	// spot archives name the size volume.
+	if _, ok := columns["size"]; !ok {
+		if i, ok := columns["volume"]; ok {
+			columns["size"] = i
+		}
+	}
+
+	for _, name := range []string{"timestamp", "price", "size"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrorInvalidTrades, name)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+
+		return record[i]
+	}
+
+	trades := make([]Trade, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrorInvalidTrades, err)
+		}
+
+		timestamp, err := strconv.ParseFloat(field(record, "timestamp"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: timestamp %q", ErrorInvalidTrades, field(record, "timestamp"))
+		}
+
+		price, err := strconv.ParseFloat(field(record, "price"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: price %q", ErrorInvalidTrades, field(record, "price"))
+		}
+
+		size, err := strconv.ParseFloat(field(record, "size"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: size %q", ErrorInvalidTrades, field(record, "size"))
+		}
+
+		trades = append(trades, Trade{
+			Time:   parseTimestamp(timestamp),
+			Symbol: field(record, "symbol"),
+			Side:   normalizeSide(field(record, "side")),
+			Price:  price,
+			Size:   size,
+		})
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Time.Before(trades[j].Time) })
+
+	return trades, nil
+}
+
+// TradeBars converts every trade of symbol into a bar, the strategy is fed
+// tick by tick.
+func TradeBars(symbol string, trades []Trade) []Bar {
+	bars := make([]Bar, 0, len(trades))
+	for _, trade := range trades {
+		if trade.Symbol != "" && trade.Symbol != symbol {
+			continue
+		}
+
+		bars = append(bars, Bar{
+			Symbol: symbol,
+			Candle: bybitHttp.Candle{
+				Start:    trade.Time,
+				Open:     trade.Price,
+				High:     trade.Price,
+				Low:      trade.Price,
+				Close:    trade.Price,
+				Volume:   trade.Size,
+				Turnover: trade.Price * trade.Size,
+			},
+		})
+	}
+
+	return bars
+}
+
+// TradeCandleBars aggregates the trades of symbol into bars of interval,
+// any interval accepted by history.NewBuilder like "5" or "10s".
+func TradeCandleBars(symbol string, trades []Trade, interval string) ([]Bar, error) {
+	bars := make([]Bar, 0)
+	builder, err := history.NewBuilder(interval, func(key history.CandleKey, candle bybitHttp.Candle) {
+		bars = append(bars, Bar{Symbol: key.Symbol, Candle: candle})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, trade := range trades {
+		if trade.Symbol == "" || trade.Symbol == symbol {
+			builder.Add(history.CandleKey{Symbol: symbol}, trade.Time, trade.Price, trade.Size)
+		}
+	}
+	builder.Flush()
+
+	return bars, nil
+}
+
+// parseTimestamp of an archive, in seconds with decimals or in milliseconds.
+func parseTimestamp(timestamp float64) time.Time {
+	if timestamp < 1e11 {
+		return time.UnixMicro(int64(timestamp * 1e6)).UTC()
+	}
+
+	return time.UnixMilli(int64(timestamp)).UTC()
+}
+
+// normalizeSide spot archives use lowercase sides.
+func normalizeSide(side string) string {
+	switch strings.ToLower(side) {
+	case "buy":
+		return bybitHttp.BuyDirection
+	case "sell":
+		return bybitHttp.SellDirection
+	}
+
+	return side
+}
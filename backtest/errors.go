@@ -0,0 +1,9 @@
+package backtest
+
+import "errors"
+
+var (
+	ErrorNoBars        = errors.New("no bars to backtest")
+	ErrorInvalidTrades = errors.New("invalid trades file")
+	ErrorMixedSymbols  = errors.New("bars of several symbols")
+)
@@ -0,0 +1,44 @@
+package backtest
+
+import "github.com/Gealber/bybit/paper"
+
+// Option allows to customize the Backtest created with New
+type Option func(*Backtest)
+
+// WithBalance sets the initial balance of coin
+func WithBalance(coin string, amount float64) Option {
+	return func(b *Backtest) {
+		b.balances[coin] = amount
+	}
+}
+
+// WithFees sets the fee tier, by default SpotFees, e.g. LinearFees for
+// strategies trading perpetuals.
+func WithFees(fees paper.Fees) Option {
+	return func(b *Backtest) {
+		b.fees = fees
+	}
+}
+
+// WithLeverage sets the leverage of linear positions, by default
+// paper.DefaultLeverage.
+func WithLeverage(leverage float64) Option {
+	return func(b *Backtest) {
+		b.leverage = leverage
+	}
+}
+
+// WithSlippage sets the fraction of the close price paid by the orders
+// matched at the close of a bar, e.g. 0.0005 for 5 basis points.
+func WithSlippage(slippage float64) Option {
+	return func(b *Backtest) {
+		b.slippage = slippage
+	}
+}
+
+// WithQuoteCoin sets the coin the equity is valued in, by default USDT
+func WithQuoteCoin(coin string) Option {
+	return func(b *Backtest) {
+		b.quoteCoin = coin
+	}
+}
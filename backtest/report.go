@@ -0,0 +1,190 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// EquityPoint equity at the close of the bar started at Time
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+// Fill execution of an order of the strategy
+type Fill struct {
+	Time        time.Time `json:"time"`
+	OrderID     string    `json:"orderId"`
+	OrderLinkID string    `json:"orderLinkId"`
+	Side        string    `json:"side"`
+	Price       float64   `json:"price"`
+	Qty         float64   `json:"qty"`
+	Value       float64   `json:"value"`
+	// Fee in the base coin for spot buys, in the quote coin otherwise.
+	Fee     float64 `json:"fee"`
+	Pnl     float64 `json:"pnl"`
+	IsMaker bool    `json:"isMaker"`
+}
+
+// Report results of a backtest, Return and MaxDrawdown are fractions,
+// Sharpe is annualized without risk free rate and Turnover is the traded
+// value divided by the initial equity.
+type Report struct {
+	Symbol        string        `json:"symbol"`
+	InitialEquity float64       `json:"initialEquity"`
+	FinalEquity   float64       `json:"finalEquity"`
+	Return        float64       `json:"return"`
+	MaxDrawdown   float64       `json:"maxDrawdown"`
+	Sharpe        float64       `json:"sharpe"`
+	Turnover      float64       `json:"turnover"`
+	Equity        []EquityPoint `json:"equity"`
+	Fills         []Fill        `json:"fills"`
+}
+
+func newFill(execution bybitWs.ExecutionData) (Fill, error) {
+	fill := Fill{
+		OrderID:     execution.OrderID,
+		OrderLinkID: execution.OrderLinkID,
+		Side:        execution.Side,
+		IsMaker:     execution.IsMaker,
+	}
+
+	execTime, err := strconv.ParseInt(execution.ExecTime, 10, 64)
+	if err != nil {
+		return Fill{}, err
+	}
+	fill.Time = time.UnixMilli(execTime).UTC()
+
+	values := []struct {
+		dst *float64
+		raw string
+	}{
+		{&fill.Price, execution.ExecPrice},
+		{&fill.Qty, execution.ExecQty},
+		{&fill.Value, execution.ExecValue},
+		{&fill.Fee, execution.ExecFee},
+		{&fill.Pnl, execution.ExecPnl},
+	}
+	for _, v := range values {
+		*v.dst, err = strconv.ParseFloat(v.raw, 64)
+		if err != nil {
+			return Fill{}, err
+		}
+	}
+
+	return fill, nil
+}
+
+func (r *Report) compute(periodsPerYear float64) {
+	if len(r.Equity) == 0 {
+		return
+	}
+
+	r.FinalEquity = r.Equity[len(r.Equity)-1].Equity
+	if r.InitialEquity != 0 {
+		r.Return = r.FinalEquity/r.InitialEquity - 1
+
+		traded := 0.0
+		for _, fill := range r.Fills {
+			traded += fill.Value
+		}
+		r.Turnover = traded / r.InitialEquity
+	}
+
+	peak, previous := r.InitialEquity, r.InitialEquity
+	returns := make([]float64, 0, len(r.Equity))
+	for _, point := range r.Equity {
+		peak = math.Max(peak, point.Equity)
+		if peak > 0 {
+			r.MaxDrawdown = math.Max(r.MaxDrawdown, (peak-point.Equity)/peak)
+		}
+
+		if previous != 0 {
+			returns = append(returns, point.Equity/previous-1)
+		}
+		previous = point.Equity
+	}
+
+	r.Sharpe = sharpe(returns, periodsPerYear)
+}
+
+func sharpe(returns []float64, periodsPerYear float64) float64 {
+	if len(returns) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	// constant returns only differ by rounding errors.
+	std := math.Sqrt(variance / float64(len(returns)-1))
+	if std < 1e-12 {
+		return 0
+	}
+
+	return mean / std * math.Sqrt(periodsPerYear)
+}
+
+// WriteJSON writes the whole report as JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// WriteEquityCSV writes the equity curve as CSV, with the time in milliseconds
+func (r *Report) WriteEquityCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"time", "equity"})
+	for _, point := range r.Equity {
+		_ = writer.Write([]string{formatTime(point.Time), formatFloat(point.Equity)})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// WriteFillsCSV writes the list of fills as CSV, with the time in milliseconds
+func (r *Report) WriteFillsCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"time", "orderId", "orderLinkId", "side", "price", "qty", "value", "fee", "pnl", "isMaker"})
+	for _, fill := range r.Fills {
+		_ = writer.Write([]string{
+			formatTime(fill.Time),
+			fill.OrderID,
+			fill.OrderLinkID,
+			fill.Side,
+			formatFloat(fill.Price),
+			formatFloat(fill.Qty),
+			formatFloat(fill.Value),
+			formatFloat(fill.Fee),
+			formatFloat(fill.Pnl),
+			strconv.FormatBool(fill.IsMaker),
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
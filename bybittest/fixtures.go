@@ -0,0 +1,118 @@
+package bybittest
+
+import (
+	"strconv"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/google/uuid"
+)
+
+// defaultFixtures results of every endpoint implemented by the http client,
+// order operations echo the orders of the request.
+func defaultFixtures() map[string]Fixture {
+	empty := func(*Request) any { return struct{}{} }
+	emptyList := func(*Request) any { return map[string]any{"list": []any{}} }
+
+	return map[string]Fixture{
+		"order/create":       orderFixture,
+		"order/amend":        orderFixture,
+		"order/cancel":       orderFixture,
+		"order/create-batch": batchFixture,
+		"order/amend-batch":  batchFixture,
+		"order/cancel-batch": batchFixture,
+		"order/history":      emptyList,
+		"order/realtime":     emptyList,
+		"market/tickers":     emptyList,
+		"market/kline":       emptyList,
+		"market/orderbook": func(req *Request) any {
+			return &bybitHttp.OrderBookResult{
+				Symbol:    req.Query.Get("symbol"),
+				Asks:      [][]string{},
+				Bids:      [][]string{},
+				Timestamp: time.Now().UnixMilli(),
+			}
+		},
+		"asset/withdraw/create": func(*Request) any {
+			return map[string]string{"id": uuid.New().String()}
+		},
+		"user/query-api":                          apiKeyFixture,
+		"user/create-sub-api":                     apiKeyFixture,
+		"user/update-api":                         apiKeyFixture,
+		"user/update-sub-api":                     apiKeyFixture,
+		"user/delete-api":                         empty,
+		"user/delete-sub-api":                     empty,
+		"asset/transfer/query-transfer-coin-list": emptyList,
+		"asset/transfer/inter-transfer": func(*Request) any {
+			return map[string]string{"transferId": uuid.New().String()}
+		},
+		"account/wallet-balance": emptyList,
+		"account/borrow-history": emptyList,
+		"position/list":          emptyList,
+	}
+}
+
+// orderFixture echoes the order of the request, generating its id.
+func orderFixture(req *Request) any {
+	var order struct {
+		OrderID     string `json:"orderId"`
+		OrderLinkID string `json:"orderLinkId"`
+	}
+	_ = req.Decode(&order)
+
+	if order.OrderID == "" {
+		order.OrderID = uuid.New().String()
+	}
+
+	return &bybitHttp.OrderResponse{
+		OrderId:     order.OrderID,
+		OrderLinkId: order.OrderLinkID,
+	}
+}
+
+// batchFixture echoes the orders of the request, every one successful.
+func batchFixture(req *Request) any {
+	var batch struct {
+		Category string `json:"category"`
+		Request  []struct {
+			Symbol      string `json:"symbol"`
+			OrderID     string `json:"orderId"`
+			OrderLinkID string `json:"orderLinkId"`
+		} `json:"request"`
+	}
+	_ = req.Decode(&batch)
+
+	result := &bybitHttp.BatchOrderResult{List: make([]*bybitHttp.BatchOrderItem, 0)}
+	ext := &bybitHttp.BatchExtInfo{List: make([]bybitHttp.BatchItemStatus, 0)}
+	for _, order := range batch.Request {
+		if order.OrderID == "" {
+			order.OrderID = uuid.New().String()
+		}
+
+		result.List = append(result.List, &bybitHttp.BatchOrderItem{
+			Category:    batch.Category,
+			Symbol:      order.Symbol,
+			OrderId:     order.OrderID,
+			OrderLinkId: order.OrderLinkID,
+			CreateAt:    strconv.FormatInt(time.Now().UnixMilli(), 10),
+		})
+		ext.List = append(ext.List, bybitHttp.BatchItemStatus{Code: bybitHttp.RetCodeOK, Msg: "OK"})
+	}
+
+	return &Response{
+		RetCode:    bybitHttp.RetCodeOK,
+		RetMsg:     "OK",
+		Result:     result,
+		RetExtInfo: ext,
+	}
+}
+
+// apiKeyFixture information of a read-write key without ip restrictions.
+func apiKeyFixture(req *Request) any {
+	return &bybitHttp.APIKeyInformationListResponse{
+		APIKey:      req.Header.Get(APIKeyHeader),
+		Permissions: &bybitHttp.Permissions{},
+		Ips:         []string{"*"},
+		CreatedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,367 @@
+// Package bybittest provides an in-process bybit server to test code using
+// the http and websocket clients without network access.
+package bybittest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/gorilla/websocket"
+)
+
+// retCodes returned by the server, same as bybit.
+const (
+	RetCodeInvalidRequest = 10001
+	RetCodeInvalidTime    = 10002
+	RetCodeInvalidKey     = 10003
+	RetCodeInvalidSign    = 10004
+	RetCodeRateLimit      = 10006
+)
+
+// headers of the requests and responses.
+const (
+	APIKeyHeader         = "X-BAPI-API-KEY"
+	TimestampHeader      = "X-BAPI-TIMESTAMP"
+	SignHeader           = "X-BAPI-SIGN"
+	RecvWindowHeader     = "X-BAPI-RECV-WINDOW"
+	LimitHeader          = "X-Bapi-Limit"
+	LimitStatusHeader    = "X-Bapi-Limit-Status"
+	LimitResetHeader     = "X-Bapi-Limit-Reset-Timestamp"
+	DefaultRecvWindow    = 5000
+	pathPrefix           = "/" + bybitHttp.APIVersion + "/"
+	websocketPublicPath  = pathPrefix + "public/"
+	websocketPrivatePath = pathPrefix + "private"
+	websocketTradePath   = pathPrefix + "trade"
+)
+
+// Request received by the server
+type Request struct {
+	Method string
+	// Endpoint path without the version, e.g. order/create.
+	Endpoint string
+	Query    url.Values
+	Body     []byte
+	Header   http.Header
+}
+
+// Decode the json body of the request into v
+func (r *Request) Decode(v any) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Response envelope sent by the server, a Fixture can return it to set
+// fields other than the result e.g. RetExtInfo.
+type Response struct {
+	RetCode    int    `json:"retCode"`
+	RetMsg     string `json:"retMsg"`
+	Result     any    `json:"result"`
+	RetExtInfo any    `json:"retExtInfo"`
+	Time       int64  `json:"time"`
+}
+
+// Fixture builds the result of an endpoint from the request received, the
+// result is wrapped in a successful Response unless it's a *Response.
+type Fixture func(req *Request) any
+
+type retError struct {
+	code int
+	msg  string
+}
+
+type rateLimit struct {
+	limit  int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+// Server in-process bybit server, serving the REST api and the websocket
+// channels. Requests are authenticated like bybit does, with the api key
+// and secret of the server.
+type Server struct {
+	APIKey    string
+	APISecret string
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu         sync.Mutex
+	fixtures   map[string]Fixture
+	errors     map[string]retError
+	rateLimits map[string]*rateLimit
+	requests   []*Request
+	conns      map[*wsConn]struct{}
+	// rejected topics by their retMsg, see RejectTopic.
+	rejected map[string]string
+	// duplicates trade responses, see DuplicateTradeResponses.
+	duplicates bool
+}
+
+// NewServer starts a server accepting the api key and secret provided,
+// close it with Close.
+func NewServer(apiKey, apiSecret string) *Server {
+	s := &Server{
+		APIKey:     apiKey,
+		APISecret:  apiSecret,
+		fixtures:   defaultFixtures(),
+		errors:     make(map[string]retError),
+		rateLimits: make(map[string]*rateLimit),
+		conns:      make(map[*wsConn]struct{}),
+		rejected:   make(map[string]string),
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Close closes every connection and stops the server
+func (s *Server) Close() {
+	s.Disconnect(websocket.CloseGoingAway)
+	s.srv.Close()
+}
+
+// URL base url of the REST api, e.g. http://127.0.0.1:1234
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// WebsocketURL base url of the websocket api, use it with websocket.WithBaseURL
+func (s *Server) WebsocketURL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Config creates the configuration of clients connecting to the server
+func (s *Server) Config() *config.AppConfig {
+	cfg := &config.AppConfig{}
+	cfg.ByBit.APIKey = s.APIKey
+	cfg.ByBit.APISecret = s.APISecret
+	cfg.ByBit.BaseURL = s.URL()
+
+	return cfg
+}
+
+// SetFixture sets the result returned by endpoint, e.g. market/tickers
+func (s *Server) SetFixture(endpoint string, result any) {
+	s.HandleFixture(endpoint, func(*Request) any { return result })
+}
+
+// HandleFixture sets the fixture building the result of endpoint
+func (s *Server) HandleFixture(endpoint string, fixture Fixture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures[endpoint] = fixture
+}
+
+// SetError makes endpoint fail with retCode and retMsg until ClearError
+func (s *Server) SetError(endpoint string, retCode int, retMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[endpoint] = retError{code: retCode, msg: retMsg}
+}
+
+// ClearError removes the error set with SetError
+func (s *Server) ClearError(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.errors, endpoint)
+}
+
+// SetRateLimit limits the requests to endpoint to limit per window, exceeding
+// requests fail with RetCodeRateLimit. The rate limit headers are sent in
+// every response of endpoint.
+func (s *Server) SetRateLimit(endpoint string, limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rateLimits[endpoint] = &rateLimit{limit: limit, window: window}
+}
+
+// Requests retrieve every REST request received, in order
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, websocketPublicPath):
+		s.serveWebsocket(w, r, publicConn)
+	case r.URL.Path == websocketPrivatePath:
+		s.serveWebsocket(w, r, privateConn)
+	case r.URL.Path == websocketTradePath:
+		s.serveWebsocket(w, r, tradeConn)
+	default:
+		s.serveREST(w, r)
+	}
+}
+
+func (s *Server) serveREST(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	req := &Request{
+		Method:   r.Method,
+		Endpoint: strings.TrimPrefix(r.URL.Path, pathPrefix),
+		Query:    r.URL.Query(),
+		Body:     body,
+		Header:   r.Header.Clone(),
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	fixture, ok := s.fixtures[req.Endpoint]
+	retErr, failing := s.errors[req.Endpoint]
+	limited := s.rateLimit(w.Header(), req.Endpoint)
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	payload := string(body)
+	if r.Method == http.MethodGet {
+		payload = r.URL.RawQuery
+	}
+
+	response := s.checkSign(r.Header, payload)
+	switch {
+	case response != nil:
+	case limited:
+		response = &Response{RetCode: RetCodeRateLimit, RetMsg: "Too many visits!"}
+	case failing:
+		response = &Response{RetCode: retErr.code, RetMsg: retErr.msg}
+	default:
+		response = toResponse(fixture(req))
+	}
+
+	// bybit always sends the result object, even on errors.
+	if response.Result == nil {
+		response.Result = struct{}{}
+	}
+
+	if response.RetExtInfo == nil {
+		response.RetExtInfo = struct{}{}
+	}
+
+	if response.Time == 0 {
+		response.Time = time.Now().UnixMilli()
+	}
+
+	writeJSON(w, response)
+}
+
+// checkSign validates the request like bybit, the sign is the hmac of
+// timestamp + api key + recv window + query string or body.
+func (s *Server) checkSign(header http.Header, payload string) *Response {
+	if header.Get(APIKeyHeader) != s.APIKey {
+		return &Response{RetCode: RetCodeInvalidKey, RetMsg: "API key is invalid."}
+	}
+
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return &Response{RetCode: RetCodeInvalidRequest, RetMsg: "invalid timestamp"}
+	}
+
+	recvWindow := int64(DefaultRecvWindow)
+	if value := header.Get(RecvWindowHeader); value != "" {
+		recvWindow, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return &Response{RetCode: RetCodeInvalidRequest, RetMsg: "invalid recv_window"}
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	if timestamp < now-recvWindow || timestamp >= now+1000 {
+		return &Response{
+			RetCode: RetCodeInvalidTime,
+			RetMsg:  "invalid request, please check your server timestamp or recv_window param",
+		}
+	}
+
+	expected := sign(s.APISecret, fmt.Sprintf("%d%s%d%s", timestamp, s.APIKey, recvWindow, payload))
+	if !hmac.Equal([]byte(expected), []byte(header.Get(SignHeader))) {
+		return &Response{RetCode: RetCodeInvalidSign, RetMsg: "error sign! origin_string[" + payload + "]"}
+	}
+
+	return nil
+}
+
+// rateLimit counts the request in the window of endpoint, reporting whether
+// it exceeds the limit. Must be called with the lock held.
+func (s *Server) rateLimit(header http.Header, endpoint string) bool {
+	limit, ok := s.rateLimits[endpoint]
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(limit.start) >= limit.window {
+		limit.start = now
+		limit.count = 0
+	}
+	limit.count++
+
+	remaining := limit.limit - limit.count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	header.Set(LimitHeader, strconv.Itoa(limit.limit))
+	header.Set(LimitStatusHeader, strconv.Itoa(remaining))
+	header.Set(LimitResetHeader, strconv.FormatInt(limit.start.Add(limit.window).UnixMilli(), 10))
+
+	return limit.count > limit.limit
+}
+
+// toResponse wraps result in a Response, a *Response is copied since
+// fixtures may return the same one to concurrent requests.
+func toResponse(result any) *Response {
+	if response, ok := result.(*Response); ok {
+		copied := *response
+
+		return &copied
+	}
+
+	return &Response{
+		RetCode: bybitHttp.RetCodeOK,
+		RetMsg:  "OK",
+		Result:  result,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func sign(secret, payload string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(payload))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
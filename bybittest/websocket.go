@@ -0,0 +1,480 @@
+package bybittest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type connKind int
+
+const (
+	publicConn connKind = iota
+	privateConn
+	tradeConn
+)
+
+// endpoints serving the operations of the trade channel.
+var tradeEndpoints = map[string]string{
+	bybitWs.OrderCreateOp:      "order/create",
+	bybitWs.OrderAmendOp:       "order/amend",
+	bybitWs.OrderCancelOp:      "order/cancel",
+	bybitWs.OrderCreateBatchOp: "order/create-batch",
+	bybitWs.OrderAmendBatchOp:  "order/amend-batch",
+	bybitWs.OrderCancelBatchOp: "order/cancel-batch",
+}
+
+// wsConn websocket connection accepted by the server.
+type wsConn struct {
+	conn    *websocket.Conn
+	kind    connKind
+	path    string
+	id      string
+	writeMu sync.Mutex
+
+	mu     sync.Mutex
+	authed bool
+	topics map[string]struct{}
+}
+
+// wsRequest operation sent by the clients, reqId is used by the trade channel.
+type wsRequest struct {
+	ReqID   string            `json:"req_id"`
+	TradeID string            `json:"reqId"`
+	Op      string            `json:"op"`
+	Args    []json.RawMessage `json:"args"`
+	Header  map[string]string `json:"header"`
+}
+
+// Publish sends msg to every connection subscribed to topic
+func (s *Server) Publish(topic string, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range s.connections() {
+		if c.subscribed(topic) {
+			_ = c.write(websocket.TextMessage, data)
+		}
+	}
+
+	return nil
+}
+
+// PublishPrivate sends msg to every authenticated connection of the private channel
+func (s *Server) PublishPrivate(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range s.connections() {
+		if c.kind == privateConn && c.isAuthed() {
+			_ = c.write(websocket.TextMessage, data)
+		}
+	}
+
+	return nil
+}
+
+// WaitSubscribed waits until a connection subscribes to topic or ctx is done
+func (s *Server) WaitSubscribed(ctx context.Context, topic string) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		for _, c := range s.connections() {
+			if c.subscribed(topic) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RejectTopic makes the subscriptions including topic fail with retMsg, like
+// bybit does with unknown symbols. An empty retMsg accepts topic again.
+func (s *Server) RejectTopic(topic, retMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if retMsg == "" {
+		delete(s.rejected, topic)
+
+		return
+	}
+
+	s.rejected[topic] = retMsg
+}
+
+// DuplicateTradeResponses makes the trade channel send every response three
+// times, to test clients against duplicated or late responses.
+func (s *Server) DuplicateTradeResponses(duplicate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.duplicates = duplicate
+}
+
+// Disconnect closes every websocket connection with the close code provided,
+// code 0 drops the connections without close message like a network failure.
+// The connections are forgotten right away, so WaitSubscribed only sees the
+// subscriptions of the reconnections.
+func (s *Server) Disconnect(code int) {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = make(map[*wsConn]struct{})
+	s.mu.Unlock()
+
+	for c := range conns {
+		if code != 0 {
+			_ = c.write(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+		}
+
+		c.conn.Close()
+	}
+}
+
+// Connection websocket connection open in the server
+type Connection struct {
+	// Path of the connection e.g. /v5/public/spot.
+	Path string
+	// Topics subscribed in the connection.
+	Topics []string
+}
+
+// Connections lists the websocket connections open in the server
+func (s *Server) Connections() []Connection {
+	conns := s.connections()
+	connections := make([]Connection, 0, len(conns))
+	for _, c := range conns {
+		c.mu.Lock()
+		topics := make([]string, 0, len(c.topics))
+		for topic := range c.topics {
+			topics = append(topics, topic)
+		}
+		c.mu.Unlock()
+
+		connections = append(connections, Connection{Path: c.path, Topics: topics})
+	}
+
+	return connections
+}
+
+func (s *Server) connections() []*wsConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+func (s *Server) serveWebsocket(w http.ResponseWriter, r *http.Request, kind connKind) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := &wsConn{
+		conn:   conn,
+		kind:   kind,
+		path:   r.URL.Path,
+		id:     uuid.New().String(),
+		topics: make(map[string]struct{}),
+	}
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			continue
+		}
+
+		s.processOperation(c, &req)
+	}
+}
+
+func (s *Server) processOperation(c *wsConn, req *wsRequest) {
+	switch req.Op {
+	case bybitWs.PingOp:
+		c.pong(req)
+	case bybitWs.AuthOp:
+		c.auth(s.checkAuth(req))
+	case bybitWs.SubscribeOp, bybitWs.UnsubscribeOp:
+		c.subscribe(req, s.rejection(req))
+	default:
+		endpoint, ok := tradeEndpoints[req.Op]
+		if !ok || c.kind != tradeConn {
+			c.ack(req, false, "unknown op "+req.Op)
+
+			return
+		}
+
+		s.trade(c, req, endpoint)
+	}
+}
+
+// checkAuth validates the auth operation like bybit, the sign is the hmac
+// of "GET/realtime" + expires. An empty message means success.
+func (s *Server) checkAuth(req *wsRequest) string {
+	if len(req.Args) != 3 {
+		return "Params Error"
+	}
+
+	var (
+		apiKey, signature string
+		expires           int64
+	)
+
+	if json.Unmarshal(req.Args[0], &apiKey) != nil ||
+		json.Unmarshal(req.Args[1], &expires) != nil ||
+		json.Unmarshal(req.Args[2], &signature) != nil {
+		return "Params Error"
+	}
+
+	if apiKey != s.APIKey {
+		return "Invalid apikey"
+	}
+
+	if expires < time.Now().UnixMilli() {
+		return "Params Error"
+	}
+
+	if sign(s.APISecret, "GET/realtime"+strconv.FormatInt(expires, 10)) != signature {
+		return "Invalid sign"
+	}
+
+	return ""
+}
+
+// rejection retMsg of the first topic of a subscription rejected with RejectTopic
+func (s *Server) rejection(req *wsRequest) string {
+	if req.Op != bybitWs.SubscribeOp {
+		return ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, arg := range req.Args {
+		var topic string
+		if json.Unmarshal(arg, &topic) == nil && s.rejected[topic] != "" {
+			return s.rejected[topic]
+		}
+	}
+
+	return ""
+}
+
+// trade performs the operation with the fixture of endpoint.
+func (s *Server) trade(c *wsConn, req *wsRequest, endpoint string) {
+	var body []byte
+	if len(req.Args) > 0 {
+		body = req.Args[0]
+	}
+
+	httpReq := &Request{
+		Method:   http.MethodPost,
+		Endpoint: endpoint,
+		Body:     body,
+		Header:   http.Header{},
+	}
+	httpReq.Header.Set(APIKeyHeader, s.APIKey)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, httpReq)
+	fixture := s.fixtures[endpoint]
+	retErr, failing := s.errors[endpoint]
+	duplicates := s.duplicates
+	s.mu.Unlock()
+
+	response := &Response{RetCode: retErr.code, RetMsg: retErr.msg}
+	if !c.isAuthed() {
+		response = &Response{RetCode: RetCodeInvalidKey, RetMsg: "Request not authorized"}
+	} else if !failing {
+		response = toResponse(fixture(httpReq))
+	}
+
+	msg := map[string]any{
+		"reqId":      req.TradeID,
+		"retCode":    response.RetCode,
+		"retMsg":     response.RetMsg,
+		"op":         req.Op,
+		"data":       response.Result,
+		"retExtInfo": response.RetExtInfo,
+		"header": map[string]string{
+			TimestampHeader: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+		"connId": c.id,
+	}
+
+	c.writeJSON(msg)
+	if duplicates {
+		c.writeJSON(msg)
+		c.writeJSON(msg)
+	}
+}
+
+func (c *wsConn) pong(req *wsRequest) {
+	if c.kind == publicConn {
+		c.writeJSON(bybitWs.OperationResponse{
+			Success: true,
+			RetMsg:  "pong",
+			ConnID:  c.id,
+			ReqID:   req.ReqID,
+			Op:      bybitWs.PingOp,
+		})
+
+		return
+	}
+
+	c.writeJSON(map[string]any{
+		"req_id":  req.ReqID,
+		"reqId":   req.TradeID,
+		"op":      "pong",
+		"args":    []string{strconv.FormatInt(time.Now().UnixMilli(), 10)},
+		"conn_id": c.id,
+	})
+}
+
+func (c *wsConn) auth(failure string) {
+	c.mu.Lock()
+	c.authed = failure == ""
+	c.mu.Unlock()
+
+	if c.kind == tradeConn {
+		retCode, retMsg := bybitHttp.RetCodeOK, "OK"
+		if failure != "" {
+			retCode, retMsg = RetCodeInvalidSign, failure
+		}
+
+		c.writeJSON(map[string]any{
+			"retCode": retCode,
+			"retMsg":  retMsg,
+			"op":      bybitWs.AuthOp,
+			"connId":  c.id,
+		})
+
+		return
+	}
+
+	c.writeJSON(bybitWs.OperationResponse{
+		Success: failure == "",
+		RetMsg:  failure,
+		ConnID:  c.id,
+		Op:      bybitWs.AuthOp,
+	})
+}
+
+func (c *wsConn) subscribe(req *wsRequest, rejection string) {
+	if c.kind == privateConn && !c.isAuthed() {
+		c.ack(req, false, "Request not authorized")
+
+		return
+	}
+
+	if rejection != "" {
+		c.ack(req, false, rejection)
+
+		return
+	}
+
+	topics := make([]string, 0, len(req.Args))
+	for _, arg := range req.Args {
+		var topic string
+		if err := json.Unmarshal(arg, &topic); err != nil || topic == "" {
+			c.ack(req, false, "Invalid topic")
+
+			return
+		}
+
+		topics = append(topics, topic)
+	}
+
+	c.mu.Lock()
+	for _, topic := range topics {
+		if req.Op == bybitWs.SubscribeOp {
+			c.topics[topic] = struct{}{}
+		} else {
+			delete(c.topics, topic)
+		}
+	}
+	c.mu.Unlock()
+
+	c.ack(req, true, "")
+}
+
+func (c *wsConn) ack(req *wsRequest, success bool, retMsg string) {
+	c.writeJSON(bybitWs.OperationResponse{
+		Success: success,
+		RetMsg:  retMsg,
+		ConnID:  c.id,
+		ReqID:   req.ReqID,
+		Op:      req.Op,
+	})
+}
+
+func (c *wsConn) subscribed(topic string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.topics[topic]
+
+	return ok
+}
+
+func (c *wsConn) isAuthed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.authed
+}
+
+func (c *wsConn) writeJSON(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	_ = c.write(websocket.TextMessage, data)
+}
+
+func (c *wsConn) write(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.conn.WriteMessage(messageType, data)
+}
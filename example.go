@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 
 	"github.com/Gealber/bybit/config"
 	bybitHttp "github.com/Gealber/bybit/http"
@@ -49,10 +51,14 @@ func main() {
 }
 
 func websocketExample(ctx context.Context, cfg *config.AppConfig) {
+	// shutdown gracefully on Ctrl+C.
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
 	wb := bybitWs.NewClient(cfg)
 
 	tickerSubsciption := bybitWs.Request{
-		Op: "subscribe",
+		Op: bybitWs.SubscribeOp,
 		Args: []interface{}{
 			// bybitWs.TickersBtcUSDTTopic,
 			// bybitWs.TickersTONUSDTTopic,
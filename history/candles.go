@@ -0,0 +1,229 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// CandleKey identifies the candles of a symbol in a category, Category is
+// empty for the trades added without it.
+type CandleKey struct {
+	Category string
+	Symbol   string
+}
+
+// Builder aggregates trades into candles of an interval, either a bybit
+// interval like "5" or "D" or any duration like "2m" or "10s". A candle is
+// emitted once a trade of a later candle arrives, intervals without trades
+// are emitted as flat candles without volume as bybit does. It implements
+// websocket.Handler for the publicTrade topics and it's safe for
+// concurrent use.
+type Builder struct {
+	period   period
+	onCandle func(key CandleKey, candle bybitHttp.Candle)
+
+	mu      sync.Mutex
+	current map[CandleKey]*bybitHttp.Candle
+}
+
+// NewBuilder creates a builder of candles of interval passing every closed
+// candle to onCandle.
+func NewBuilder(interval string, onCandle func(key CandleKey, candle bybitHttp.Candle)) (*Builder, error) {
+	p, err := parsePeriod(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Builder{
+		period:   p,
+		onCandle: onCandle,
+		current:  make(map[CandleKey]*bybitHttp.Candle),
+	}, nil
+}
+
+// Add aggregates a trade of key, trades older than the current candle of
+// the key are ignored.
+func (b *Builder) Add(key CandleKey, t time.Time, price, size float64) {
+	closed := b.add(key, t, price, size)
+	for _, candle := range closed {
+		b.onCandle(key, candle)
+	}
+}
+
+func (b *Builder) add(key CandleKey, t time.Time, price, size float64) []bybitHttp.Candle {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	start := b.period.align(t)
+	candle, ok := b.current[key]
+	if ok && start.Before(candle.Start) {
+		return nil
+	}
+
+	closed := make([]bybitHttp.Candle, 0)
+	if ok && start.After(candle.Start) {
+		closed = append(closed, *candle)
+
+		// flat candles for the intervals without trades.
+		for next := b.period.next(candle.Start); next.Before(start); next = b.period.next(next) {
+			closed = append(closed, bybitHttp.Candle{
+				Start: next,
+				Open:  candle.Close,
+				High:  candle.Close,
+				Low:   candle.Close,
+				Close: candle.Close,
+			})
+		}
+
+		ok = false
+	}
+
+	if !ok {
+		candle = &bybitHttp.Candle{Start: start, Open: price, High: price, Low: price}
+		b.current[key] = candle
+	}
+
+	candle.High = math.Max(candle.High, price)
+	candle.Low = math.Min(candle.Low, price)
+	candle.Close = price
+	candle.Volume += size
+	candle.Turnover += price * size
+
+	return closed
+}
+
+// Current retrieves the candle of key still open
+func (b *Builder) Current(key CandleKey) (bybitHttp.Candle, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	candle, ok := b.current[key]
+	if !ok {
+		return bybitHttp.Candle{}, false
+	}
+
+	return *candle, true
+}
+
+// Flush emits the open candles, e.g. at the end of stored trades
+func (b *Builder) Flush() {
+	b.mu.Lock()
+	current := b.current
+	b.current = make(map[CandleKey]*bybitHttp.Candle)
+	b.mu.Unlock()
+
+	for key, candle := range current {
+		b.onCandle(key, *candle)
+	}
+}
+
+// ProcessMsg implements websocket.Handler for the publicTrade topics, the
+// trades are keyed by the category of the endpoint they were received from.
+func (b *Builder) ProcessMsg(ctx context.Context, obj any) error {
+	msg, ok := obj.(bybitWs.PublicTradeResponse)
+	if !ok {
+		return fmt.Errorf("%w: %T", bybitWs.ErrorInvalidMessage, obj)
+	}
+
+	var category string
+	if endpoint, ok := bybitWs.EndpointFromContext(ctx); ok {
+		category = string(endpoint.Category)
+	}
+
+	for _, trade := range msg.Data {
+		price, err := strconv.ParseFloat(trade.Price, 64)
+		if err != nil {
+			return err
+		}
+
+		size, err := strconv.ParseFloat(trade.Size, 64)
+		if err != nil {
+			return err
+		}
+
+		b.Add(CandleKey{Category: category, Symbol: trade.Symbol}, time.UnixMilli(trade.Timestamp).UTC(), price, size)
+	}
+
+	return nil
+}
+
+// Resample aggregates candles of source interval, sorted by start time,
+// into candles of interval, which must be a whole multiple of source. The
+// last candle may be incomplete and gaps in candles are kept.
+func Resample(candles []bybitHttp.Candle, source, interval string) ([]bybitHttp.Candle, error) {
+	from, err := parsePeriod(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := parsePeriod(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	if from.duration > 0 && p.duration > 0 && p.duration%from.duration != 0 {
+		return nil, fmt.Errorf("%w: %s of %s", ErrorNotMultiple, interval, source)
+	}
+
+	resampled := make([]bybitHttp.Candle, 0)
+	for i, candle := range candles {
+		if i > 0 && !candle.Start.After(candles[i-1].Start) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrorUnsortedCandles, candle.Start, candles[i-1].Start)
+		}
+
+		if !from.align(candle.Start).Equal(candle.Start) {
+			return nil, fmt.Errorf("%w: %s of %s", ErrorMisalignedCandles, source, candle.Start)
+		}
+
+		// e.g. a week across two months.
+		start := p.align(candle.Start)
+		if from.next(candle.Start).After(p.next(start)) {
+			return nil, fmt.Errorf("%w: %s of %s", ErrorNotMultiple, interval, source)
+		}
+
+		last := len(resampled) - 1
+		if last < 0 || !resampled[last].Start.Equal(start) {
+			candle.Start = start
+			resampled = append(resampled, candle)
+
+			continue
+		}
+
+		current := &resampled[last]
+		current.High = math.Max(current.High, candle.High)
+		current.Low = math.Min(current.Low, candle.Low)
+		current.Close = candle.Close
+		current.Volume += candle.Volume
+		current.Turnover += candle.Turnover
+	}
+
+	return resampled, nil
+}
+
+// ResampleKlines parses klines of source interval, as returned by GetKline
+// or stored in a Store, and resamples them into candles of interval.
+func ResampleKlines(klines []bybitHttp.Kline, source, interval string) ([]bybitHttp.Candle, error) {
+	candles := make([]bybitHttp.Candle, 0, len(klines))
+	for _, kline := range klines {
+		candle, err := kline.Candle()
+		if err != nil {
+			return nil, err
+		}
+
+		candles = append(candles, candle)
+	}
+
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Start.Before(candles[j].Start) })
+
+	return Resample(candles, source, interval)
+}
+
+var _ bybitWs.Handler = (*Builder)(nil)
@@ -0,0 +1,145 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// candles of every step from start, with the close price of closes
+func candles(start time.Time, step time.Duration, closes ...float64) []bybitHttp.Candle {
+	result := make([]bybitHttp.Candle, 0, len(closes))
+	for i, price := range closes {
+		result = append(result, bybitHttp.Candle{
+			Start:    start.Add(time.Duration(i) * step),
+			Open:     price - 1,
+			High:     price + 1,
+			Low:      price - 2,
+			Close:    price,
+			Volume:   1,
+			Turnover: price,
+		})
+	}
+
+	return result
+}
+
+func TestResample(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		candles  []bybitHttp.Candle
+		source   string
+		interval string
+		expected []bybitHttp.Candle
+		err      error
+	}{
+		{
+			name:     "minutes",
+			candles:  candles(start, time.Minute, 10, 12, 11, 15, 14, 13),
+			source:   "1",
+			interval: "5",
+			expected: []bybitHttp.Candle{
+				{Start: start, Open: 9, High: 16, Low: 8, Close: 14, Volume: 5, Turnover: 62},
+				{Start: start.Add(5 * time.Minute), Open: 12, High: 14, Low: 11, Close: 13, Volume: 1, Turnover: 13},
+			},
+		},
+		{
+			name:     "gaps are kept",
+			candles:  append(candles(start, time.Hour, 10), candles(start.Add(3*day), time.Hour, 20)...),
+			source:   "60",
+			interval: "D",
+			expected: []bybitHttp.Candle{
+				{Start: start, Open: 9, High: 11, Low: 8, Close: 10, Volume: 1, Turnover: 10},
+				{Start: start.Add(3 * day), Open: 19, High: 21, Low: 18, Close: 20, Volume: 1, Turnover: 20},
+			},
+		},
+		{
+			name:     "days into months",
+			candles:  candles(start.Add(29*day), day, 10, 20, 30),
+			source:   "D",
+			interval: "M",
+			expected: []bybitHttp.Candle{
+				{Start: start, Open: 9, High: 21, Low: 8, Close: 20, Volume: 2, Turnover: 30},
+				{Start: start.AddDate(0, 1, 0), Open: 29, High: 31, Low: 28, Close: 30, Volume: 1, Turnover: 30},
+			},
+		},
+		{name: "not a multiple", candles: candles(start, time.Minute, 10), source: "1", interval: "90s", err: ErrorNotMultiple},
+		{name: "smaller interval", candles: candles(start, time.Hour, 10), source: "60", interval: "1", err: ErrorNotMultiple},
+		// the week started on 2024-01-29 ends in February.
+		{name: "weeks into months", candles: candles(start.Add(28*day), 7*day, 10), source: "W", interval: "M", err: ErrorNotMultiple},
+		{name: "misaligned", candles: candles(start.Add(30*time.Minute), time.Hour, 10, 11), source: "60", interval: "D", err: ErrorMisalignedCandles},
+		{name: "unsorted", candles: candles(start.Add(time.Hour), -time.Hour, 10, 11), source: "60", interval: "D", err: ErrorUnsortedCandles},
+		{name: "duplicated", candles: candles(start, 0, 10, 11), source: "60", interval: "D", err: ErrorUnsortedCandles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resampled, err := Resample(tt.candles, tt.source, tt.interval)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v got %v", tt.err, err)
+			}
+
+			if tt.err == nil && !reflect.DeepEqual(resampled, tt.expected) {
+				t.Fatalf("expected %+v got %+v", tt.expected, resampled)
+			}
+		})
+	}
+}
+
+func TestBuilderKeys(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	closed := make(map[CandleKey][]bybitHttp.Candle)
+	builder, err := NewBuilder("1", func(key CandleKey, candle bybitHttp.Candle) {
+		closed[key] = append(closed[key], candle)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trade := func(category bybitWs.CoverType, offset time.Duration, price string) {
+		ctx := bybitWs.ContextWithEndpoint(context.Background(), bybitWs.Endpoint{Channel: bybitWs.PublicChannel, Category: category})
+		err := builder.ProcessMsg(ctx, bybitWs.PublicTradeResponse{
+			Topic: "publicTrade.BTCUSDT",
+			Data:  []bybitWs.TradeData{{Timestamp: start.Add(offset).UnixMilli(), Symbol: "BTCUSDT", Price: price, Size: "1"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trade(bybitWs.Spot, 0, "100")
+	trade(bybitWs.Linear, 10*time.Second, "200")
+	trade(bybitWs.Spot, 20*time.Second, "101")
+	// closes the spot candle and a flat one for the minute without trades.
+	trade(bybitWs.Spot, 2*time.Minute, "102")
+	// older than the current candle, ignored.
+	trade(bybitWs.Spot, time.Minute, "1")
+
+	spot, linear := CandleKey{Category: "spot", Symbol: "BTCUSDT"}, CandleKey{Category: "linear", Symbol: "BTCUSDT"}
+	expected := []bybitHttp.Candle{
+		{Start: start, Open: 100, High: 101, Low: 100, Close: 101, Volume: 2, Turnover: 201},
+		{Start: start.Add(time.Minute), Open: 101, High: 101, Low: 101, Close: 101},
+	}
+	if !reflect.DeepEqual(closed[spot], expected) || len(closed[linear]) != 0 {
+		t.Fatalf("unexpected closed candles %+v", closed)
+	}
+
+	current, ok := builder.Current(linear)
+	if !ok || current.Close != 200 || current.Volume != 1 {
+		t.Fatalf("unexpected linear candle %+v", current)
+	}
+
+	builder.Flush()
+	if len(closed[spot]) != 3 || len(closed[linear]) != 1 {
+		t.Fatalf("expected the open candles to be flushed got %+v", closed)
+	}
+}
@@ -0,0 +1,6 @@
+package history
+
+const (
+	// MaxKlineLimit max number of klines returned by a single request.
+	MaxKlineLimit = 1000
+)
@@ -0,0 +1,233 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/logging"
+)
+
+// KlineSource retrieves a page of klines, implemented by http.Client
+type KlineSource interface {
+	GetKline(queryParams bybitHttp.KlineParams) ([]bybitHttp.Kline, error)
+}
+
+// Downloader fills a Store with the klines of arbitrary ranges, only the
+// klines missing in the store are requested.
+type Downloader struct {
+	source   KlineSource
+	store    *Store
+	pageSize int
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewDownloader creates a downloader of klines from source into store,
+// opts allow to customize it.
+func NewDownloader(source KlineSource, store *Store, opts ...Option) *Downloader {
+	d := &Downloader{
+		source:   source,
+		store:    store,
+		pageSize: MaxKlineLimit,
+		logger:   logging.Default("bybit-history"),
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Download stores the closed klines of symbol started in [from, to). The
+// gaps of the store in the range are requested paging backwards from their
+// end, ranges without klines in bybit between the klines returned or
+// stored are recorded in the index so later runs don't request them again.
+func (d *Downloader) Download(ctx context.Context, category, symbol, interval string, from, to time.Time) (*Index, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from %s to %s", ErrorInvalidRange, from, to)
+	}
+
+	stored, err := d.store.Load(category, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := d.store.Index(category, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounds Range
+	have := make(map[int64]struct{}, len(stored))
+	for _, kline := range stored {
+		start, err := klineStart(kline)
+		if err != nil {
+			return nil, err
+		}
+
+		have[start.UnixMilli()] = struct{}{}
+		bounds = bounds.extend(start)
+	}
+
+	gaps, err := d.gaps(interval, from, to, func(start time.Time) bool {
+		_, ok := have[start.UnixMilli()]
+
+		return ok || index.missing(start)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gap := range gaps {
+		d.logger.Info("downloading klines",
+			slog.String("symbol", symbol),
+			slog.String("interval", interval),
+			slog.Time("from", gap.From),
+			slog.Time("to", gap.To),
+		)
+
+		klines, err := d.fetch(ctx, category, symbol, interval, gap)
+		if err != nil {
+			return nil, err
+		}
+
+		fetched := make(map[int64]struct{}, len(klines))
+		for _, kline := range klines {
+			start, err := klineStart(kline)
+			if err != nil {
+				return nil, err
+			}
+
+			fetched[start.UnixMilli()] = struct{}{}
+			bounds = bounds.extend(start)
+		}
+
+		// klines still missing after the download don't exist in bybit when
+		// there are klines before and after them. A fetch without klines,
+		// e.g. of a range before the listing of the symbol, records nothing.
+		missing := make([]Range, 0)
+		if len(klines) > 0 {
+			missing, err = d.gaps(interval, gap.From, gap.To.Add(time.Millisecond), func(start time.Time) bool {
+				_, ok := fetched[start.UnixMilli()]
+
+				return ok
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			missing = bounded(missing, bounds)
+		}
+
+		index, err = d.store.Merge(category, symbol, interval, klines, missing)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return index, nil
+}
+
+// gaps ranges of the closed klines started in [from, to) without klines
+// according to present.
+func (d *Downloader) gaps(interval string, from, to time.Time, present func(start time.Time) bool) ([]Range, error) {
+	start, err := Align(interval, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if start.Before(from) {
+		start, err = Next(interval, start)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := d.now()
+	gaps := make([]Range, 0)
+	var gap *Range
+	for start.Before(to) {
+		next, err := Next(interval, start)
+		if err != nil {
+			return nil, err
+		}
+
+		// the current kline isn't closed yet.
+		if next.After(now) {
+			break
+		}
+
+		switch {
+		case present(start):
+			gap = nil
+		case gap == nil:
+			gaps = append(gaps, Range{From: start, To: start})
+			gap = &gaps[len(gaps)-1]
+		default:
+			gap.To = start
+		}
+
+		start = next
+	}
+
+	return gaps, nil
+}
+
+// fetch the klines started within gap, paging backwards from its end.
+func (d *Downloader) fetch(ctx context.Context, category, symbol, interval string, gap Range) ([]bybitHttp.Kline, error) {
+	klines := make([]bybitHttp.Kline, 0)
+	end := gap.To
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := d.source.GetKline(bybitHttp.KlineParams{
+			Category: category,
+			Symbol:   symbol,
+			Interval: interval,
+			Start:    int(gap.From.UnixMilli()),
+			End:      int(end.UnixMilli()),
+			Limit:    d.pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		oldest := end
+		for _, kline := range page {
+			start, err := klineStart(kline)
+			if err != nil {
+				return nil, err
+			}
+
+			if !gap.Contains(start) {
+				continue
+			}
+
+			klines = append(klines, kline)
+			if start.Before(oldest) {
+				oldest = start
+			}
+		}
+
+		d.logger.Debug("downloaded klines page",
+			slog.String("symbol", symbol),
+			slog.Int("klines", len(page)),
+			slog.Time("oldest", oldest),
+		)
+
+		// a short page or a page without progress is the last one.
+		if len(page) < d.pageSize || !oldest.Before(end) || !oldest.After(gap.From) {
+			return klines, nil
+		}
+
+		end = oldest.Add(-time.Millisecond)
+	}
+}
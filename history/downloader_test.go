@@ -0,0 +1,139 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/logging"
+)
+
+// fakeSource serves the hourly klines started at hours, newest first
+type fakeSource struct {
+	hours    []int
+	requests int
+	err      error
+}
+
+func (s *fakeSource) GetKline(params bybitHttp.KlineParams) ([]bybitHttp.Kline, error) {
+	s.requests++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	klines := make([]bybitHttp.Kline, 0)
+	for i := len(s.hours) - 1; i >= 0 && len(klines) < params.Limit; i-- {
+		start := hour(s.hours[i]).UnixMilli()
+		if start >= int64(params.Start) && start <= int64(params.End) {
+			klines = append(klines, bybitHttp.Kline{strconv.FormatInt(start, 10), "1", "2", "0.5", "1.5", "10", "15"})
+		}
+	}
+
+	return klines, nil
+}
+
+func newTestDownloader(t *testing.T, source KlineSource) (*Downloader, *Store) {
+	t.Helper()
+
+	store := NewStore(t.TempDir())
+	d := NewDownloader(source, store, WithPageSize(2), WithLogger(logging.Discard()))
+	d.now = func() time.Time { return hour(24) }
+
+	return d, store
+}
+
+func TestDownload(t *testing.T) {
+	// listed at 02:00 with the 04:00 kline lost in a maintenance.
+	source := &fakeSource{hours: []int{2, 3, 5, 6, 7}}
+	d, store := newTestDownloader(t, source)
+
+	index, err := d.Download(context.Background(), "spot", "BTCUSDT", "60", hour(0), hour(8))
+	if err != nil {
+		t.Fatalf("downloading: %v", err)
+	}
+
+	if index.Count != 5 || !index.First.Equal(hour(2)) || !index.Last.Equal(hour(7)) || source.requests != 3 {
+		t.Fatalf("unexpected index %+v after %d requests", index, source.requests)
+	}
+
+	// the range before the listing isn't bounded by klines.
+	expected := []Range{{hour(4), hour(4)}}
+	if !reflect.DeepEqual(index.Missing, expected) {
+		t.Fatalf("expected missing %v got %v", expected, index.Missing)
+	}
+
+	klines, err := store.LoadRange("spot", "BTCUSDT", "60", Range{hour(0), hour(8)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(klines) != 5 {
+		t.Fatalf("expected 5 stored klines got %d", len(klines))
+	}
+
+	// only the range before the listing is requested again, it returns no
+	// page and records nothing.
+	index, err = d.Download(context.Background(), "spot", "BTCUSDT", "60", hour(0), hour(8))
+	if err != nil {
+		t.Fatalf("downloading again: %v", err)
+	}
+
+	if source.requests != 4 || !reflect.DeepEqual(index.Missing, expected) {
+		t.Fatalf("unexpected missing %v after %d requests", index.Missing, source.requests)
+	}
+}
+
+func TestDownloadErrorsRecordNothing(t *testing.T) {
+	source := &fakeSource{hours: []int{0, 1, 2}, err: errors.New("rate limit")}
+	d, store := newTestDownloader(t, source)
+
+	_, err := d.Download(context.Background(), "spot", "BTCUSDT", "60", hour(0), hour(3))
+	if err == nil {
+		t.Fatal("expected the error of the source")
+	}
+
+	index, err := store.Index("spot", "BTCUSDT", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index.Missing) != 0 || index.Count != 0 {
+		t.Fatalf("unexpected index %+v", index)
+	}
+}
+
+func TestGaps(t *testing.T) {
+	d, _ := newTestDownloader(t, &fakeSource{})
+	present := map[int]bool{1: true, 2: true, 5: true}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		expected []Range
+	}{
+		{name: "aligned", from: hour(0), to: hour(8), expected: []Range{{hour(0), hour(0)}, {hour(3), hour(4)}, {hour(6), hour(7)}}},
+		{name: "unaligned from", from: hour(0).Add(time.Minute), to: hour(5), expected: []Range{{hour(3), hour(4)}}},
+		{name: "to excluded", from: hour(3), to: hour(4), expected: []Range{{hour(3), hour(3)}}},
+		// the kline started at 24:00 is still open.
+		{name: "open kline", from: hour(22), to: hour(26), expected: []Range{{hour(22), hour(23)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gaps, err := d.gaps("60", tt.from, tt.to, func(start time.Time) bool {
+				return present[int(start.Sub(hour(0))/time.Hour)]
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(gaps, tt.expected) {
+				t.Fatalf("expected %v got %v", tt.expected, gaps)
+			}
+		})
+	}
+}
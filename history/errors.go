@@ -0,0 +1,12 @@
+package history
+
+import "errors"
+
+var (
+	ErrorInvalidRange = errors.New("invalid time range")
+	ErrorCorruptStore = errors.New("corrupt kline store")
+
+	ErrorUnsortedCandles   = errors.New("candles not sorted by start time")
+	ErrorMisalignedCandles = errors.New("candles not aligned to their interval")
+	ErrorNotMultiple       = errors.New("interval not a multiple of the candles interval")
+)
@@ -0,0 +1,84 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+// Align truncates t to the start of the kline of interval containing it.
+// Weekly klines start on Monday and monthly ones on the first day of the
+// month, in UTC.
+func Align(interval string, t time.Time) (time.Time, error) {
+	t = t.UTC()
+	switch interval {
+	case bybitHttp.IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	case bybitHttp.IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+
+		return day.AddDate(0, 0, -offset), nil
+	}
+
+	d, err := bybitHttp.IntervalDuration(interval)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.Truncate(d), nil
+}
+
+// Next start of the kline following the one started at start.
+func Next(interval string, start time.Time) (time.Time, error) {
+	if interval == bybitHttp.IntervalMonth {
+		return start.AddDate(0, 1, 0), nil
+	}
+
+	d, err := bybitHttp.IntervalDuration(interval)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return start.Add(d), nil
+}
+
+// period of candles of any interval, bybit ones or durations like 2m or 10s.
+type period struct {
+	align func(t time.Time) time.Time
+	next  func(start time.Time) time.Time
+	// duration of the candles, zero for months.
+	duration time.Duration
+}
+
+func parsePeriod(interval string) (period, error) {
+	if _, err := Align(interval, time.Time{}); err == nil {
+		d, _ := bybitHttp.IntervalDuration(interval)
+
+		return period{
+			duration: d,
+			align: func(t time.Time) time.Time {
+				start, _ := Align(interval, t)
+
+				return start
+			},
+			next: func(start time.Time) time.Time {
+				next, _ := Next(interval, start)
+
+				return next
+			},
+		}, nil
+	}
+
+	d, err := time.ParseDuration(interval)
+	if err != nil || d <= 0 {
+		return period{}, fmt.Errorf("%w: %q", bybitHttp.ErrorInvalidInterval, interval)
+	}
+
+	return period{
+		align:    func(t time.Time) time.Time { return t.UTC().Truncate(d) },
+		next:     func(start time.Time) time.Time { return start.Add(d) },
+		duration: d,
+	}, nil
+}
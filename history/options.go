@@ -0,0 +1,27 @@
+package history
+
+import (
+	"log/slog"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+// Option allows to customize the Downloader created with NewDownloader
+type Option func(*Downloader)
+
+// WithLogger replace the default logger, a nil logger silence the downloader
+func WithLogger(logger *slog.Logger) Option {
+	return func(d *Downloader) {
+		d.logger = logging.Redact(logger)
+	}
+}
+
+// WithPageSize sets the number of klines requested per page, at most and
+// by default MaxKlineLimit.
+func WithPageSize(size int) Option {
+	return func(d *Downloader) {
+		if size > 0 && size <= MaxKlineLimit {
+			d.pageSize = size
+		}
+	}
+}
@@ -0,0 +1,337 @@
+// Package history downloads historical klines from bybit, keeps them in a
+// local store that can be updated incrementally and aggregates trades and
+// klines into candles of any interval.
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+var csvHeader = []string{"start", "open", "high", "low", "close", "volume", "turnover"}
+
+// Range of kline start times, both inclusive
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Contains reports whether t is inside the range
+func (r Range) Contains(t time.Time) bool {
+	return !t.Before(r.From) && !t.After(r.To)
+}
+
+// extend the range to contain t, a zero range becomes [t, t]
+func (r Range) extend(t time.Time) Range {
+	if r.From.IsZero() || t.Before(r.From) {
+		r.From = t
+	}
+
+	if r.To.IsZero() || t.After(r.To) {
+		r.To = t
+	}
+
+	return r
+}
+
+// Index summary of the klines stored for a symbol and interval
+type Index struct {
+	Category string    `json:"category"`
+	Symbol   string    `json:"symbol"`
+	Interval string    `json:"interval"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	Count    int       `json:"count"`
+	// Missing ranges without klines in bybit, e.g. before the listing of
+	// the symbol or during maintenances, they aren't requested again.
+	Missing   []Range   `json:"missing"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// missing reports whether t is known to be missing in bybit
+func (i *Index) missing(t time.Time) bool {
+	for _, r := range i.Missing {
+		if r.Contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Store keeps the klines of each category, symbol and interval in a CSV
+// file sorted by start time, next to a JSON index, under dir. It's safe
+// for concurrent use within a process.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore creates a store in dir, created on the first write
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(category, symbol, interval, ext string) string {
+	return filepath.Join(s.dir, category, symbol, interval+ext)
+}
+
+// Load retrieves every stored kline sorted by start time, nil when there
+// are none.
+func (s *Store) Load(category, symbol, interval string) ([]bybitHttp.Kline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(category, symbol, interval)
+}
+
+// LoadRange retrieves the stored klines started within r
+func (s *Store) LoadRange(category, symbol, interval string, r Range) ([]bybitHttp.Kline, error) {
+	klines, err := s.Load(category, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]bybitHttp.Kline, 0)
+	for _, kline := range klines {
+		start, err := klineStart(kline)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.Contains(start) {
+			result = append(result, kline)
+		}
+	}
+
+	return result, nil
+}
+
+// Index retrieves the index of the klines, empty when there are none
+func (s *Store) Index(category, symbol, interval string) (*Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.index(category, symbol, interval)
+}
+
+// Merge adds klines to the stored ones, replacing those with the same
+// start time, and records the missing ranges in the index.
+func (s *Store) Merge(category, symbol, interval string, klines []bybitHttp.Kline, missing []Range) (*Index, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, err := s.load(category, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := s.index(category, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	byStart := make(map[int64]bybitHttp.Kline, len(stored)+len(klines))
+	for _, kline := range append(stored, klines...) {
+		candle, err := kline.Candle()
+		if err != nil {
+			return nil, err
+		}
+
+		byStart[candle.Start.UnixMilli()] = kline[:len(csvHeader)]
+	}
+
+	starts := make([]int64, 0, len(byStart))
+	for start := range byStart {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	merged := make([]bybitHttp.Kline, 0, len(starts))
+	for _, start := range starts {
+		merged = append(merged, byStart[start])
+	}
+
+	err = s.write(category, symbol, interval, merged)
+	if err != nil {
+		return nil, err
+	}
+
+	index.Count = len(merged)
+	if len(starts) > 0 {
+		index.First = time.UnixMilli(starts[0]).UTC()
+		index.Last = time.UnixMilli(starts[len(starts)-1]).UTC()
+	}
+	index.Missing = mergeRanges(append(index.Missing, missing...))
+	index.UpdatedAt = time.Now().UTC()
+
+	err = s.writeIndex(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+func (s *Store) load(category, symbol, interval string) ([]bybitHttp.Kline, error) {
+	file, err := os.Open(s.path(category, symbol, interval, ".csv"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(csvHeader)
+
+	_, err = reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorCorruptStore, err)
+	}
+
+	klines := make([]bybitHttp.Kline, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrorCorruptStore, err)
+		}
+
+		klines = append(klines, bybitHttp.Kline(record))
+	}
+
+	return klines, nil
+}
+
+func (s *Store) index(category, symbol, interval string) (*Index, error) {
+	index := &Index{Category: category, Symbol: symbol, Interval: interval}
+
+	data, err := os.ReadFile(s.path(category, symbol, interval, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, index)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorCorruptStore, err)
+	}
+
+	return index, nil
+}
+
+// write replaces the klines atomically, a failed write keeps the previous file.
+func (s *Store) write(category, symbol, interval string, klines []bybitHttp.Kline) error {
+	return writeFile(s.path(category, symbol, interval, ".csv"), func(w io.Writer) error {
+		writer := csv.NewWriter(w)
+		_ = writer.Write(csvHeader)
+		for _, kline := range klines {
+			_ = writer.Write(kline)
+		}
+		writer.Flush()
+
+		return writer.Error()
+	})
+}
+
+func (s *Store) writeIndex(index *Index) error {
+	return writeFile(s.path(index.Category, index.Symbol, index.Interval, ".json"), func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(index)
+	})
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func klineStart(kline bybitHttp.Kline) (time.Time, error) {
+	if len(kline) == 0 {
+		return time.Time{}, fmt.Errorf("%w: %v", bybitHttp.ErrorInvalidKline, kline)
+	}
+
+	start, err := strconv.ParseInt(kline[0], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: start %q", bybitHttp.ErrorInvalidKline, kline[0])
+	}
+
+	return time.UnixMilli(start).UTC(), nil
+}
+
+// bounded keeps the ranges strictly inside bounds.
+func bounded(ranges []Range, bounds Range) []Range {
+	result := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		if r.From.After(bounds.From) && r.To.Before(bounds.To) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// mergeRanges sorts ranges merging the overlapping ones.
+func mergeRanges(ranges []Range) []Range {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From.Before(ranges[j].From) })
+
+	merged := make([]Range, 0, len(ranges))
+	for _, r := range ranges {
+		last := len(merged) - 1
+		if last >= 0 && !r.From.After(merged[last].To) {
+			if r.To.After(merged[last].To) {
+				merged[last].To = r.To
+			}
+
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged
+}
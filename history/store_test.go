@@ -0,0 +1,76 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func hour(h int) time.Time {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(h) * time.Hour)
+}
+
+func TestMergeRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranges   []Range
+		expected []Range
+	}{
+		{name: "empty", ranges: []Range{}, expected: []Range{}},
+		{name: "disjoint", ranges: []Range{{hour(0), hour(1)}, {hour(3), hour(4)}}, expected: []Range{{hour(0), hour(1)}, {hour(3), hour(4)}}},
+		{name: "unsorted", ranges: []Range{{hour(3), hour(4)}, {hour(0), hour(1)}}, expected: []Range{{hour(0), hour(1)}, {hour(3), hour(4)}}},
+		{name: "overlapping", ranges: []Range{{hour(0), hour(2)}, {hour(1), hour(4)}}, expected: []Range{{hour(0), hour(4)}}},
+		{name: "touching", ranges: []Range{{hour(0), hour(2)}, {hour(2), hour(3)}}, expected: []Range{{hour(0), hour(3)}}},
+		{name: "contained", ranges: []Range{{hour(0), hour(5)}, {hour(1), hour(2)}, {hour(6), hour(6)}}, expected: []Range{{hour(0), hour(5)}, {hour(6), hour(6)}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergeRanges(tt.ranges)
+			if !reflect.DeepEqual(merged, tt.expected) {
+				t.Fatalf("expected %v got %v", tt.expected, merged)
+			}
+		})
+	}
+}
+
+func TestAlign(t *testing.T) {
+	tests := []struct {
+		interval string
+		t        time.Time
+		start    time.Time
+		next     time.Time
+	}{
+		{interval: "60", t: time.Date(2024, 1, 3, 10, 35, 0, 0, time.UTC), start: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC)},
+		{interval: "D", t: time.Date(2024, 1, 3, 10, 35, 0, 0, time.UTC), start: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+		// weeks start on Monday, 2024-01-01 was a Monday.
+		{interval: "W", t: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{interval: "W", t: time.Date(2024, 1, 7, 23, 0, 0, 0, time.UTC), start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{interval: "W", t: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{interval: "M", t: time.Date(2024, 2, 29, 15, 0, 0, 0, time.UTC), start: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{interval: "M", t: time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC), start: time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// times in other locations are aligned in UTC.
+		{interval: "D", t: time.Date(2024, 1, 3, 1, 0, 0, 0, time.FixedZone("UTC+3", 3*3600)), start: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), next: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		start, err := Align(tt.interval, tt.t)
+		if err != nil {
+			t.Fatalf("aligning %s: %v", tt.interval, err)
+		}
+
+		next, err := Next(tt.interval, start)
+		if err != nil {
+			t.Fatalf("next %s: %v", tt.interval, err)
+		}
+
+		if !start.Equal(tt.start) || !next.Equal(tt.next) {
+			t.Fatalf("%s of %s expected %s-%s got %s-%s", tt.interval, tt.t, tt.start, tt.next, start, next)
+		}
+	}
+
+	_, err := Align("7", time.Now())
+	if err == nil {
+		t.Fatal("expected an invalid interval error")
+	}
+}
@@ -10,15 +10,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Gealber/bybit/config"
+	"github.com/Gealber/bybit/logging"
 	query "github.com/google/go-querystring/query"
 	"github.com/google/uuid"
 	"golang.org/x/sync/errgroup"
@@ -26,18 +26,52 @@ import (
 
 // Client represents connection with ByBit REST API.
 type Client struct {
-	APIKey     string
-	APISecret  string
-	HttpClient http.Client
-	BaseURL    string
-	logger     *log.Logger
+	APIKey      string
+	APISecret   string
+	HttpClient  http.Client
+	BaseURL     string
+	logger      *slog.Logger
+	preflight   *KeyRequirements
+	middlewares []Middleware
+	ctx         context.Context
+	priceSource PriceSource
+	sender      OrderSender
+	exchange    Exchange
 }
 
-// New create a new instance of a client
-func New(cfg *config.AppConfig) (*Client, error) {
-	httpClient := http.Client{}
+// OrderSender sends orders to the exchange, implemented by Client and by
+// the websocket TradeClient.
+type OrderSender interface {
+	PlaceOrder(order OrderRequest) (*OrderResponse, error)
+	AmendOrder(amend AmendRequest) (*OrderResponse, error)
+	CancelOrder(cancel CancelRequest) (*OrderResponse, error)
+	PlaceBatchOrders(batch BatchOrderRequest) ([]*BatchOrderItem, error)
+	AmendBatchOrders(batch BatchAmendRequest) ([]*BatchOrderItem, error)
+	CancelBatchOrders(batch BatchCancelRequest) ([]*BatchOrderItem, error)
+}
+
+// Exchange operations used by trading strategies, implemented by Client
+// and by simulators to run the strategies in paper mode.
+type Exchange interface {
+	OrderSender
+	OpenOrders(queryParams any) ([]*Order, error)
+	GetWalletBalance(queryParams WalletBalanceParams) (*WalletBalanceResult, error)
+	GetPositionInfo(queryParams PositionParams) ([]*Position, error)
+	GetTickers(queryParams TickerParams) ([]*Ticker, error)
+}
 
-	bybitLoggerHTTP := log.New(os.Stdout, "[bybit-http]", log.Lshortfile)
+// PriceSource provides the latest price of a symbol e.g. a local order book
+// kept with websocket. BestPrice returns the best bid when side is Sell and
+// the best ask when side is Buy, false when the price is unknown.
+type PriceSource interface {
+	BestPrice(side, symbol string) (float64, bool)
+}
+
+// New create a new instance of a client, opts allow to customize it.
+// In case a preflight check was requested with WithPreflight the api key
+// is validated before returning the client.
+func New(cfg *config.AppConfig, opts ...ClientOption) (*Client, error) {
+	httpClient := http.Client{}
 
 	if cfg.ByBit.APIKey == "" {
 		return nil, errors.New("empty api key in env checkout environment variable BYBIT_API_KEY")
@@ -47,28 +81,81 @@ func New(cfg *config.AppConfig) (*Client, error) {
 		return nil, errors.New("empty api secret in env checkout environment variable BYBIT_API_SECRET")
 	}
 
-	return &Client{
+	client := &Client{
 		APIKey:     cfg.ByBit.APIKey,
 		APISecret:  cfg.ByBit.APISecret,
 		HttpClient: httpClient,
 		BaseURL:    cfg.ByBit.BaseURL,
-		logger:     bybitLoggerHTTP,
-	}, nil
+		logger:     logging.Default("bybit-http"),
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	if client.preflight != nil {
+		_, err := client.CheckAPIKey(*client.preflight)
+		if err != nil {
+			return nil, fmt.Errorf("preflight check: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// WithContext returns a shallow copy of the client whose requests carry ctx,
+// allowing cancellation and propagation of values like tracing spans.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	client := *c
+	client.ctx = ctx
+
+	return &client
+}
+
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
 }
 
 // Do performs http request according to the req provided
-// the response is stored in the pointer to a struct 'objResp'
+// the response is stored in the pointer to a struct 'objResp'.
+// The request goes through the middleware chain of the client.
 func (c *Client) Do(
 	req *http.Request,
 	objResp interface{},
 ) error {
-	response, err := c.HttpClient.Do(req)
+	call := &Call{
+		Endpoint:  endpointName(req.URL.Path),
+		RequestID: logging.RequestID(req.Context()),
+		Request:   req,
+	}
+
+	return c.invoker()(call, objResp)
+}
+
+func (c *Client) do(call *Call, objResp any) error {
+	start := time.Now()
+	defer func() {
+		call.Latency = time.Since(start)
+	}()
+
+	response, err := c.HttpClient.Do(call.Request)
 	if err != nil {
+		c.logger.Error("request failed", logging.RequestIDKey, call.RequestID, "error", err)
+
 		return err
 	}
 
 	defer response.Body.Close()
 
+	call.StatusCode = response.StatusCode
+	call.Header = response.Header
+
+	c.logger.Debug("response", logging.RequestIDKey, call.RequestID, "status", response.StatusCode)
+
 	if response.StatusCode != http.StatusOK {
 		return ErrorUnexpectedStatus
 	}
@@ -78,6 +165,11 @@ func (c *Client) Do(
 		return err
 	}
 
+	err = json.Unmarshal(b, &call.Envelope)
+	if err != nil {
+		return err
+	}
+
 	return json.Unmarshal(b, objResp)
 }
 
@@ -106,8 +198,9 @@ func (c *Client) NewRequest(
 	timestamp := time.Now().UTC().UnixMilli()
 	sign := c.genSignHash(timestamp, queries.Encode())
 	url := c.buildURL(path, queries)
+	requestID := logging.NewRequestID()
 
-	c.logger.Println("URL: ", url)
+	c.logger.Debug("request", logging.RequestIDKey, requestID, "method", method, "url", url)
 
 	if objBody != nil {
 		data, err := json.Marshal(objBody)
@@ -119,7 +212,9 @@ func (c *Client) NewRequest(
 		bodyReader = bytes.NewBuffer(data)
 	}
 
-	request, err := http.NewRequest(method, url, bodyReader)
+	ctx := logging.ContextWithRequestID(c.context(), requestID)
+
+	request, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return nil, err
 	}
@@ -178,6 +273,53 @@ func (c *Client) CancelOrder(cancel CancelRequest) (*OrderResponse, error) {
 	return response.Result, nil
 }
 
+// AmendOrder modify an open order in the exchange
+func (c *Client) AmendOrder(amend AmendRequest) (*OrderResponse, error) {
+	path := "order/amend"
+
+	request, err := c.NewRequest(http.MethodPost, path, nil, &amend)
+	if err != nil {
+		return nil, err
+	}
+
+	var response AmendOrderResponse
+
+	err = c.Do(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
+	return response.Result, nil
+}
+
+// PlaceBatchOrders place several orders in the exchange with a single request,
+// check the Code of each item to know if the order was placed
+func (c *Client) PlaceBatchOrders(batch BatchOrderRequest) ([]*BatchOrderItem, error) {
+	path := "order/create-batch"
+
+	return c.batchRequest(path, &batch)
+}
+
+// AmendBatchOrders modify several open orders with a single request,
+// check the Code of each item to know if the order was modified
+func (c *Client) AmendBatchOrders(batch BatchAmendRequest) ([]*BatchOrderItem, error) {
+	path := "order/amend-batch"
+
+	return c.batchRequest(path, &batch)
+}
+
+// CancelBatchOrders cancel several orders with a single request,
+// check the Code of each item to know if the order was cancelled
+func (c *Client) CancelBatchOrders(batch BatchCancelRequest) ([]*BatchOrderItem, error) {
+	path := "order/cancel-batch"
+
+	return c.batchRequest(path, &batch)
+}
+
 // OrderHistory retrieve the order history
 func (c *Client) OrderHistory(queryParams HistoryParams) ([]*Order, error) {
 	path := "order/history"
@@ -255,7 +397,11 @@ func (c *Client) GetKline(queryParams KlineParams) ([]Kline, error) {
 		return nil, err
 	}
 
-	return response.Result.List, err
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
+	return response.Result.List, nil
 }
 
 // GetOrderBook retrieve order book
@@ -314,9 +460,50 @@ func (c *Client) GetAPIKeyInformation() (*APIKeyInformationListResponse, error)
 		return nil, err
 	}
 
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
 	return response.Result, err
 }
 
+// CreateSubAPIKey create a new api key for a sub account. Only the master
+// account api key is allowed to perform this operation.
+func (c *Client) CreateSubAPIKey(create CreateSubAPIKeyRequest) (*APIKeyInformationListResponse, error) {
+	path := "user/create-sub-api"
+
+	return c.apiKeyRequest(path, &create)
+}
+
+// ModifyAPIKey modify the settings of the master api key used to sign the request
+func (c *Client) ModifyAPIKey(modify ModifyAPIKeyRequest) (*APIKeyInformationListResponse, error) {
+	path := "user/update-api"
+
+	return c.apiKeyRequest(path, &modify)
+}
+
+// ModifySubAPIKey modify the settings of a sub account api key
+func (c *Client) ModifySubAPIKey(modify ModifySubAPIKeyRequest) (*APIKeyInformationListResponse, error) {
+	path := "user/update-sub-api"
+
+	return c.apiKeyRequest(path, &modify)
+}
+
+// DeleteAPIKey delete the master api key used to sign the request.
+// Once deleted the client is not able to perform any further request.
+func (c *Client) DeleteAPIKey() error {
+	path := "user/delete-api"
+
+	return c.deleteAPIKey(path, &struct{}{})
+}
+
+// DeleteSubAPIKey delete a sub account api key
+func (c *Client) DeleteSubAPIKey(deleteReq DeleteSubAPIKeyRequest) error {
+	path := "user/delete-sub-api"
+
+	return c.deleteAPIKey(path, &deleteReq)
+}
+
 // GetTransferableCoins retreive transferable coins
 func (c *Client) GetTransferableCoins(query TransferableCoinsListParams) (*TransferableCoinsList, error) {
 	path := "asset/transfer/query-transfer-coin-list"
@@ -405,6 +592,28 @@ func (c *Client) GetWalletBalance(queryParams WalletBalanceParams) (*WalletBalan
 	return response.Result, err
 }
 
+// GetPositionInfo retrieve the positions of a category
+func (c *Client) GetPositionInfo(queryParams PositionParams) ([]*Position, error) {
+	path := "position/list"
+
+	request, err := c.NewRequest(http.MethodGet, path, queryParams, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response PositionListResponse
+	err = c.Do(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
+	return response.Result.List, nil
+}
+
 // BorrowHistory retrieve the borrowed history
 func (c *Client) BorrowHistory(queryParams BorrowHistoryParams) ([]*Borrow, error) {
 	path := "account/borrow-history"
@@ -463,17 +672,19 @@ func (c *Client) PlaceCascadeOrders(side, coin string, priceStep, coinQty float6
 
 	orders := c.prepareCascadeOrders(side, coin, coinQty, currentPrice, priceStep)
 
+	sender := c.orderSender()
+
 	// perform cascade orders in goroutines.
 	errsGroup, _ := errgroup.WithContext(context.Background())
 	for _, order := range orders {
 		orderReq := order
 		errsGroup.Go(func() error {
-			resp, err := c.PlaceOrder(orderReq)
+			resp, err := sender.PlaceOrder(orderReq)
 			if err != nil {
 				return err
 			}
 
-			c.logger.Printf("ORDER: %+v\n", resp)
+			c.logger.Debug("order placed", "orderId", resp.OrderId, "orderLinkId", resp.OrderLinkId)
 
 			return nil
 		})
@@ -482,6 +693,24 @@ func (c *Client) PlaceCascadeOrders(side, coin string, priceStep, coinQty float6
 	return errsGroup.Wait()
 }
 
+func (c *Client) orderSender() OrderSender {
+	if c.sender == nil {
+		return c.trading()
+	}
+
+	return c.sender
+}
+
+// trading retrieve the exchange used by the strategies, the client itself
+// unless WithExchange was used.
+func (c *Client) trading() Exchange {
+	if c.exchange == nil {
+		return c
+	}
+
+	return c.exchange
+}
+
 func (c *Client) prepareCascadeOrders(side, coin string, quantity, startPrice, priceStep float64) []OrderRequest {
 	remaining := quantity
 	orderSize := quantity / DeafaultPlaceOrdersQty
@@ -522,7 +751,7 @@ func (c *Client) getCoinUSDTEquity(coin string) (float64, float64, error) {
 		Coin:        coin,
 	}
 
-	balanceInfo, err := c.GetWalletBalance(queryParams)
+	balanceInfo, err := c.trading().GetWalletBalance(queryParams)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -554,12 +783,20 @@ func (c *Client) getCoinUSDTEquity(coin string) (float64, float64, error) {
 }
 
 func (c *Client) getLatestOrderBookPrice(side, coin string) (float64, error) {
+	symbol := fmt.Sprintf("%sUSDT", coin)
+
+	if c.priceSource != nil {
+		if price, ok := c.priceSource.BestPrice(side, symbol); ok {
+			return price, nil
+		}
+	}
+
 	tickersParams := TickerParams{
 		Category: SpotCategory,
-		Symbol:   fmt.Sprintf("%sUSDT", coin),
+		Symbol:   symbol,
 	}
 
-	tickers, err := c.GetTickers(tickersParams)
+	tickers, err := c.trading().GetTickers(tickersParams)
 	if err != nil {
 		return 0, err
 	}
@@ -587,6 +824,63 @@ func (c *Client) getLatestOrderBookPrice(side, coin string) (float64, error) {
 	return currentPrice, nil
 }
 
+func (c *Client) batchRequest(path string, objBody any) ([]*BatchOrderItem, error) {
+	request, err := c.NewRequest(http.MethodPost, path, nil, objBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var response BatchOrderResponse
+	err = c.Do(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
+	return MergeBatchResult(response.Result, response.RetExtInfo), nil
+}
+
+func (c *Client) apiKeyRequest(path string, objBody any) (*APIKeyInformationListResponse, error) {
+	request, err := c.NewRequest(http.MethodPost, path, nil, objBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var response APIKeyInformationResponse
+	err = c.Do(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.RetCode != RetCodeOK {
+		return nil, errors.New(response.RetMsg)
+	}
+
+	return response.Result, nil
+}
+
+func (c *Client) deleteAPIKey(path string, objBody any) error {
+	request, err := c.NewRequest(http.MethodPost, path, nil, objBody)
+	if err != nil {
+		return err
+	}
+
+	var response DeleteAPIKeyResponse
+	err = c.Do(request, &response)
+	if err != nil {
+		return err
+	}
+
+	if response.RetCode != RetCodeOK {
+		return errors.New(response.RetMsg)
+	}
+
+	return nil
+}
+
 func (c *Client) genSignHash(timestamp int64, payload string) string {
 	h := hmac.New(sha256.New, []byte(c.APISecret))
 
@@ -602,3 +896,5 @@ func (c *Client) buildURL(path string, queryValues url.Values) string {
 
 	return fmt.Sprintf("%s?%s", urlPath, queryValues.Encode())
 }
+
+var _ Exchange = (*Client)(nil)
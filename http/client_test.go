@@ -0,0 +1,259 @@
+package http_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Gealber/bybit/bybittest"
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+func newTestClient(t *testing.T) (*bybitHttp.Client, *bybittest.Server) {
+	t.Helper()
+
+	server := bybittest.NewServer("test-key", "test-secret")
+	t.Cleanup(server.Close)
+
+	client, err := bybitHttp.New(server.Config())
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	return client, server
+}
+
+func TestSignedRequests(t *testing.T) {
+	client, server := newTestClient(t)
+
+	server.SetFixture("market/tickers", map[string]any{
+		"list": []bybitHttp.Ticker{{Symbol: "BTCUSDT", Bid1Price: "100", Ask1Price: "101"}},
+	})
+
+	tickers, err := client.GetTickers(bybitHttp.TickerParams{Category: "spot", Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatalf("GetTickers: %v", err)
+	}
+
+	if len(tickers) != 1 || tickers[0].Symbol != "BTCUSDT" || tickers[0].Ask1Price != "101" {
+		t.Fatalf("unexpected tickers %+v", tickers)
+	}
+
+	order, err := client.PlaceOrder(bybitHttp.OrderRequest{
+		Category:    "spot",
+		Symbol:      "BTCUSDT",
+		Side:        bybitHttp.BuyDirection,
+		OrderType:   "Limit",
+		Qty:         "1",
+		OrderLinkId: "link-1",
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	if order.OrderLinkId != "link-1" || order.OrderId == "" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests got %d", len(requests))
+	}
+
+	if requests[0].Query.Get("symbol") != "BTCUSDT" || requests[1].Endpoint != "order/create" {
+		t.Fatalf("unexpected requests %+v %+v", requests[0], requests[1])
+	}
+}
+
+func TestInvalidSign(t *testing.T) {
+	server := bybittest.NewServer("test-key", "test-secret")
+	t.Cleanup(server.Close)
+
+	cfg := server.Config()
+	cfg.ByBit.APISecret = "wrong-secret"
+
+	client, err := bybitHttp.New(cfg)
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	_, err = client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+	if err == nil || !strings.Contains(err.Error(), "error sign") {
+		t.Fatalf("expected sign error got %v", err)
+	}
+}
+
+func TestRetCodeErrors(t *testing.T) {
+	client, server := newTestClient(t)
+
+	server.SetError("order/cancel", 110001, "order not exists or too late to cancel")
+
+	_, err := client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+	if err == nil || !strings.Contains(err.Error(), "order not exists") {
+		t.Fatalf("expected retCode error got %v", err)
+	}
+
+	server.ClearError("order/cancel")
+
+	_, err = client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+	if err != nil {
+		t.Fatalf("CancelOrder after ClearError: %v", err)
+	}
+
+	// an error page of klines isn't an empty page.
+	server.SetError("market/kline", 10001, "Not supported symbols.")
+
+	klines, err := client.GetKline(bybitHttp.KlineParams{Category: "spot", Symbol: "BTCUSDX", Interval: "1"})
+	if err == nil || !strings.Contains(err.Error(), "Not supported symbols") {
+		t.Fatalf("expected retCode error got %v %v", klines, err)
+	}
+}
+
+func TestSharedResponseFixture(t *testing.T) {
+	client, server := newTestClient(t)
+
+	// the same response served concurrently gets its defaults filled in a copy.
+	response := &bybittest.Response{RetCode: bybitHttp.RetCodeOK, RetMsg: "OK"}
+	server.SetFixture("order/cancel", response)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+			if err != nil {
+				t.Errorf("CancelOrder: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if response.Result != nil || response.Time != 0 {
+		t.Fatalf("fixture response modified %+v", response)
+	}
+}
+
+// TestFixtures calls every endpoint of the client against the default
+// fixtures of the server.
+func TestFixtures(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	batch := []bybitHttp.OrderRequest{{Symbol: "BTCUSDT", OrderLinkId: "a"}, {Symbol: "BTCUSDT", OrderLinkId: "b"}}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"PlaceOrder", func() error {
+			_, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+			return err
+		}},
+		{"AmendOrder", func() error {
+			_, err := client.AmendOrder(bybitHttp.AmendRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+			return err
+		}},
+		{"CancelOrder", func() error {
+			_, err := client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+			return err
+		}},
+		{"PlaceBatchOrders", func() error {
+			items, err := client.PlaceBatchOrders(bybitHttp.BatchOrderRequest{Category: "spot", Request: batch})
+			if err == nil && len(items) != len(batch) {
+				t.Errorf("expected %d items got %d", len(batch), len(items))
+			}
+			return err
+		}},
+		{"AmendBatchOrders", func() error {
+			_, err := client.AmendBatchOrders(bybitHttp.BatchAmendRequest{Category: "spot"})
+			return err
+		}},
+		{"CancelBatchOrders", func() error {
+			_, err := client.CancelBatchOrders(bybitHttp.BatchCancelRequest{Category: "spot"})
+			return err
+		}},
+		{"OrderHistory", func() error {
+			_, err := client.OrderHistory(bybitHttp.HistoryParams{Category: "spot"})
+			return err
+		}},
+		{"OpenOrders", func() error {
+			_, err := client.OpenOrders(bybitHttp.HistoryParams{Category: "spot"})
+			return err
+		}},
+		{"GetTickers", func() error {
+			_, err := client.GetTickers(bybitHttp.TickerParams{Category: "spot"})
+			return err
+		}},
+		{"GetKline", func() error {
+			_, err := client.GetKline(bybitHttp.KlineParams{Category: "spot", Symbol: "BTCUSDT", Interval: "1"})
+			return err
+		}},
+		{"GetOrderBook", func() error {
+			book, err := client.GetOrderBook(bybitHttp.OrderBookParams{Category: "spot", Symbol: "BTCUSDT"})
+			if err == nil && book.Symbol != "BTCUSDT" {
+				t.Errorf("unexpected book %+v", book)
+			}
+			return err
+		}},
+		{"Withdraw", func() error {
+			_, err := client.Withdraw(bybitHttp.WithdrawRequest{Coin: "USDT"})
+			return err
+		}},
+		{"GetAPIKeyInformation", func() error {
+			info, err := client.GetAPIKeyInformation()
+			if err == nil && info.APIKey != "test-key" {
+				t.Errorf("unexpected key %+v", info)
+			}
+			return err
+		}},
+		{"CreateSubAPIKey", func() error {
+			_, err := client.CreateSubAPIKey(bybitHttp.CreateSubAPIKeyRequest{Subuid: 1})
+			return err
+		}},
+		{"ModifyAPIKey", func() error {
+			_, err := client.ModifyAPIKey(bybitHttp.ModifyAPIKeyRequest{})
+			return err
+		}},
+		{"ModifySubAPIKey", func() error {
+			_, err := client.ModifySubAPIKey(bybitHttp.ModifySubAPIKeyRequest{})
+			return err
+		}},
+		{"DeleteAPIKey", client.DeleteAPIKey},
+		{"DeleteSubAPIKey", func() error {
+			return client.DeleteSubAPIKey(bybitHttp.DeleteSubAPIKeyRequest{})
+		}},
+		{"GetTransferableCoins", func() error {
+			_, err := client.GetTransferableCoins(bybitHttp.TransferableCoinsListParams{FromAccountType: "UNIFIED", ToAccountType: "FUND"})
+			return err
+		}},
+		{"CreateInternalTransfer", func() error {
+			id, err := client.CreateInternalTransfer(bybitHttp.TransferRequest{Coin: "USDT"})
+			if err == nil && id == "" {
+				t.Error("empty transfer id")
+			}
+			return err
+		}},
+		{"GetWalletBalance", func() error {
+			_, err := client.GetWalletBalance(bybitHttp.WalletBalanceParams{AccountType: "UNIFIED"})
+			return err
+		}},
+		{"GetPositionInfo", func() error {
+			_, err := client.GetPositionInfo(bybitHttp.PositionParams{Category: "linear"})
+			return err
+		}},
+		{"BorrowHistory", func() error {
+			_, err := client.BorrowHistory(bybitHttp.BorrowHistoryParams{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		})
+	}
+}
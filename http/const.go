@@ -8,7 +8,8 @@ const (
 )
 
 const (
-	SpotCategory = "spot"
+	SpotCategory   = "spot"
+	LinearCategory = "linear"
 
 	// direction of order.
 	BuyDirection  = "Buy"
@@ -18,6 +19,19 @@ const (
 	MarketOrder = "Market"
 	LimitOrder  = "Limit"
 
+	// order status.
+	OrderStatusNew             = "New"
+	OrderStatusPartiallyFilled = "PartiallyFilled"
+	OrderStatusFilled          = "Filled"
+	OrderStatusCancelled       = "Cancelled"
+	OrderStatusRejected        = "Rejected"
+
+	// time in force.
+	GoodTillCancel    = "GTC"
+	ImmediateOrCancel = "IOC"
+	FillOrKill        = "FOK"
+	PostOnly          = "PostOnly"
+
 	// account types.
 	UnifiedAccount = "UNIFIED"
 	FundingAccount = "FUNDING"
@@ -26,6 +40,23 @@ const (
 	TonUSDTSymbol = "TONUSDT"
 )
 
+// kline intervals.
+const (
+	Interval1Minute  = "1"
+	Interval3Minute  = "3"
+	Interval5Minute  = "5"
+	Interval15Minute = "15"
+	Interval30Minute = "30"
+	Interval1Hour    = "60"
+	Interval2Hour    = "120"
+	Interval4Hour    = "240"
+	Interval6Hour    = "360"
+	Interval12Hour   = "720"
+	IntervalDay      = "D"
+	IntervalWeek     = "W"
+	IntervalMonth    = "M"
+)
+
 const (
 	DeafaultPlaceOrdersQty = 20
 )
@@ -3,7 +3,14 @@ package http
 import "errors"
 
 var (
-	ErrorUnexpectedStatus = errors.New("unexpected http status code")
+	ErrorUnexpectedStatus       = errors.New("unexpected http status code")
 	ErrorUnavailableInformation = errors.New("unavailable information")
-	ErrorInsuficcientBalance = errors.New("insuficcient balance")
+	ErrorInsuficcientBalance    = errors.New("insuficcient balance")
+	ErrorAPIKeyReadOnly         = errors.New("api key is read only")
+	ErrorAPIKeyExpired          = errors.New("api key expired")
+	ErrorMissingPermission      = errors.New("api key missing permission")
+	ErrorIPWhitelistRequired    = errors.New("api key not bound to an ip whitelist")
+	ErrorInvalidKline           = errors.New("invalid kline")
+	// ErrorInvalidInterval unknown kline interval or without fixed duration, like months.
+	ErrorInvalidInterval = errors.New("invalid kline interval")
 )
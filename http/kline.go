@@ -0,0 +1,93 @@
+package http
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var intervalDurations = map[string]time.Duration{
+	Interval1Minute:  time.Minute,
+	Interval3Minute:  3 * time.Minute,
+	Interval5Minute:  5 * time.Minute,
+	Interval15Minute: 15 * time.Minute,
+	Interval30Minute: 30 * time.Minute,
+	Interval1Hour:    time.Hour,
+	Interval2Hour:    2 * time.Hour,
+	Interval4Hour:    4 * time.Hour,
+	Interval6Hour:    6 * time.Hour,
+	Interval12Hour:   12 * time.Hour,
+	IntervalDay:      24 * time.Hour,
+	IntervalWeek:     7 * 24 * time.Hour,
+}
+
+// IntervalDuration duration of a kline interval, IntervalMonth is not
+// supported since months have no fixed duration.
+func IntervalDuration(interval string) (time.Duration, error) {
+	d, ok := intervalDurations[interval]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrorInvalidInterval, interval)
+	}
+
+	return d, nil
+}
+
+// Candle typed kline, prices are in the quote coin, volume in the base coin
+// and turnover in the quote coin.
+type Candle struct {
+	Start    time.Time `json:"start"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume"`
+	Turnover float64   `json:"turnover"`
+}
+
+// Candle parses the kline, made of the start time in milliseconds, open,
+// high, low, close, volume and turnover.
+func (k Kline) Candle() (Candle, error) {
+	if len(k) < 7 {
+		return Candle{}, fmt.Errorf("%w: %v", ErrorInvalidKline, k)
+	}
+
+	start, err := strconv.ParseInt(k[0], 10, 64)
+	if err != nil {
+		return Candle{}, fmt.Errorf("%w: start %q", ErrorInvalidKline, k[0])
+	}
+
+	values := make([]float64, 6)
+	for i := range values {
+		values[i], err = strconv.ParseFloat(k[i+1], 64)
+		if err != nil {
+			return Candle{}, fmt.Errorf("%w: %q", ErrorInvalidKline, k[i+1])
+		}
+	}
+
+	return Candle{
+		Start:    time.UnixMilli(start).UTC(),
+		Open:     values[0],
+		High:     values[1],
+		Low:      values[2],
+		Close:    values[3],
+		Volume:   values[4],
+		Turnover: values[5],
+	}, nil
+}
+
+// Kline formats the candle as returned by GetKline
+func (c Candle) Kline() Kline {
+	format := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	return Kline{
+		strconv.FormatInt(c.Start.UnixMilli(), 10),
+		format(c.Open),
+		format(c.High),
+		format(c.Low),
+		format(c.Close),
+		format(c.Volume),
+		format(c.Turnover),
+	}
+}
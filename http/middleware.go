@@ -0,0 +1,62 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Envelope fields shared by every response of bybit REST API
+type Envelope struct {
+	RetCode int    `json:"retCode"`
+	RetMsg  string `json:"retMsg"`
+	Time    int64  `json:"time"`
+}
+
+// Call describes a single REST call while it goes through the middleware chain.
+// StatusCode, Header, Envelope and Latency are filled once the next Invoker returns.
+type Call struct {
+	// Endpoint name of the call, e.g. "order/create".
+	Endpoint string
+	// RequestID used in the logs of this call.
+	RequestID string
+	// Request signed and ready to be sent.
+	Request    *http.Request
+	StatusCode int
+	Header     http.Header
+	Envelope   Envelope
+	Latency    time.Duration
+}
+
+// Invoker performs the call decoding the response in objResp
+type Invoker func(call *Call, objResp any) error
+
+// Middleware wraps an Invoker allowing to observe or modify every call
+// performed by the Client, e.g. for tracing, metrics or audit logs.
+type Middleware func(next Invoker) Invoker
+
+// Use appends middlewares to the chain, the first middleware is the outermost.
+// It's not safe to call Use while the client is performing requests.
+func (c *Client) Use(middlewares ...Middleware) {
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+func (c *Client) invoker() Invoker {
+	invoke := c.do
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		invoke = c.middlewares[i](invoke)
+	}
+
+	return invoke
+}
+
+// endpointName extracts the endpoint from the path of the request
+// e.g. /v5/order/create turns into order/create.
+func endpointName(path string) string {
+	prefix := "/" + APIVersion + "/"
+	if idx := strings.Index(path, prefix); idx >= 0 {
+		return path[idx+len(prefix):]
+	}
+
+	return strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,114 @@
+package http_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+func newMiddlewareClient(t *testing.T, middlewares ...bybitHttp.Middleware) *bybitHttp.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Bapi-Limit", "10")
+		_, _ = w.Write([]byte(`{"retCode":10001,"retMsg":"params error","result":{},"time":1700000000000}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.AppConfig{}
+	cfg.ByBit.APIKey = "test-key"
+	cfg.ByBit.APISecret = "test-secret"
+	cfg.ByBit.BaseURL = server.URL
+
+	client, err := bybitHttp.New(cfg, bybitHttp.WithMiddleware(middlewares...))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	return client
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	var (
+		order []string
+		calls []*bybitHttp.Call
+	)
+
+	record := func(name string) bybitHttp.Middleware {
+		return func(next bybitHttp.Invoker) bybitHttp.Invoker {
+			return func(call *bybitHttp.Call, objResp any) error {
+				order = append(order, name)
+				err := next(call, objResp)
+				order = append(order, name)
+				calls = append(calls, call)
+
+				return err
+			}
+		}
+	}
+
+	client := newMiddlewareClient(t, record("outer"), record("inner"))
+
+	// the retCode error is reported in the envelope whether or not the endpoint checks it.
+	_, _ = client.GetTickers(bybitHttp.TickerParams{Category: "spot", Symbol: "BTCUSDT"})
+
+	expected := []string{"outer", "inner", "inner", "outer"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected %v got %v", expected, order)
+	}
+
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected %v got %v", expected, order)
+		}
+	}
+
+	call := calls[1]
+	if call != calls[0] {
+		t.Fatal("expected the same call through the chain")
+	}
+
+	if call.Endpoint != "market/tickers" || call.Request.URL.Query().Get("symbol") != "BTCUSDT" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	if call.StatusCode != http.StatusOK || call.Header.Get("X-Bapi-Limit") != "10" || call.Latency <= 0 {
+		t.Fatalf("unexpected response of call %+v", call)
+	}
+
+	if call.Envelope.RetCode != 10001 || call.Envelope.RetMsg != "params error" || call.Envelope.Time != 1700000000000 {
+		t.Fatalf("unexpected envelope %+v", call.Envelope)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	errBlocked := errors.New("blocked")
+
+	client := newMiddlewareClient(t, func(next bybitHttp.Invoker) bybitHttp.Invoker {
+		return func(call *bybitHttp.Call, objResp any) error {
+			return errBlocked
+		}
+	})
+
+	var reached bool
+	client.Use(func(next bybitHttp.Invoker) bybitHttp.Invoker {
+		return func(call *bybitHttp.Call, objResp any) error {
+			reached = true
+
+			return next(call, objResp)
+		}
+	})
+
+	_, err := client.GetTickers(bybitHttp.TickerParams{Category: "spot", Symbol: "BTCUSDT"})
+	if !errors.Is(err, errBlocked) {
+		t.Fatalf("expected %v got %v", errBlocked, err)
+	}
+
+	if reached {
+		t.Fatal("expected inner middleware to be skipped")
+	}
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"log/slog"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+// ClientOption allows to customize the Client created with New
+type ClientOption func(*Client)
+
+// WithPreflight enables a check of the api key information when the client
+// is created. New fails in case the key doesn't satisfy the requirements.
+func WithPreflight(requirements KeyRequirements) ClientOption {
+	return func(c *Client) {
+		c.preflight = &requirements
+	}
+}
+
+// WithLogger replace the default logger, api keys and signatures are redacted
+// from its output. A nil logger silence the client completely.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logging.Redact(logger)
+	}
+}
+
+// WithMiddleware adds middlewares to the chain every request goes through,
+// the first middleware provided is the outermost.
+func WithMiddleware(middlewares ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middlewares = append(c.middlewares, middlewares...)
+	}
+}
+
+// WithPriceSource sets the source of the latest prices used by
+// PlaceCascadeOrders instead of polling tickers over REST.
+func WithPriceSource(source PriceSource) ClientOption {
+	return func(c *Client) {
+		c.priceSource = source
+	}
+}
+
+// WithOrderSender sets the sender used by PlaceCascadeOrders to place the
+// orders, e.g. the websocket TradeClient to reduce latency.
+func WithOrderSender(sender OrderSender) ClientOption {
+	return func(c *Client) {
+		c.sender = sender
+	}
+}
+
+// WithExchange sets the exchange used by PlaceCascadeOrders to query balances
+// and prices and to place the orders, e.g. a simulator to trade in paper mode.
+// WithPriceSource and WithOrderSender take precedence over it.
+func WithExchange(exchange Exchange) ClientOption {
+	return func(c *Client) {
+		c.exchange = exchange
+	}
+}
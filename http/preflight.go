@@ -0,0 +1,115 @@
+package http
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// WalletWithdrawPermission permission in the Wallet group needed by Withdraw.
+	WalletWithdrawPermission = "Withdraw"
+	// noIPBinding is the value returned in Ips when the key is not bound to any IP.
+	noIPBinding = "*"
+)
+
+// KeyRequirements describes what the api key should be allowed to do,
+// used by CheckAPIKey and the preflight check in New.
+type KeyRequirements struct {
+	// Permissions required by the application, grouped in the same way
+	// bybit does, e.g. Spot: []string{"SpotTrade"}.
+	Permissions Permissions
+	// ReadWrite requires the key to not be read only.
+	ReadWrite bool
+	// Withdraw requires the Wallet Withdraw permission and the key to be
+	// bound to an IP whitelist, both are needed by Withdraw.
+	Withdraw bool
+	// ExpiryWarning logs a warning when the key expires within this duration.
+	ExpiryWarning time.Duration
+}
+
+// CheckAPIKey retrieve the api key information and verifies it satisfy the
+// requirements. The information is returned even when the check fails.
+func (c *Client) CheckAPIKey(requirements KeyRequirements) (*APIKeyInformationListResponse, error) {
+	info, err := c.GetAPIKeyInformation()
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, ErrorUnavailableInformation
+	}
+
+	if !info.ExpiredAt.IsZero() {
+		untilExpiration := time.Until(info.ExpiredAt)
+		if untilExpiration <= 0 {
+			return info, fmt.Errorf("%w: at %s", ErrorAPIKeyExpired, info.ExpiredAt)
+		}
+
+		if untilExpiration < requirements.ExpiryWarning {
+			c.logger.Warn("api key about to expire", "expiredAt", info.ExpiredAt)
+		}
+	}
+
+	if requirements.ReadWrite && info.ReadOnly != 0 {
+		return info, ErrorAPIKeyReadOnly
+	}
+
+	required := requirements.Permissions
+	if requirements.Withdraw {
+		required.Wallet = append(append([]string{}, required.Wallet...), WalletWithdrawPermission)
+	}
+
+	granted := &Permissions{}
+	if info.Permissions != nil {
+		granted = info.Permissions
+	}
+	grantedGroups := granted.groups()
+
+	for group, permissions := range required.groups() {
+		for _, permission := range permissions {
+			if !contains(grantedGroups[group], permission) {
+				return info, fmt.Errorf("%w: %s %s", ErrorMissingPermission, group, permission)
+			}
+		}
+	}
+
+	if requirements.Withdraw && !hasIPWhitelist(info.Ips) {
+		return info, ErrorIPWhitelistRequired
+	}
+
+	return info, nil
+}
+
+// groups list the permissions by the name used by bybit for each group.
+func (p *Permissions) groups() map[string][]string {
+	blockTrade := make([]string, 0, len(p.BlockTrade))
+	for _, permission := range p.BlockTrade {
+		blockTrade = append(blockTrade, fmt.Sprint(permission))
+	}
+
+	return map[string][]string{
+		"ContractTrade": p.ContractTrade,
+		"Spot":          p.Spot,
+		"Wallet":        p.Wallet,
+		"Options":       p.Options,
+		"Derivatives":   p.Derivatives,
+		"CopyTrading":   p.CopyTrading,
+		"BlockTrade":    blockTrade,
+		"Exchange":      p.Exchange,
+		"NFT":           p.Nft,
+	}
+}
+
+func hasIPWhitelist(ips []string) bool {
+	return len(ips) > 0 && !contains(ips, noIPBinding)
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
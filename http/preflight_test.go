@@ -0,0 +1,157 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+// keyServer answers user/query-api with info and records the path of every request.
+func keyServer(t *testing.T, info bybitHttp.APIKeyInformationListResponse) (*config.AppConfig, *[]string) {
+	t.Helper()
+
+	paths := new([]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+
+		response := bybitHttp.APIKeyInformationResponse{RetCode: bybitHttp.RetCodeOK, RetMsg: "OK", Result: &info}
+		if r.URL.Path != "/v5/user/query-api" {
+			response.Result = nil
+		}
+
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.AppConfig{}
+	cfg.ByBit.APIKey = "test-key"
+	cfg.ByBit.APISecret = "test-secret"
+	cfg.ByBit.BaseURL = server.URL
+
+	return cfg, paths
+}
+
+func TestPreflight(t *testing.T) {
+	granted := bybitHttp.APIKeyInformationListResponse{
+		Permissions: &bybitHttp.Permissions{Spot: []string{"SpotTrade"}, Wallet: []string{"AccountTransfer"}},
+		Ips:         []string{"*"},
+		ExpiredAt:   time.Now().Add(time.Hour),
+	}
+
+	readOnly := granted
+	readOnly.ReadOnly = 1
+
+	whitelisted := granted
+	whitelisted.Ips = []string{"10.0.0.1"}
+	whitelisted.Permissions = &bybitHttp.Permissions{Wallet: []string{"AccountTransfer", bybitHttp.WalletWithdrawPermission}}
+
+	expired := granted
+	expired.ExpiredAt = time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name         string
+		info         bybitHttp.APIKeyInformationListResponse
+		requirements bybitHttp.KeyRequirements
+		err          error
+	}{
+		{
+			name:         "granted",
+			info:         granted,
+			requirements: bybitHttp.KeyRequirements{ReadWrite: true, Permissions: bybitHttp.Permissions{Spot: []string{"SpotTrade"}}},
+		},
+		{
+			name:         "missing permission",
+			info:         granted,
+			requirements: bybitHttp.KeyRequirements{Permissions: bybitHttp.Permissions{ContractTrade: []string{"Order"}}},
+			err:          bybitHttp.ErrorMissingPermission,
+		},
+		{
+			name:         "read only",
+			info:         readOnly,
+			requirements: bybitHttp.KeyRequirements{ReadWrite: true},
+			err:          bybitHttp.ErrorAPIKeyReadOnly,
+		},
+		{
+			name:         "withdraw without permission",
+			info:         granted,
+			requirements: bybitHttp.KeyRequirements{Withdraw: true},
+			err:          bybitHttp.ErrorMissingPermission,
+		},
+		{
+			name:         "withdraw without whitelist",
+			info:         bybitHttp.APIKeyInformationListResponse{Permissions: whitelisted.Permissions, Ips: []string{"*"}},
+			requirements: bybitHttp.KeyRequirements{Withdraw: true},
+			err:          bybitHttp.ErrorIPWhitelistRequired,
+		},
+		{
+			name:         "withdraw",
+			info:         whitelisted,
+			requirements: bybitHttp.KeyRequirements{Withdraw: true},
+		},
+		{
+			name: "expired",
+			info: expired,
+			err:  bybitHttp.ErrorAPIKeyExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, paths := keyServer(t, tt.info)
+
+			client, err := bybitHttp.New(cfg, bybitHttp.WithPreflight(tt.requirements))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v got %v", tt.err, err)
+			}
+
+			if (err == nil) != (client != nil) {
+				t.Fatalf("unexpected client %v with error %v", client, err)
+			}
+
+			if len(*paths) != 1 || (*paths)[0] != "/v5/user/query-api" {
+				t.Fatalf("unexpected requests %v", *paths)
+			}
+		})
+	}
+}
+
+func TestAPIKeyManagement(t *testing.T) {
+	cfg, paths := keyServer(t, bybitHttp.APIKeyInformationListResponse{APIKey: "sub-key"})
+
+	client, err := bybitHttp.New(cfg)
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	_, err = client.CreateSubAPIKey(bybitHttp.CreateSubAPIKeyRequest{Subuid: 1, Permissions: &bybitHttp.Permissions{}})
+	if err != nil {
+		t.Fatalf("CreateSubAPIKey: %v", err)
+	}
+
+	_, err = client.ModifySubAPIKey(bybitHttp.ModifySubAPIKeyRequest{APIKey: "sub-key", IPs: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("ModifySubAPIKey: %v", err)
+	}
+
+	err = client.DeleteSubAPIKey(bybitHttp.DeleteSubAPIKeyRequest{APIKey: "sub-key"})
+	if err != nil {
+		t.Fatalf("DeleteSubAPIKey: %v", err)
+	}
+
+	expected := []string{"/v5/user/create-sub-api", "/v5/user/update-sub-api", "/v5/user/delete-sub-api"}
+	if len(*paths) != len(expected) {
+		t.Fatalf("expected requests %v got %v", expected, *paths)
+	}
+
+	for i, path := range expected {
+		if (*paths)[i] != path {
+			t.Fatalf("expected requests %v got %v", expected, *paths)
+		}
+	}
+}
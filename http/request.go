@@ -26,15 +26,50 @@ type OrderRequest struct {
 	MMP              bool   `json:"mmp,omitempty"`
 }
 
-// CancelRequest entity for cancelling order
+// CancelRequest entity for cancelling order, category is omitted in batch requests
 type CancelRequest struct {
-	Category    string `json:"category"`
+	Category    string `json:"category,omitempty"`
 	Symbol      string `json:"symbol"`
 	OrderID     string `json:"orderId,omitempty"`
 	OrderLinkId string `json:"orderLinkId,omitempty"`
 	OrderFilter string `json:"orderFilter,omitempty"`
 }
 
+// AmendRequest entity for modifying an open order, category is omitted in batch requests
+type AmendRequest struct {
+	Category     string `json:"category,omitempty"`
+	Symbol       string `json:"symbol"`
+	OrderID      string `json:"orderId,omitempty"`
+	OrderLinkId  string `json:"orderLinkId,omitempty"`
+	OrderIv      string `json:"orderIv,omitempty"`
+	TriggerPrice string `json:"triggerPrice,omitempty"`
+	Qty          string `json:"qty,omitempty"`
+	Price        string `json:"price,omitempty"`
+	TakeProfit   string `json:"takeProfit,omitempty"`
+	StopLoss     string `json:"stopLoss,omitempty"`
+	TpTriggerBy  string `json:"tpTriggerBy,omitempty"`
+	SlTriggerBy  string `json:"slTriggerBy,omitempty"`
+	TriggerBy    string `json:"triggerBy,omitempty"`
+}
+
+// BatchOrderRequest entity for creating several orders of the same category
+type BatchOrderRequest struct {
+	Category string         `json:"category"`
+	Request  []OrderRequest `json:"request"`
+}
+
+// BatchAmendRequest entity for modifying several orders of the same category
+type BatchAmendRequest struct {
+	Category string         `json:"category"`
+	Request  []AmendRequest `json:"request"`
+}
+
+// BatchCancelRequest entity for cancelling several orders of the same category
+type BatchCancelRequest struct {
+	Category string          `json:"category"`
+	Request  []CancelRequest `json:"request"`
+}
+
 // HistoryParams entitity for requesting history of a transaction
 // used in OrderHistory
 type HistoryParams struct {
@@ -110,7 +145,49 @@ type TransferRequest struct {
 	ToAccountType   string `json:"toAccountType"`
 }
 
+// PositionParams entity for requesting the positions of a category,
+// symbol or settleCoin is required by bybit for linear positions
+type PositionParams struct {
+	Category   string `url:"category"`
+	Symbol     string `url:"symbol,omitempty"`
+	BaseCoin   string `url:"baseCoin,omitempty"`
+	SettleCoin string `url:"settleCoin,omitempty"`
+	Limit      int    `url:"limit,omitempty"`
+	Cursor     string `url:"cursor,omitempty"`
+}
+
 type WalletBalanceParams struct {
 	AccountType string `url:"accountType"`
 	Coin        string `url:"coin"`
 }
+
+// CreateSubAPIKeyRequest entity for creating an api key of a sub account
+type CreateSubAPIKeyRequest struct {
+	Subuid      int          `json:"subuid"`
+	Note        string       `json:"note,omitempty"`
+	ReadOnly    int          `json:"readOnly"`
+	IPs         string       `json:"ips,omitempty"`
+	Permissions *Permissions `json:"permissions"`
+}
+
+// ModifyAPIKeyRequest entity for modifying the master api key.
+// IPs is a comma separated list of IPs, "*" means no IP binding.
+type ModifyAPIKeyRequest struct {
+	ReadOnly    *int         `json:"readOnly,omitempty"`
+	IPs         string       `json:"ips,omitempty"`
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// ModifySubAPIKeyRequest entity for modifying an api key of a sub account,
+// when APIKey is empty the key used for signing the request is modified
+type ModifySubAPIKeyRequest struct {
+	APIKey      string       `json:"apikey,omitempty"`
+	ReadOnly    *int         `json:"readOnly,omitempty"`
+	IPs         string       `json:"ips,omitempty"`
+	Permissions *Permissions `json:"permissions,omitempty"`
+}
+
+// DeleteSubAPIKeyRequest entity for deleting an api key of a sub account
+type DeleteSubAPIKeyRequest struct {
+	APIKey string `json:"apikey,omitempty"`
+}
@@ -18,6 +18,60 @@ type OrderBookResponse struct {
 
 type CacelOrderResponse PlaceOrderResponse
 
+type AmendOrderResponse PlaceOrderResponse
+
+type BatchOrderResponse struct {
+	RetCode    int               `json:"retCode"`
+	RetMsg     string            `json:"retMsg"`
+	Result     *BatchOrderResult `json:"result"`
+	RetExtInfo *BatchExtInfo     `json:"retExtInfo"`
+	Time       int64             `json:"time"`
+}
+
+type BatchOrderResult struct {
+	List []*BatchOrderItem `json:"list"`
+}
+
+// BatchOrderItem result of each order in a batch, Code and Msg hold the
+// result of the operation on this order, code 0 means success.
+type BatchOrderItem struct {
+	Category    string `json:"category"`
+	Symbol      string `json:"symbol"`
+	OrderId     string `json:"orderId"`
+	OrderLinkId string `json:"orderLinkId"`
+	CreateAt    string `json:"createAt"`
+	Code        int    `json:"-"`
+	Msg         string `json:"-"`
+}
+
+type BatchExtInfo struct {
+	List []BatchItemStatus `json:"list"`
+}
+
+type BatchItemStatus struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// MergeBatchResult sets the status of each order in ext into the items of
+// the result, both lists follow the order of the request.
+func MergeBatchResult(result *BatchOrderResult, ext *BatchExtInfo) []*BatchOrderItem {
+	if result == nil {
+		return nil
+	}
+
+	if ext != nil {
+		for i, item := range result.List {
+			if i < len(ext.List) && item != nil {
+				item.Code = ext.List[i].Code
+				item.Msg = ext.List[i].Msg
+			}
+		}
+	}
+
+	return result.List
+}
+
 type OrderResponse struct {
 	OrderId     string `json:"orderId"`
 	OrderLinkId string `json:"orderLinkId"`
@@ -61,6 +115,7 @@ type OrderBookResult struct {
 	Bids      [][]string `json:"b"`
 	Timestamp int64      `json:"ts"`
 	UpdateID  int        `json:"u"`
+	Seq       int64      `json:"seq"`
 }
 
 type TickersResponse struct {
@@ -163,15 +218,22 @@ type APIKeyInformationListResponse struct {
 }
 
 type Permissions struct {
-	ContractTrade []string      `json:"ContractTrade"`
-	Spot          []string      `json:"Spot"`
-	Wallet        []string      `json:"Wallet"`
-	Options       []string      `json:"Options"`
-	Derivatives   []string      `json:"Derivatives"`
-	CopyTrading   []string      `json:"CopyTrading"`
-	BlockTrade    []interface{} `json:"BlockTrade"`
-	Exchange      []string      `json:"Exchange"`
-	Nft           []string      `json:"NFT"`
+	ContractTrade []string      `json:"ContractTrade,omitempty"`
+	Spot          []string      `json:"Spot,omitempty"`
+	Wallet        []string      `json:"Wallet,omitempty"`
+	Options       []string      `json:"Options,omitempty"`
+	Derivatives   []string      `json:"Derivatives,omitempty"`
+	CopyTrading   []string      `json:"CopyTrading,omitempty"`
+	BlockTrade    []interface{} `json:"BlockTrade,omitempty"`
+	Exchange      []string      `json:"Exchange,omitempty"`
+	Nft           []string      `json:"NFT,omitempty"`
+}
+
+type DeleteAPIKeyResponse struct {
+	RetCode int         `json:"retCode"`
+	RetMsg  string      `json:"retMsg"`
+	Result  interface{} `json:"result"`
+	Time    int64       `json:"time"`
 }
 
 type TransferableCoinsResponse struct {
@@ -201,6 +263,46 @@ type TransferWithdrawFlowResponse struct {
 	TransferId string
 }
 
+type PositionListResponse struct {
+	RetCode int                 `json:"retCode"`
+	RetMsg  string              `json:"retMsg"`
+	Result  *PositionListResult `json:"result"`
+	Time    int64               `json:"time"`
+}
+
+type PositionListResult struct {
+	NextPageCursor string      `json:"nextPageCursor"`
+	Category       string      `json:"category"`
+	List           []*Position `json:"list"`
+}
+
+type Position struct {
+	PositionIdx    int    `json:"positionIdx"`
+	RiskID         int    `json:"riskId"`
+	RiskLimitValue string `json:"riskLimitValue"`
+	Symbol         string `json:"symbol"`
+	Side           string `json:"side"`
+	Size           string `json:"size"`
+	AvgPrice       string `json:"avgPrice"`
+	PositionValue  string `json:"positionValue"`
+	TradeMode      int    `json:"tradeMode"`
+	PositionStatus string `json:"positionStatus"`
+	AutoAddMargin  int    `json:"autoAddMargin"`
+	Leverage       string `json:"leverage"`
+	MarkPrice      string `json:"markPrice"`
+	LiqPrice       string `json:"liqPrice"`
+	BustPrice      string `json:"bustPrice"`
+	PositionIM     string `json:"positionIM"`
+	PositionMM     string `json:"positionMM"`
+	TakeProfit     string `json:"takeProfit"`
+	StopLoss       string `json:"stopLoss"`
+	TrailingStop   string `json:"trailingStop"`
+	UnrealisedPnl  string `json:"unrealisedPnl"`
+	CumRealisedPnl string `json:"cumRealisedPnl"`
+	CreatedTime    string `json:"createdTime"`
+	UpdatedTime    string `json:"updatedTime"`
+}
+
 type WalletBalanceResponse struct {
 	RetCode int                  `json:"retCode"`
 	RetMsg  string               `json:"retMsg"`
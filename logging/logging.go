@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ComponentKey attribute used to identify which part of the library logged.
+	ComponentKey = "component"
+	// RequestIDKey attribute used to correlate the logs of a single request.
+	RequestIDKey = "request_id"
+	// Redacted value that replaces sensitive attributes.
+	Redacted = "[REDACTED]"
+)
+
+type requestIDKey struct{}
+
+// sensitiveKeys attributes that are never written, compared in lower case.
+var sensitiveKeys = map[string]struct{}{
+	"api_key":          {},
+	"apikey":           {},
+	"api_secret":       {},
+	"apisecret":        {},
+	"secret":           {},
+	"sign":             {},
+	"signature":        {},
+	"x-bapi-api-key":   {},
+	"x-bapi-sign":      {},
+	"x-bapi-signature": {},
+}
+
+// Default logger used when none is provided, writes text logs to stdout
+// from Info level with the given component.
+func Default(component string) *slog.Logger {
+	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
+
+	return slog.New(NewRedactHandler(handler)).With(ComponentKey, component)
+}
+
+// Discard logger that drops every record, useful for high-throughput services.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(1 << 10)}))
+}
+
+// Redact wraps the handler of logger to redact api keys and signatures.
+// A nil logger is converted into a Discard logger.
+func Redact(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+
+	if _, ok := logger.Handler().(*redactHandler); ok {
+		return logger
+	}
+
+	return slog.New(NewRedactHandler(logger.Handler()))
+}
+
+// NewRequestID generates a new id to correlate logs.
+func NewRequestID() string {
+	return uuid.New().String()
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID retrieve the request id stored in ctx, empty if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// redactHandler replaces the value of sensitive attributes before passing
+// the record to the wrapped handler.
+type redactHandler struct {
+	next slog.Handler
+}
+
+// NewRedactHandler wraps next to redact api keys and signatures.
+func NewRedactHandler(next slog.Handler) slog.Handler {
+	return &redactHandler{next: next}
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, redactAttr(attr))
+	}
+
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(attr.Key)]; ok {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, groupAttr := range group {
+			redacted = append(redacted, redactAttr(groupAttr))
+		}
+
+		return slog.Group(attr.Key, redacted...)
+	}
+
+	return attr
+}
@@ -0,0 +1,119 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+const (
+	apiKey    = "test-key"
+	apiSecret = "test-secret"
+	sign      = "0123456789abcdef"
+)
+
+func newLogger(buf *bytes.Buffer) *slog.Logger {
+	return logging.Redact(slog.New(slog.NewJSONHandler(buf, nil)))
+}
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+
+	entry := make(map[string]any)
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+
+	return entry
+}
+
+func assertRedacted(t *testing.T, buf *bytes.Buffer) {
+	t.Helper()
+
+	for _, value := range []string{apiKey, apiSecret, sign} {
+		if strings.Contains(buf.String(), value) {
+			t.Fatalf("%q leaked in %s", value, buf.String())
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var buf bytes.Buffer
+	newLogger(&buf).Info("request",
+		"api_key", apiKey,
+		"X-BAPI-API-KEY", apiKey,
+		"secret", apiSecret,
+		"sign", sign,
+		"X-BAPI-SIGN", sign,
+		"symbol", "BTCUSDT",
+	)
+
+	assertRedacted(t, &buf)
+
+	entry := decode(t, &buf)
+	for _, key := range []string{"api_key", "X-BAPI-API-KEY", "secret", "sign", "X-BAPI-SIGN"} {
+		if entry[key] != logging.Redacted {
+			t.Errorf("expected %s redacted got %v", key, entry[key])
+		}
+	}
+
+	if entry["symbol"] != "BTCUSDT" {
+		t.Errorf("expected symbol kept got %v", entry["symbol"])
+	}
+}
+
+func TestRedactGroups(t *testing.T) {
+	var buf bytes.Buffer
+	newLogger(&buf).Info("request",
+		slog.Group("header",
+			slog.String("X-BAPI-API-KEY", apiKey),
+			slog.Group("auth", slog.String("sign", sign), slog.String("secret", apiSecret)),
+			slog.String("Content-Type", "application/json"),
+		),
+	)
+
+	assertRedacted(t, &buf)
+
+	header, _ := decode(t, &buf)["header"].(map[string]any)
+	auth, _ := header["auth"].(map[string]any)
+	if header["X-BAPI-API-KEY"] != logging.Redacted || auth["sign"] != logging.Redacted || auth["secret"] != logging.Redacted {
+		t.Fatalf("expected nested attributes redacted got %v", header)
+	}
+
+	if header["Content-Type"] != "application/json" {
+		t.Fatalf("expected Content-Type kept got %v", header["Content-Type"])
+	}
+}
+
+func TestRedactWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	newLogger(&buf).
+		With("api_key", apiKey).
+		WithGroup("request").
+		With("sign", sign).
+		Info("sent", "secret", apiSecret)
+
+	assertRedacted(t, &buf)
+
+	entry := decode(t, &buf)
+	request, _ := entry["request"].(map[string]any)
+	if entry["api_key"] != logging.Redacted || request["sign"] != logging.Redacted || request["secret"] != logging.Redacted {
+		t.Fatalf("expected attributes redacted got %v", entry)
+	}
+}
+
+func TestRedactOnce(t *testing.T) {
+	logger := logging.Redact(slog.Default())
+	if logging.Redact(logger) != logger {
+		t.Fatal("expected a redacted logger to be returned as is")
+	}
+
+	if logging.Redact(nil) == nil {
+		t.Fatal("expected a logger for nil")
+	}
+}
@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	Namespace = "bybit"
+
+	// rate limit headers returned by bybit in every response.
+	rateLimitHeader          = "X-Bapi-Limit"
+	rateLimitRemainingHeader = "X-Bapi-Limit-Status"
+
+	// retCodeError label used when the response envelope couldn't be decoded.
+	retCodeError = "error"
+)
+
+// Metrics collects prometheus metrics of the REST and websocket clients.
+// Use Middleware with http.WithMiddleware and pass Metrics itself to
+// websocket.WithObserver.
+type Metrics struct {
+	httpRequests           *prometheus.CounterVec
+	httpLatency            *prometheus.HistogramVec
+	httpRateLimit          *prometheus.GaugeVec
+	httpRateLimitRemaining *prometheus.GaugeVec
+	wsConnections          prometheus.Counter
+	wsReconnects           prometheus.Counter
+	wsMessages             *prometheus.CounterVec
+	wsHandlerLatency       *prometheus.HistogramVec
+	wsHandlerErrors        *prometheus.CounterVec
+	wsDropped              *prometheus.CounterVec
+	wsPingRTT              prometheus.Histogram
+}
+
+// New creates the collectors and registers them in reg
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of REST requests by endpoint and retCode.",
+		}, []string{"endpoint", "ret_code"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of REST requests by endpoint and retCode.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "ret_code"}),
+		httpRateLimit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "rate_limit",
+			Help:      "Rate limit of the endpoint reported by bybit.",
+		}, []string{"endpoint"}),
+		httpRateLimitRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Subsystem: "http",
+			Name:      "rate_limit_remaining",
+			Help:      "Remaining requests in the current rate limit window of the endpoint.",
+		}, []string{"endpoint"}),
+		wsConnections: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "connections_total",
+			Help:      "Number of websocket connections established.",
+		}),
+		wsReconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "reconnects_total",
+			Help:      "Number of websocket reconnections.",
+		}),
+		wsMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "messages_total",
+			Help:      "Number of websocket messages received by topic.",
+		}, []string{"topic"}),
+		wsHandlerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent by handlers processing a message by topic.",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1},
+		}, []string{"topic"}),
+		wsHandlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "handler_errors_total",
+			Help:      "Number of messages handlers failed to process by topic.",
+		}, []string{"topic"}),
+		wsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "dropped_messages_total",
+			Help:      "Number of websocket messages dropped by the dispatch policy by topic.",
+		}, []string{"topic"}),
+		wsPingRTT: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Subsystem: "ws",
+			Name:      "ping_rtt_seconds",
+			Help:      "Round trip time between a ping and its pong.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}),
+	}
+
+	collectors := []prometheus.Collector{
+		m.httpRequests,
+		m.httpLatency,
+		m.httpRateLimit,
+		m.httpRateLimitRemaining,
+		m.wsConnections,
+		m.wsReconnects,
+		m.wsMessages,
+		m.wsHandlerLatency,
+		m.wsHandlerErrors,
+		m.wsDropped,
+		m.wsPingRTT,
+	}
+
+	for _, collector := range collectors {
+		err := reg.Register(collector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Middleware records count, latency and rate limit headroom of every REST call
+func (m *Metrics) Middleware() bybitHttp.Middleware {
+	return func(next bybitHttp.Invoker) bybitHttp.Invoker {
+		return func(call *bybitHttp.Call, objResp any) error {
+			err := next(call, objResp)
+
+			retCode := strconv.Itoa(call.Envelope.RetCode)
+			if err != nil {
+				retCode = retCodeError
+			}
+
+			m.httpRequests.WithLabelValues(call.Endpoint, retCode).Inc()
+			m.httpLatency.WithLabelValues(call.Endpoint, retCode).Observe(call.Latency.Seconds())
+			m.observeRateLimit(call)
+
+			return err
+		}
+	}
+}
+
+func (m *Metrics) observeRateLimit(call *bybitHttp.Call) {
+	if call.Header == nil {
+		return
+	}
+
+	if limit, err := strconv.ParseFloat(call.Header.Get(rateLimitHeader), 64); err == nil {
+		m.httpRateLimit.WithLabelValues(call.Endpoint).Set(limit)
+	}
+
+	if remaining, err := strconv.ParseFloat(call.Header.Get(rateLimitRemainingHeader), 64); err == nil {
+		m.httpRateLimitRemaining.WithLabelValues(call.Endpoint).Set(remaining)
+	}
+}
+
+// Connected implements websocket.Observer
+func (m *Metrics) Connected(reconnect bool) {
+	m.wsConnections.Inc()
+	if reconnect {
+		m.wsReconnects.Inc()
+	}
+}
+
+// MessageReceived implements websocket.Observer
+func (m *Metrics) MessageReceived(topic string) {
+	m.wsMessages.WithLabelValues(topic).Inc()
+}
+
+// MessageHandled implements websocket.Observer
+func (m *Metrics) MessageHandled(topic string, elapsed time.Duration, err error) {
+	m.wsHandlerLatency.WithLabelValues(topic).Observe(elapsed.Seconds())
+	if err != nil {
+		m.wsHandlerErrors.WithLabelValues(topic).Inc()
+	}
+}
+
+// MessageDropped implements websocket.DropObserver
+func (m *Metrics) MessageDropped(topic string) {
+	m.wsDropped.WithLabelValues(topic).Inc()
+}
+
+// Pong implements websocket.Observer
+func (m *Metrics) Pong(rtt time.Duration) {
+	m.wsPingRTT.Observe(rtt.Seconds())
+}
+
+var (
+	_ bybitWs.Observer     = (*Metrics)(nil)
+	_ bybitWs.DropObserver = (*Metrics)(nil)
+)
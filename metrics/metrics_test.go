@@ -0,0 +1,142 @@
+package metrics_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Gealber/bybit/bybittest"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newMetrics(t *testing.T) (*metrics.Metrics, *prometheus.Registry) {
+	t.Helper()
+
+	reg := prometheus.NewRegistry()
+	m, err := metrics.New(reg)
+	if err != nil {
+		t.Fatalf("creating metrics: %v", err)
+	}
+
+	return m, reg
+}
+
+func TestMiddleware(t *testing.T) {
+	m, reg := newMetrics(t)
+
+	server := bybittest.NewServer("test-key", "test-secret")
+	t.Cleanup(server.Close)
+	server.SetRateLimit("order/create", 10, time.Minute)
+	server.SetError("order/cancel", 110001, "order not exists or too late to cancel")
+
+	client, err := bybitHttp.New(server.Config(), bybitHttp.WithMiddleware(m.Middleware()))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+		if err != nil {
+			t.Fatalf("placing order: %v", err)
+		}
+	}
+
+	_, err = client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: "1"})
+	if err == nil {
+		t.Fatal("expected cancel to fail")
+	}
+
+	expected := `
+# HELP bybit_http_requests_total Number of REST requests by endpoint and retCode.
+# TYPE bybit_http_requests_total counter
+bybit_http_requests_total{endpoint="order/cancel",ret_code="110001"} 1
+bybit_http_requests_total{endpoint="order/create",ret_code="0"} 2
+# HELP bybit_http_rate_limit Rate limit of the endpoint reported by bybit.
+# TYPE bybit_http_rate_limit gauge
+bybit_http_rate_limit{endpoint="order/create"} 10
+# HELP bybit_http_rate_limit_remaining Remaining requests in the current rate limit window of the endpoint.
+# TYPE bybit_http_rate_limit_remaining gauge
+bybit_http_rate_limit_remaining{endpoint="order/create"} 8
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"bybit_http_requests_total", "bybit_http_rate_limit", "bybit_http_rate_limit_remaining")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := testutil.CollectAndCount(reg, "bybit_http_request_duration_seconds"); count != 2 {
+		t.Fatalf("expected latency of 2 endpoints got %d", count)
+	}
+}
+
+func TestObserver(t *testing.T) {
+	m, reg := newMetrics(t)
+
+	m.Connected(false)
+	m.Connected(true)
+	m.MessageReceived("tickers.BTCUSDT")
+	m.MessageReceived("tickers.BTCUSDT")
+	m.MessageReceived("execution")
+	m.MessageHandled("tickers.BTCUSDT", time.Millisecond, nil)
+	m.MessageHandled("execution", time.Millisecond, errors.New("failed"))
+	m.Pong(20 * time.Millisecond)
+
+	expected := `
+# HELP bybit_ws_connections_total Number of websocket connections established.
+# TYPE bybit_ws_connections_total counter
+bybit_ws_connections_total 2
+# HELP bybit_ws_reconnects_total Number of websocket reconnections.
+# TYPE bybit_ws_reconnects_total counter
+bybit_ws_reconnects_total 1
+# HELP bybit_ws_messages_total Number of websocket messages received by topic.
+# TYPE bybit_ws_messages_total counter
+bybit_ws_messages_total{topic="execution"} 1
+bybit_ws_messages_total{topic="tickers.BTCUSDT"} 2
+# HELP bybit_ws_handler_errors_total Number of messages handlers failed to process by topic.
+# TYPE bybit_ws_handler_errors_total counter
+bybit_ws_handler_errors_total{topic="execution"} 1
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"bybit_ws_connections_total", "bybit_ws_reconnects_total", "bybit_ws_messages_total", "bybit_ws_handler_errors_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count := testutil.CollectAndCount(reg, "bybit_ws_handler_duration_seconds"); count != 2 {
+		t.Fatalf("expected handler latency of 2 topics got %d", count)
+	}
+
+	if count := testutil.CollectAndCount(reg, "bybit_ws_ping_rtt_seconds"); count != 1 {
+		t.Fatalf("expected ping rtt got %d", count)
+	}
+}
+
+func TestDropObserver(t *testing.T) {
+	m, reg := newMetrics(t)
+
+	m.MessageDropped("orderbook.50.BTCUSDT")
+	m.MessageDropped("orderbook.50.BTCUSDT")
+
+	expected := `
+# HELP bybit_ws_dropped_messages_total Number of websocket messages dropped by the dispatch policy by topic.
+# TYPE bybit_ws_dropped_messages_total counter
+bybit_ws_dropped_messages_total{topic="orderbook.50.BTCUSDT"} 2
+`
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "bybit_ws_dropped_messages_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	_, reg := newMetrics(t)
+
+	_, err := metrics.New(reg)
+	if err == nil {
+		t.Fatal("expected registering the collectors twice to fail")
+	}
+}
@@ -0,0 +1,9 @@
+package paper
+
+import "errors"
+
+var (
+	ErrorOrderNotFound = errors.New("order not exists or too late to cancel")
+	ErrorNoLiquidity   = errors.New("no liquidity in the order book")
+	ErrorInvalidOrder  = errors.New("invalid order")
+)
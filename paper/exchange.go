@@ -0,0 +1,688 @@
+// Package paper provides an in-memory exchange to run trading strategies
+// without sending orders to bybit.
+package paper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	// fees of the regular spot tier.
+	DefaultMakerFee = 0.001
+	DefaultTakerFee = 0.001
+
+	// DefaultLeverage of linear positions, fully collateralized.
+	DefaultLeverage = 1.0
+
+	// retCodes of the items of batch operations.
+	RetCodeInvalidOrder        = 10001
+	RetCodeOrderNotFound       = 110001
+	RetCodeInsufficientBalance = 170131
+)
+
+// DefaultQuoteCoins coins used to split symbols, e.g. BTCUSDT in BTC and USDT
+var DefaultQuoteCoins = []string{"USDT", "USDC", "BTC", "ETH", "EUR", "DAI"}
+
+// Fees rates charged on the value of every execution
+type Fees struct {
+	Maker float64
+	Taker float64
+}
+
+type balance struct {
+	free float64
+	// locked by open orders, spot balances and the initial margin of linear orders.
+	locked float64
+	// margin held by the open positions settled in the coin.
+	margin float64
+}
+
+// instrument identifies the books and positions, the same symbol is
+// traded in several categories.
+type instrument struct {
+	category string
+	symbol   string
+}
+
+type position struct {
+	// size positive for long positions and negative for short ones.
+	size        float64
+	avgPrice    float64
+	margin      float64
+	realisedPnl float64
+	created     time.Time
+	updated     time.Time
+}
+
+// Exchange simulates bybit matching the orders against the order books
+// supplied with SetOrderBook or the market data it handles as a websocket
+// Handler, books and positions are kept by category and symbol. Spot orders lock and settle the balances of the base and quote
+// coins, linear orders reserve the initial margin of the positions they
+// open in the quote coin. It implements http.Exchange so strategies can run in paper mode,
+// e.g. with http.WithExchange. All the methods are safe for concurrent use.
+type Exchange struct {
+	fees       Fees
+	leverage   float64
+	quotes     []string
+	dispatcher *bybitWs.Dispatcher
+	orderBooks *bybitWs.OrderBooks
+	now        func() time.Time
+
+	mu        sync.Mutex
+	balances  map[string]*balance
+	books     map[instrument]*book
+	orders    map[string]*order
+	linkIDs   map[string]string
+	positions map[instrument]*position
+	execSeq   int64
+}
+
+// New creates an exchange without balances nor order books, opts allow to customize it
+func New(opts ...Option) *Exchange {
+	e := &Exchange{
+		fees:      Fees{Maker: DefaultMakerFee, Taker: DefaultTakerFee},
+		leverage:  DefaultLeverage,
+		quotes:    DefaultQuoteCoins,
+		now:       time.Now,
+		balances:  make(map[string]*balance),
+		books:     make(map[instrument]*book),
+		orders:    make(map[string]*order),
+		linkIDs:   make(map[string]string),
+		positions: make(map[instrument]*position),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if e.orderBooks == nil {
+		e.orderBooks = bybitWs.NewOrderBooks(nil)
+	}
+	e.orderBooks.OnUpdate(e.syncOrderBook)
+
+	return e
+}
+
+// SetOrderBook replaces the book of symbol in category, resting orders
+// crossing the new book are executed as maker.
+func (e *Exchange) SetOrderBook(category, symbol string, bids, asks []bybitWs.PriceLevel) {
+	e.mu.Lock()
+	ev := &events{}
+	e.setOrderBook(instrument{category: category, symbol: symbol}, bids, asks, ev)
+	e.mu.Unlock()
+
+	e.emit(ev)
+}
+
+// setOrderBook replaces the book of key keeping its last price. Must be
+// called with the lock held.
+func (e *Exchange) setOrderBook(key instrument, bids, asks []bybitWs.PriceLevel, ev *events) {
+	b := &book{
+		bids: append([]bybitWs.PriceLevel(nil), bids...),
+		asks: append([]bybitWs.PriceLevel(nil), asks...),
+	}
+	sort.Slice(b.bids, func(i, j int) bool { return b.bids[i].Price > b.bids[j].Price })
+	sort.Slice(b.asks, func(i, j int) bool { return b.asks[i].Price < b.asks[j].Price })
+
+	if previous, ok := e.books[key]; ok {
+		b.last = previous.last
+	}
+	e.books[key] = b
+
+	e.matchResting(key, ev)
+}
+
+// syncOrderBook copies the book of symbol in category maintained by the
+// order books, the liquidity taken by previous orders is restored on every update.
+func (e *Exchange) syncOrderBook(category bybitWs.CoverType, symbol string) {
+	bids, asks, ok := e.orderBooks.Depth(category, symbol, 0)
+	if ok {
+		e.SetOrderBook(string(category), symbol, bids, asks)
+	}
+}
+
+// ProcessMsg implements websocket.Handler, tickers update the top of the
+// book of its symbol and order book snapshots and deltas are applied to the
+// order books of the exchange. The category of the books is the one of the
+// endpoint the message was received from, spot when unknown. Spot tickers
+// only carry the last price, used as an unlimited level on both sides of the book.
+func (e *Exchange) ProcessMsg(ctx context.Context, obj any) error {
+	switch msg := obj.(type) {
+	case bybitWs.TickersResponse:
+		if msg.Data == nil {
+			return nil
+		}
+
+		e.processTicker(categoryFromContext(ctx), msg.Data)
+	case bybitWs.OrderbookResponse:
+		return e.orderBooks.ProcessMsg(ctx, msg)
+	default:
+		return fmt.Errorf("%w: %T", bybitWs.ErrorInvalidMessage, obj)
+	}
+
+	return nil
+}
+
+// processTicker updates the top of the book of the symbol in category,
+// fields missing in deltas keep their previous value.
+func (e *Exchange) processTicker(category string, ticker *bybitWs.TickersData) {
+	bid, bidSize := parseFloat(ticker.Bid1Price), parseFloat(ticker.Bid1Size)
+	ask, askSize := parseFloat(ticker.Ask1Price), parseFloat(ticker.Ask1Size)
+	last := parseFloat(ticker.LastPrice)
+
+	e.mu.Lock()
+	ev := &events{}
+	defer e.emit(ev)
+	defer e.mu.Unlock()
+
+	key := instrument{category: category, symbol: ticker.Symbol}
+	previous, ok := e.books[key]
+	if ok && bid == 0 && len(previous.bids) > 0 {
+		bid, bidSize = previous.bids[0].Price, previous.bids[0].Size
+	}
+
+	if ok && ask == 0 && len(previous.asks) > 0 {
+		ask, askSize = previous.asks[0].Price, previous.asks[0].Size
+	}
+
+	if bid == 0 && ask == 0 {
+		if last == 0 {
+			return
+		}
+
+		bid, ask = last, last
+		bidSize, askSize = unlimited, unlimited
+	}
+
+	bids := make([]bybitWs.PriceLevel, 0, 1)
+	if bid > 0 {
+		bids = append(bids, bybitWs.PriceLevel{Price: bid, Size: bidSize})
+	}
+
+	asks := make([]bybitWs.PriceLevel, 0, 1)
+	if ask > 0 {
+		asks = append(asks, bybitWs.PriceLevel{Price: ask, Size: askSize})
+	}
+
+	e.setOrderBook(key, bids, asks, ev)
+
+	if last > 0 {
+		e.books[key].last = last
+	}
+}
+
+// PlaceOrder place an order in the simulated exchange
+func (e *Exchange) PlaceOrder(request bybitHttp.OrderRequest) (*bybitHttp.OrderResponse, error) {
+	e.mu.Lock()
+	ev := &events{}
+	o, err := e.place(request, ev)
+	e.mu.Unlock()
+
+	e.emit(ev)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &bybitHttp.OrderResponse{OrderId: o.orderID, OrderLinkId: o.orderLinkID}, nil
+}
+
+// AmendOrder modify the price or quantity of an open order
+func (e *Exchange) AmendOrder(amend bybitHttp.AmendRequest) (*bybitHttp.OrderResponse, error) {
+	e.mu.Lock()
+	ev := &events{}
+	o, err := e.amend(amend, ev)
+	e.mu.Unlock()
+
+	e.emit(ev)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &bybitHttp.OrderResponse{OrderId: o.orderID, OrderLinkId: o.orderLinkID}, nil
+}
+
+// CancelOrder cancel an open order
+func (e *Exchange) CancelOrder(cancel bybitHttp.CancelRequest) (*bybitHttp.OrderResponse, error) {
+	e.mu.Lock()
+	ev := &events{}
+	o, err := e.cancel(cancel.OrderID, cancel.OrderLinkId, ev)
+	e.mu.Unlock()
+
+	e.emit(ev)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &bybitHttp.OrderResponse{OrderId: o.orderID, OrderLinkId: o.orderLinkID}, nil
+}
+
+// PlaceBatchOrders place several orders, check the Code of each item
+func (e *Exchange) PlaceBatchOrders(batch bybitHttp.BatchOrderRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	items := make([]*bybitHttp.BatchOrderItem, 0, len(batch.Request))
+	for _, request := range batch.Request {
+		request.Category = batch.Category
+		response, err := e.PlaceOrder(request)
+		items = append(items, batchItem(batch.Category, request.Symbol, response, err))
+	}
+
+	return items, nil
+}
+
+// AmendBatchOrders modify several open orders, check the Code of each item
+func (e *Exchange) AmendBatchOrders(batch bybitHttp.BatchAmendRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	items := make([]*bybitHttp.BatchOrderItem, 0, len(batch.Request))
+	for _, request := range batch.Request {
+		request.Category = batch.Category
+		response, err := e.AmendOrder(request)
+		items = append(items, batchItem(batch.Category, request.Symbol, response, err))
+	}
+
+	return items, nil
+}
+
+// CancelBatchOrders cancel several orders, check the Code of each item
+func (e *Exchange) CancelBatchOrders(batch bybitHttp.BatchCancelRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	items := make([]*bybitHttp.BatchOrderItem, 0, len(batch.Request))
+	for _, request := range batch.Request {
+		request.Category = batch.Category
+		response, err := e.CancelOrder(request)
+		items = append(items, batchItem(batch.Category, request.Symbol, response, err))
+	}
+
+	return items, nil
+}
+
+// OpenOrders retrieve the open orders, queryParams of type http.HistoryParams
+// filter them by category, symbol and order ids.
+func (e *Exchange) OpenOrders(queryParams any) ([]*bybitHttp.Order, error) {
+	var filter bybitHttp.HistoryParams
+	switch params := queryParams.(type) {
+	case bybitHttp.HistoryParams:
+		filter = params
+	case *bybitHttp.HistoryParams:
+		filter = *params
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	orders := make([]*order, 0)
+	for _, o := range e.orders {
+		switch {
+		case !o.open():
+		case filter.Category != "" && o.category != filter.Category:
+		case filter.Symbol != "" && o.symbol != filter.Symbol:
+		case filter.OrderId != "" && o.orderID != filter.OrderId:
+		case filter.OrderLinkId != "" && o.orderLinkID != filter.OrderLinkId:
+		default:
+			orders = append(orders, o)
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool { return orders[i].created.After(orders[j].created) })
+
+	result := make([]*bybitHttp.Order, 0, len(orders))
+	for _, o := range orders {
+		data := o.data()
+		result = append(result, &data.Order)
+	}
+
+	return result, nil
+}
+
+// GetWalletBalance retrieve the balances of the unified account, the coins
+// of queryParams is a comma separated list, empty for every coin. Every
+// requested coin is reported, with zero balances when never held. The total
+// available balance is the available balance in USDT, the equity of a coin
+// includes the unrealised pnl of the positions settled in it.
+func (e *Exchange) GetWalletBalance(queryParams bybitHttp.WalletBalanceParams) (*bybitHttp.WalletBalanceResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	names := make([]string, 0, len(e.balances))
+	if queryParams.Coin != "" {
+		for _, coin := range strings.Split(queryParams.Coin, ",") {
+			if !contains(names, coin) {
+				names = append(names, coin)
+			}
+		}
+	} else {
+		for coin := range e.balances {
+			names = append(names, coin)
+		}
+	}
+	sort.Strings(names)
+
+	available := 0.0
+	if usdt, ok := e.balances["USDT"]; ok {
+		available = usdt.free
+	}
+
+	wallet := bybitHttp.WalletBalance{
+		AccountType:           bybitHttp.UnifiedAccount,
+		TotalAvailableBalance: formatFloat(available),
+		Coin:                  make([]bybitHttp.CoinBalanceInfo, 0, len(names)),
+	}
+
+	unrealised := make(map[string]float64)
+	for key, p := range e.positions {
+		if p.size != 0 {
+			_, quote, _ := e.split(key.symbol)
+			unrealised[quote] += (e.markPrice(key, p) - p.avgPrice) * p.size
+		}
+	}
+
+	for _, coin := range names {
+		b, ok := e.balances[coin]
+		if !ok {
+			b = &balance{}
+		}
+
+		total := b.free + b.locked + b.margin
+		wallet.Coin = append(wallet.Coin, bybitHttp.CoinBalanceInfo{
+			Coin:                coin,
+			Equity:              formatFloat(total + unrealised[coin]),
+			WalletBalance:       formatFloat(total),
+			AvailableToWithdraw: formatFloat(b.free),
+			TotalOrderIM:        formatFloat(b.locked),
+			TotalPositionIM:     formatFloat(b.margin),
+			UnrealisedPnl:       formatFloat(unrealised[coin]),
+		})
+	}
+
+	return &bybitHttp.WalletBalanceResult{List: []bybitHttp.WalletBalance{wallet}}, nil
+}
+
+// GetPositionInfo retrieve the open positions, filtered by category and symbol
+func (e *Exchange) GetPositionInfo(queryParams bybitHttp.PositionParams) ([]*bybitHttp.Position, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keys := make([]instrument, 0, len(e.positions))
+	for key, p := range e.positions {
+		switch {
+		case p.size == 0:
+		case queryParams.Category != "" && key.category != queryParams.Category:
+		case queryParams.Symbol != "" && key.symbol != queryParams.Symbol:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sortInstruments(keys)
+
+	positions := make([]*bybitHttp.Position, 0, len(keys))
+	for _, key := range keys {
+		positions = append(positions, e.positionInfo(key, e.positions[key]))
+	}
+
+	return positions, nil
+}
+
+// GetTickers retrieve the best prices and the last price of the books,
+// filtered by category and symbol.
+func (e *Exchange) GetTickers(queryParams bybitHttp.TickerParams) ([]*bybitHttp.Ticker, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keys := make([]instrument, 0, len(e.books))
+	for key := range e.books {
+		switch {
+		case queryParams.Category != "" && key.category != queryParams.Category:
+		case queryParams.Symbol != "" && key.symbol != queryParams.Symbol:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sortInstruments(keys)
+
+	tickers := make([]*bybitHttp.Ticker, 0, len(keys))
+	for _, key := range keys {
+		b := e.books[key]
+		ticker := &bybitHttp.Ticker{Symbol: key.symbol, LastPrice: formatFloat(b.last)}
+		if len(b.bids) > 0 {
+			ticker.Bid1Price = formatFloat(b.bids[0].Price)
+			ticker.Bid1Size = formatFloat(b.bids[0].Size)
+		}
+
+		if len(b.asks) > 0 {
+			ticker.Ask1Price = formatFloat(b.asks[0].Price)
+			ticker.Ask1Size = formatFloat(b.asks[0].Size)
+		}
+
+		tickers = append(tickers, ticker)
+	}
+
+	return tickers, nil
+}
+
+// BestPrice implements http.PriceSource with the spot books, the best bid
+// is returned when selling and the best ask when buying.
+func (e *Exchange) BestPrice(side, symbol string) (float64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b, ok := e.books[instrument{category: bybitHttp.SpotCategory, symbol: symbol}]
+	if !ok {
+		return 0, false
+	}
+
+	levels := b.asks
+	if side == bybitHttp.SellDirection {
+		levels = b.bids
+	}
+
+	if len(levels) == 0 {
+		return 0, false
+	}
+
+	return levels[0].Price, true
+}
+
+// Balance retrieve the available and locked balance of coin, the locked
+// balance includes the margin of the open positions.
+func (e *Exchange) Balance(coin string) (float64, float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b := e.balance(coin)
+
+	return b.free, b.locked + b.margin
+}
+
+// balance of coin, created empty when missing. Must be called with the lock held.
+func (e *Exchange) balance(coin string) *balance {
+	b, ok := e.balances[coin]
+	if !ok {
+		b = &balance{}
+		e.balances[coin] = b
+	}
+
+	return b
+}
+
+// split retrieve the base and quote coins of symbol
+func (e *Exchange) split(symbol string) (string, string, error) {
+	for _, quote := range e.quotes {
+		if strings.HasSuffix(symbol, quote) && len(symbol) > len(quote) {
+			return strings.TrimSuffix(symbol, quote), quote, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("%w: unknown quote coin of %s", ErrorInvalidOrder, symbol)
+}
+
+func (e *Exchange) positionInfo(key instrument, p *position) *bybitHttp.Position {
+	side := bybitHttp.BuyDirection
+	if p.size < 0 {
+		side = bybitHttp.SellDirection
+	}
+
+	size := abs(p.size)
+	mark := e.markPrice(key, p)
+
+	return &bybitHttp.Position{
+		Symbol:         key.symbol,
+		Side:           side,
+		Size:           formatFloat(size),
+		AvgPrice:       formatFloat(p.avgPrice),
+		PositionValue:  formatFloat(size * p.avgPrice),
+		PositionStatus: "Normal",
+		Leverage:       formatFloat(e.leverage),
+		PositionIM:     formatFloat(p.margin),
+		MarkPrice:      formatFloat(mark),
+		UnrealisedPnl:  formatFloat((mark - p.avgPrice) * p.size),
+		CumRealisedPnl: formatFloat(p.realisedPnl),
+		CreatedTime:    formatTime(p.created),
+		UpdatedTime:    formatTime(p.updated),
+	}
+}
+
+// markPrice of the position of key, the last price of its book when known.
+func (e *Exchange) markPrice(key instrument, p *position) float64 {
+	if b, ok := e.books[key]; ok && b.last > 0 {
+		return b.last
+	}
+
+	return p.avgPrice
+}
+
+// events produced by an operation, emitted once the lock is released.
+type events struct {
+	orders     []bybitWs.OrderData
+	executions []bybitWs.ExecutionData
+}
+
+func (e *Exchange) emit(ev *events) {
+	if e.dispatcher == nil {
+		return
+	}
+
+	now := e.now().UnixMilli()
+
+	if len(ev.executions) > 0 {
+		e.dispatch(bybitWs.ExecutionTopic, bybitWs.ExecutionMessage{
+			ID:           uuid.New().String(),
+			Topic:        bybitWs.ExecutionTopic,
+			CreationTime: now,
+			Data:         ev.executions,
+		})
+	}
+
+	if len(ev.orders) > 0 {
+		e.dispatch(bybitWs.OrderTopic, bybitWs.OrderMessage{
+			ID:           uuid.New().String(),
+			Topic:        bybitWs.OrderTopic,
+			CreationTime: now,
+			Data:         ev.orders,
+		})
+	}
+}
+
+// dispatch passes msg to its handler as the websocket client would do,
+// topics without handler are skipped.
+func (e *Exchange) dispatch(topic string, msg any) {
+	if _, ok := e.dispatcher.Handler(topic); !ok {
+		return
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	_ = e.dispatcher.Dispatch(bybitWs.ContextWithTopic(context.Background(), topic), topic, data)
+}
+
+func batchItem(category, symbol string, response *bybitHttp.OrderResponse, err error) *bybitHttp.BatchOrderItem {
+	item := &bybitHttp.BatchOrderItem{Category: category, Symbol: symbol, Msg: "OK"}
+	if response != nil {
+		item.OrderId = response.OrderId
+		item.OrderLinkId = response.OrderLinkId
+	}
+
+	switch {
+	case err == nil:
+		item.Code = bybitHttp.RetCodeOK
+	case errors.Is(err, bybitHttp.ErrorInsuficcientBalance):
+		item.Code, item.Msg = RetCodeInsufficientBalance, err.Error()
+	case errors.Is(err, ErrorOrderNotFound):
+		item.Code, item.Msg = RetCodeOrderNotFound, err.Error()
+	default:
+		item.Code, item.Msg = RetCodeInvalidOrder, err.Error()
+	}
+
+	return item
+}
+
+// categoryFromContext retrieve the category of the endpoint of a message, spot when unknown
+func categoryFromContext(ctx context.Context) string {
+	if endpoint, ok := bybitWs.EndpointFromContext(ctx); ok && endpoint.Category != "" {
+		return string(endpoint.Category)
+	}
+
+	return bybitHttp.SpotCategory
+}
+
+// sortInstruments by symbol and category
+func sortInstruments(keys []instrument) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].symbol != keys[j].symbol {
+			return keys[i].symbol < keys[j].symbol
+		}
+
+		return keys[i].category < keys[j].category
+	})
+}
+
+func parseFloat(value string) float64 {
+	f, _ := strconv.ParseFloat(value, 64)
+
+	return f
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func abs(value float64) float64 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	_ bybitHttp.Exchange    = (*Exchange)(nil)
+	_ bybitHttp.PriceSource = (*Exchange)(nil)
+	_ bybitWs.Handler       = (*Exchange)(nil)
+)
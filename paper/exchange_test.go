@@ -0,0 +1,465 @@
+package paper_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/paper"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// orderRecorder keeps the last update of every order emitted by the exchange
+type orderRecorder struct {
+	orders map[string]bybitWs.OrderData
+}
+
+func newExchange(t *testing.T, opts ...paper.Option) (*paper.Exchange, *orderRecorder) {
+	t.Helper()
+
+	recorder := &orderRecorder{orders: make(map[string]bybitWs.OrderData)}
+	dispatcher := bybitWs.NewDispatcher()
+	err := dispatcher.Handle(bybitWs.OrderTopic, bybitWs.Func(func(_ context.Context, msg bybitWs.OrderMessage) error {
+		for _, data := range msg.Data {
+			recorder.orders[data.OrderLinkID] = data
+		}
+
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return paper.New(append([]paper.Option{paper.WithDispatcher(dispatcher)}, opts...)...), recorder
+}
+
+func levels(pairs ...float64) []bybitWs.PriceLevel {
+	result := make([]bybitWs.PriceLevel, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		result = append(result, bybitWs.PriceLevel{Price: pairs[i], Size: pairs[i+1]})
+	}
+
+	return result
+}
+
+func place(t *testing.T, exchange *paper.Exchange, request bybitHttp.OrderRequest) {
+	t.Helper()
+
+	_, err := exchange.PlaceOrder(request)
+	if err != nil {
+		t.Fatalf("placing %s: %v", request.OrderLinkId, err)
+	}
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func checkBalance(t *testing.T, exchange *paper.Exchange, coin string, free, locked float64) {
+	t.Helper()
+
+	gotFree, gotLocked := exchange.Balance(coin)
+	if !equal(gotFree, free) || !equal(gotLocked, locked) {
+		t.Fatalf("%s expected free %v locked %v got %v %v", coin, free, locked, gotFree, gotLocked)
+	}
+}
+
+func TestTimeInForce(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeInForce string
+		price       string
+		status      string
+		reason      string
+		cumQty      string
+	}{
+		{name: "GTC rests the remaining", timeInForce: bybitHttp.GoodTillCancel, price: "101", status: bybitHttp.OrderStatusPartiallyFilled, cumQty: "2"},
+		{name: "IOC cancels the remaining", timeInForce: bybitHttp.ImmediateOrCancel, price: "101", status: bybitHttp.OrderStatusCancelled, cumQty: "2"},
+		{name: "IOC without liquidity", timeInForce: bybitHttp.ImmediateOrCancel, price: "99", status: bybitHttp.OrderStatusCancelled, reason: "EC_NoImmediateQtyToFill", cumQty: "0"},
+		{name: "FOK without full fill", timeInForce: bybitHttp.FillOrKill, price: "101", status: bybitHttp.OrderStatusCancelled, reason: "EC_CancelForNoFullFill", cumQty: "0"},
+		{name: "PostOnly taking liquidity", timeInForce: bybitHttp.PostOnly, price: "100", status: bybitHttp.OrderStatusCancelled, reason: "EC_PostOnlyWillTakeLiquidity", cumQty: "0"},
+		{name: "PostOnly resting", timeInForce: bybitHttp.PostOnly, price: "99", status: bybitHttp.OrderStatusNew, cumQty: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange, recorder := newExchange(t, paper.WithBalance("USDT", 1000))
+			exchange.SetOrderBook(bybitHttp.SpotCategory, "BTCUSDT", levels(98, 1), levels(100, 1, 101, 1, 102, 5))
+
+			place(t, exchange, bybitHttp.OrderRequest{
+				Category:    bybitHttp.SpotCategory,
+				Symbol:      "BTCUSDT",
+				Side:        bybitHttp.BuyDirection,
+				OrderType:   bybitHttp.LimitOrder,
+				Qty:         "3",
+				Price:       tt.price,
+				TimeInForce: tt.timeInForce,
+				OrderLinkId: "order",
+			})
+
+			order := recorder.orders["order"]
+			if order.OrderStatus != tt.status || order.RejectReason != tt.reason || order.CumExecQty != tt.cumQty {
+				t.Fatalf("unexpected order status %s reason %q cumQty %s", order.OrderStatus, order.RejectReason, order.CumExecQty)
+			}
+		})
+	}
+}
+
+func TestFeeCurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		category    string
+		side        string
+		feeCurrency string
+		balances    map[string]float64
+	}{
+		// 1 BTC bought at 100 pays 0.001 BTC.
+		{name: "spot buy", category: bybitHttp.SpotCategory, side: bybitHttp.BuyDirection, feeCurrency: "BTC", balances: map[string]float64{"BTC": 1.999, "USDT": 900}},
+		// 1 BTC sold at 99 pays 0.099 USDT.
+		{name: "spot sell", category: bybitHttp.SpotCategory, side: bybitHttp.SellDirection, feeCurrency: "USDT", balances: map[string]float64{"BTC": 0, "USDT": 1098.901}},
+		// the position of 1 BTC holds 100 USDT of margin.
+		{name: "linear", category: bybitHttp.LinearCategory, side: bybitHttp.BuyDirection, feeCurrency: "USDT", balances: map[string]float64{"BTC": 1, "USDT": 999.9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange, recorder := newExchange(t,
+				paper.WithBalance("USDT", 1000),
+				paper.WithBalance("BTC", 1),
+				paper.WithFees(paper.Fees{Maker: 0.001, Taker: 0.001}),
+			)
+			exchange.SetOrderBook(tt.category, "BTCUSDT", levels(99, 10), levels(100, 10))
+
+			place(t, exchange, bybitHttp.OrderRequest{
+				Category:    tt.category,
+				Symbol:      "BTCUSDT",
+				Side:        tt.side,
+				OrderType:   bybitHttp.MarketOrder,
+				Qty:         "1",
+				OrderLinkId: "order",
+			})
+
+			order := recorder.orders["order"]
+			if order.FeeCurrency != tt.feeCurrency || order.OrderStatus != bybitHttp.OrderStatusFilled {
+				t.Fatalf("unexpected fee currency %s status %s", order.FeeCurrency, order.OrderStatus)
+			}
+
+			for coin, expected := range tt.balances {
+				free, locked := exchange.Balance(coin)
+				if !equal(free+locked, expected) {
+					t.Fatalf("expected %v %s got %v", expected, coin, free+locked)
+				}
+			}
+		})
+	}
+}
+
+func TestSpotLockAndSettle(t *testing.T) {
+	exchange, recorder := newExchange(t,
+		paper.WithBalance("USDT", 1000),
+		paper.WithFees(paper.Fees{Maker: 0.001, Taker: 0.002}),
+	)
+	exchange.SetOrderBook(bybitHttp.SpotCategory, "BTCUSDT", levels(90, 10), levels(110, 10))
+
+	place(t, exchange, bybitHttp.OrderRequest{
+		Category:    bybitHttp.SpotCategory,
+		Symbol:      "BTCUSDT",
+		Side:        bybitHttp.BuyDirection,
+		OrderType:   bybitHttp.LimitOrder,
+		Qty:         "2",
+		Price:       "100",
+		OrderLinkId: "buy",
+	})
+	checkBalance(t, exchange, "USDT", 800, 200)
+
+	_, err := exchange.PlaceOrder(bybitHttp.OrderRequest{
+		Category:  bybitHttp.SpotCategory,
+		Symbol:    "BTCUSDT",
+		Side:      bybitHttp.BuyDirection,
+		OrderType: bybitHttp.LimitOrder,
+		Qty:       "9",
+		Price:     "100",
+	})
+	if !errors.Is(err, bybitHttp.ErrorInsuficcientBalance) {
+		t.Fatalf("expected insufficient balance got %v", err)
+	}
+
+	// the book crossing the order fills one unit as maker at the order price.
+	exchange.SetOrderBook(bybitHttp.SpotCategory, "BTCUSDT", levels(90, 10), levels(95, 1))
+	checkBalance(t, exchange, "USDT", 800, 100)
+	checkBalance(t, exchange, "BTC", 0.999, 0)
+
+	if order := recorder.orders["buy"]; order.OrderStatus != bybitHttp.OrderStatusPartiallyFilled || order.CumExecFee != "0.001" {
+		t.Fatalf("unexpected order status %s fee %s", order.OrderStatus, order.CumExecFee)
+	}
+
+	_, err = exchange.CancelOrder(bybitHttp.CancelRequest{OrderLinkId: "buy"})
+	if err != nil {
+		t.Fatalf("cancelling: %v", err)
+	}
+	checkBalance(t, exchange, "USDT", 900, 0)
+
+	// a taker sell settles the locked base coin and pays the fee in the quote coin.
+	place(t, exchange, bybitHttp.OrderRequest{
+		Category:  bybitHttp.SpotCategory,
+		Symbol:    "BTCUSDT",
+		Side:      bybitHttp.SellDirection,
+		OrderType: bybitHttp.MarketOrder,
+		Qty:       "0.999",
+	})
+	checkBalance(t, exchange, "BTC", 0, 0)
+	checkBalance(t, exchange, "USDT", 900+0.999*90*(1-0.002), 0)
+}
+
+func TestPositionPnl(t *testing.T) {
+	tests := []struct {
+		name      string
+		sellQty   string
+		sellPrice float64
+		pnl       string
+		side      string
+		size      string
+		avgPrice  string
+	}{
+		{name: "reduce", sellQty: "1", sellPrice: 110, pnl: "10", side: bybitHttp.BuyDirection, size: "1", avgPrice: "100"},
+		{name: "close", sellQty: "2", sellPrice: 90, pnl: "-20"},
+		{name: "reverse", sellQty: "5", sellPrice: 110, pnl: "20", side: bybitHttp.SellDirection, size: "3", avgPrice: "110"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exchange, _ := newExchange(t, paper.WithBalance("USDT", 1000), paper.WithFees(paper.Fees{}))
+			exchange.SetOrderBook(bybitHttp.LinearCategory, "BTCUSDT", levels(99, 100), levels(100, 100))
+
+			place(t, exchange, bybitHttp.OrderRequest{
+				Category:  bybitHttp.LinearCategory,
+				Symbol:    "BTCUSDT",
+				Side:      bybitHttp.BuyDirection,
+				OrderType: bybitHttp.MarketOrder,
+				Qty:       "2",
+			})
+
+			exchange.SetOrderBook(bybitHttp.LinearCategory, "BTCUSDT", levels(tt.sellPrice, 100), levels(tt.sellPrice+1, 100))
+			place(t, exchange, bybitHttp.OrderRequest{
+				Category:  bybitHttp.LinearCategory,
+				Symbol:    "BTCUSDT",
+				Side:      bybitHttp.SellDirection,
+				OrderType: bybitHttp.MarketOrder,
+				Qty:       tt.sellQty,
+			})
+
+			positions, err := exchange.GetPositionInfo(bybitHttp.PositionParams{Symbol: "BTCUSDT"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.size == "" {
+				if len(positions) != 0 {
+					t.Fatalf("expected a closed position got %+v", positions[0])
+				}
+
+				return
+			}
+
+			if len(positions) != 1 {
+				t.Fatalf("expected a position got %d", len(positions))
+			}
+
+			p := positions[0]
+			if p.Side != tt.side || p.Size != tt.size || p.AvgPrice != tt.avgPrice || p.CumRealisedPnl != tt.pnl {
+				t.Fatalf("unexpected position %s %s@%s pnl %s", p.Side, p.Size, p.AvgPrice, p.CumRealisedPnl)
+			}
+		})
+	}
+}
+
+func TestLinearMargin(t *testing.T) {
+	exchange, recorder := newExchange(t,
+		paper.WithBalance("USDT", 100),
+		paper.WithLeverage(10),
+		paper.WithFees(paper.Fees{}),
+	)
+	exchange.SetOrderBook(bybitHttp.LinearCategory, "BTCUSDT", levels(90, 100), levels(110, 100))
+
+	place(t, exchange, bybitHttp.OrderRequest{
+		Category:    bybitHttp.LinearCategory,
+		Symbol:      "BTCUSDT",
+		Side:        bybitHttp.BuyDirection,
+		OrderType:   bybitHttp.LimitOrder,
+		Qty:         "8",
+		Price:       "100",
+		OrderLinkId: "open",
+	})
+	checkBalance(t, exchange, "USDT", 20, 80)
+
+	_, err := exchange.PlaceOrder(bybitHttp.OrderRequest{
+		Category:  bybitHttp.LinearCategory,
+		Symbol:    "BTCUSDT",
+		Side:      bybitHttp.BuyDirection,
+		OrderType: bybitHttp.LimitOrder,
+		Qty:       "3",
+		Price:     "100",
+	})
+	if !errors.Is(err, bybitHttp.ErrorInsuficcientBalance) {
+		t.Fatalf("expected insufficient balance got %v", err)
+	}
+
+	// the margin of the filled order is held by the position.
+	exchange.SetOrderBook(bybitHttp.LinearCategory, "BTCUSDT", levels(90, 100), levels(100, 100))
+	if order := recorder.orders["open"]; order.OrderStatus != bybitHttp.OrderStatusFilled {
+		t.Fatalf("unexpected order status %s", order.OrderStatus)
+	}
+
+	wallet, err := exchange.GetWalletBalance(bybitHttp.WalletBalanceParams{Coin: "USDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coin := wallet.List[0].Coin[0]
+	if coin.TotalOrderIM != "0" || coin.TotalPositionIM != "80" || coin.AvailableToWithdraw != "20" || coin.WalletBalance != "100" {
+		t.Fatalf("unexpected balance %+v", coin)
+	}
+
+	// closing the position doesn't require margin.
+	exchange.SetOrderBook(bybitHttp.LinearCategory, "BTCUSDT", levels(105, 100), levels(106, 100))
+	place(t, exchange, bybitHttp.OrderRequest{
+		Category:  bybitHttp.LinearCategory,
+		Symbol:    "BTCUSDT",
+		Side:      bybitHttp.SellDirection,
+		OrderType: bybitHttp.MarketOrder,
+		Qty:       "8",
+	})
+	checkBalance(t, exchange, "USDT", 140, 0)
+}
+
+func TestOrderBookDeltas(t *testing.T) {
+	exchange, recorder := newExchange(t, paper.WithBalance("USDT", 1000))
+	ctx := context.Background()
+
+	book := func(msgType string, updateID int64, asks [][]string) bybitWs.OrderbookResponse {
+		return bybitWs.OrderbookResponse{
+			Topic: bybitWs.OrderbookTopicFor(50, "BTCUSDT"),
+			Type:  msgType,
+			Data:  &bybitWs.OrderbookData{Symbol: "BTCUSDT", Bids: [][]string{{"90", "1"}}, Asks: asks, UpdateID: updateID},
+		}
+	}
+
+	err := exchange.ProcessMsg(ctx, book(bybitWs.SnapshotType, 1, [][]string{{"110", "1"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	place(t, exchange, bybitHttp.OrderRequest{
+		Category:    bybitHttp.SpotCategory,
+		Symbol:      "BTCUSDT",
+		Side:        bybitHttp.BuyDirection,
+		OrderType:   bybitHttp.LimitOrder,
+		Qty:         "1",
+		Price:       "100",
+		OrderLinkId: "buy",
+	})
+
+	// a delta adding a level crossing the resting order fills it.
+	err = exchange.ProcessMsg(ctx, book(bybitWs.DeltaType, 2, [][]string{{"100", "1"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if order := recorder.orders["buy"]; order.OrderStatus != bybitHttp.OrderStatusFilled {
+		t.Fatalf("expected a fill of the delta got status %s", order.OrderStatus)
+	}
+
+	// the next delta restores the liquidity taken by the order.
+	err = exchange.ProcessMsg(ctx, book(bybitWs.DeltaType, 3, [][]string{{"110", "2"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tickers, err := exchange.GetTickers(bybitHttp.TickerParams{Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tickers[0].Ask1Price != "100" || tickers[0].Ask1Size != "1" {
+		t.Fatalf("unexpected best ask %s@%s", tickers[0].Ask1Size, tickers[0].Ask1Price)
+	}
+}
+
+func TestBooksByCategory(t *testing.T) {
+	exchange, _ := newExchange(t, paper.WithBalance("USDT", 1000))
+
+	ticker := func(category bybitWs.CoverType, price string) {
+		ctx := bybitWs.ContextWithEndpoint(context.Background(), bybitWs.Endpoint{Channel: bybitWs.PublicChannel, Category: category})
+		err := exchange.ProcessMsg(ctx, bybitWs.TickersResponse{
+			Topic: "tickers.BTCUSDT",
+			Data:  &bybitWs.TickersData{Symbol: "BTCUSDT", LastPrice: price},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ticker(bybitWs.Spot, "100")
+
+	_, err := exchange.PlaceOrder(bybitHttp.OrderRequest{
+		Category:  bybitHttp.LinearCategory,
+		Symbol:    "BTCUSDT",
+		Side:      bybitHttp.BuyDirection,
+		OrderType: bybitHttp.MarketOrder,
+		Qty:       "1",
+	})
+	if !errors.Is(err, paper.ErrorNoLiquidity) {
+		t.Fatalf("expected no linear liquidity got %v", err)
+	}
+
+	ticker(bybitWs.Linear, "110")
+
+	for category, expected := range map[string]string{bybitHttp.SpotCategory: "100", bybitHttp.LinearCategory: "110"} {
+		tickers, err := exchange.GetTickers(bybitHttp.TickerParams{Category: category, Symbol: "BTCUSDT"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(tickers) != 1 || tickers[0].LastPrice != expected {
+			t.Fatalf("unexpected %s tickers %+v", category, tickers)
+		}
+	}
+
+	if price, _ := exchange.BestPrice(bybitHttp.BuyDirection, "BTCUSDT"); price != 100 {
+		t.Fatalf("expected the spot best price got %v", price)
+	}
+}
+
+func TestPlaceCascadeOrders(t *testing.T) {
+	// the orders are placed concurrently, without recorder.
+	exchange := paper.New(paper.WithBalance("USDT", 1e5))
+	exchange.SetOrderBook(bybitHttp.SpotCategory, "BTCUSDT", levels(99, 10), levels(100, 10))
+
+	cfg := &config.AppConfig{}
+	cfg.ByBit.APIKey, cfg.ByBit.APISecret = "key", "secret"
+
+	client, err := bybitHttp.New(cfg, bybitHttp.WithExchange(exchange))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cascade buys from the best ask down, the first order is filled.
+	err = client.PlaceCascadeOrders(bybitHttp.BuyDirection, "BTC", 1, 2)
+	if err != nil {
+		t.Fatalf("placing cascade orders: %v", err)
+	}
+
+	orders, err := exchange.OpenOrders(bybitHttp.HistoryParams{Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orders) != bybitHttp.DeafaultPlaceOrdersQty-1 {
+		t.Fatalf("expected %d resting orders got %d", bybitHttp.DeafaultPlaceOrdersQty-1, len(orders))
+	}
+
+	checkBalance(t, exchange, "BTC", 0.1*(1-paper.DefaultTakerFee), 0)
+}
@@ -0,0 +1,649 @@
+package paper
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"github.com/google/uuid"
+)
+
+// unlimited size of the levels built from the last price of a ticker.
+const unlimited = math.MaxFloat64
+
+// reasons of the orders cancelled by the exchange, as reported by bybit.
+const (
+	rejectPostOnly    = "EC_PostOnlyWillTakeLiquidity"
+	rejectNoFill      = "EC_CancelForNoFullFill"
+	rejectNoLiquidity = "EC_NoImmediateQtyToFill"
+)
+
+type book struct {
+	bids []bybitWs.PriceLevel
+	asks []bybitWs.PriceLevel
+	last float64
+}
+
+type order struct {
+	category    string
+	symbol      string
+	base        string
+	quote       string
+	orderID     string
+	orderLinkID string
+	side        string
+	orderType   string
+	timeInForce string
+	reduceOnly  bool
+	price       float64
+	qty         float64
+	cumQty      float64
+	cumValue    float64
+	cumFee      float64
+	// locked amount of the balance of the base coin for spot sells and of
+	// the quote coin otherwise.
+	locked       float64
+	status       string
+	rejectReason string
+	created      time.Time
+	updated      time.Time
+}
+
+// key of the book and the position of the order.
+func (o *order) key() instrument {
+	return instrument{category: o.category, symbol: o.symbol}
+}
+
+func (o *order) open() bool {
+	return o.status == bybitHttp.OrderStatusNew || o.status == bybitHttp.OrderStatusPartiallyFilled
+}
+
+func (o *order) spot() bool {
+	return o.category == bybitHttp.SpotCategory
+}
+
+func (o *order) remaining() float64 {
+	return o.qty - o.cumQty
+}
+
+// crosses reports whether the order can be executed at price.
+func (o *order) crosses(price float64) bool {
+	if o.orderType == bybitHttp.MarketOrder {
+		return true
+	}
+
+	if o.side == bybitHttp.BuyDirection {
+		return price <= o.price
+	}
+
+	return price >= o.price
+}
+
+func (o *order) data() bybitWs.OrderData {
+	avgPrice := 0.0
+	if o.cumQty > 0 {
+		avgPrice = o.cumValue / o.cumQty
+	}
+
+	feeCurrency := o.quote
+	if o.spot() && o.side == bybitHttp.BuyDirection {
+		feeCurrency = o.base
+	}
+
+	leaves := 0.0
+	if o.open() {
+		leaves = o.remaining()
+	}
+
+	return bybitWs.OrderData{
+		Category:    o.category,
+		FeeCurrency: feeCurrency,
+		Order: bybitHttp.Order{
+			Symbol:       o.symbol,
+			OrderType:    o.orderType,
+			OrderLinkID:  o.orderLinkID,
+			OrderID:      o.orderID,
+			AvgPrice:     formatFloat(avgPrice),
+			OrderStatus:  o.status,
+			CumExecValue: formatFloat(o.cumValue),
+			RejectReason: o.rejectReason,
+			Price:        formatFloat(o.price),
+			CreatedTime:  formatTime(o.created),
+			TimeInForce:  o.timeInForce,
+			LeavesValue:  formatFloat(leaves * o.price),
+			UpdatedTime:  formatTime(o.updated),
+			Side:         o.side,
+			CumExecFee:   formatFloat(o.cumFee),
+			LeavesQty:    formatFloat(leaves),
+			CumExecQty:   formatFloat(o.cumQty),
+			ReduceOnly:   o.reduceOnly,
+			Qty:          formatFloat(o.qty),
+		},
+	}
+}
+
+// place validates request, locks the balance or the initial margin
+// required by the order and executes it. Must be called with the lock held.
+func (e *Exchange) place(request bybitHttp.OrderRequest, ev *events) (*order, error) {
+	o, err := e.newOrder(request)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := e.books[o.key()]
+	if o.orderType == bybitHttp.MarketOrder && (!ok || len(b.opposite(o.side)) == 0) {
+		return nil, fmt.Errorf("%w: %s", ErrorNoLiquidity, o.symbol)
+	}
+
+	if o.reduceOnly {
+		err = e.reduce(o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = e.lock(o, e.required(o))
+	if err != nil {
+		return nil, err
+	}
+
+	e.orders[o.orderID] = o
+	if o.orderLinkID != "" {
+		e.linkIDs[o.orderLinkID] = o.orderID
+	}
+
+	e.execute(o, ev)
+
+	return o, nil
+}
+
+func (e *Exchange) newOrder(request bybitHttp.OrderRequest) (*order, error) {
+	if request.Category != bybitHttp.SpotCategory && request.Category != bybitHttp.LinearCategory {
+		return nil, fmt.Errorf("%w: unsupported category %q", ErrorInvalidOrder, request.Category)
+	}
+
+	if request.Side != bybitHttp.BuyDirection && request.Side != bybitHttp.SellDirection {
+		return nil, fmt.Errorf("%w: invalid side %q", ErrorInvalidOrder, request.Side)
+	}
+
+	if request.OrderType != bybitHttp.MarketOrder && request.OrderType != bybitHttp.LimitOrder {
+		return nil, fmt.Errorf("%w: invalid order type %q", ErrorInvalidOrder, request.OrderType)
+	}
+
+	qty, err := strconv.ParseFloat(request.Qty, 64)
+	if err != nil || qty <= 0 {
+		return nil, fmt.Errorf("%w: invalid qty %q", ErrorInvalidOrder, request.Qty)
+	}
+
+	price := 0.0
+	if request.OrderType == bybitHttp.LimitOrder {
+		price, err = strconv.ParseFloat(request.Price, 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("%w: invalid price %q", ErrorInvalidOrder, request.Price)
+		}
+	}
+
+	if _, ok := e.linkIDs[request.OrderLinkId]; ok {
+		return nil, fmt.Errorf("%w: duplicated orderLinkId %s", ErrorInvalidOrder, request.OrderLinkId)
+	}
+
+	base, quote, err := e.split(request.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	timeInForce := request.TimeInForce
+	switch {
+	case request.OrderType == bybitHttp.MarketOrder:
+		timeInForce = bybitHttp.ImmediateOrCancel
+	case timeInForce == "":
+		timeInForce = bybitHttp.GoodTillCancel
+	}
+
+	now := e.now()
+
+	return &order{
+		category:    request.Category,
+		symbol:      request.Symbol,
+		base:        base,
+		quote:       quote,
+		orderID:     uuid.New().String(),
+		orderLinkID: request.OrderLinkId,
+		side:        request.Side,
+		orderType:   request.OrderType,
+		timeInForce: timeInForce,
+		reduceOnly:  request.ReduceOnly,
+		price:       price,
+		qty:         qty,
+		status:      bybitHttp.OrderStatusNew,
+		created:     now,
+		updated:     now,
+	}, nil
+}
+
+// required balance to lock for the remaining quantity of the order. Linear
+// orders require the initial margin and the fee to open of the quantity
+// that doesn't close the position, reduce only orders don't require any.
+func (e *Exchange) required(o *order) float64 {
+	b := e.books[o.key()]
+	if o.spot() {
+		if o.side == bybitHttp.SellDirection {
+			return o.remaining()
+		}
+
+		return o.value(b, o.remaining())
+	}
+
+	if o.reduceOnly {
+		return 0
+	}
+
+	qty := o.remaining()
+	if p, ok := e.positions[o.key()]; ok && p.size != 0 && (p.size > 0) != (o.side == bybitHttp.BuyDirection) {
+		qty = math.Max(0, qty-abs(p.size))
+	}
+
+	return o.value(b, qty) * (1/e.leverage + e.fees.Taker)
+}
+
+// value of qty at the price of the order, market orders are estimated
+// walking the book.
+func (o *order) value(b *book, qty float64) float64 {
+	if o.orderType == bybitHttp.LimitOrder {
+		return qty * o.price
+	}
+
+	if b == nil {
+		return 0
+	}
+
+	cost := 0.0
+	for _, level := range b.opposite(o.side) {
+		if qty <= 0 {
+			break
+		}
+
+		filled := math.Min(qty, level.Size)
+		cost += filled * level.Price
+		qty -= filled
+	}
+
+	return cost
+}
+
+// lock sets the balance locked by the order to amount, returning the excess
+// to the available balance.
+func (e *Exchange) lock(o *order, amount float64) error {
+	coin := o.quote
+	if o.spot() && o.side == bybitHttp.SellDirection {
+		coin = o.base
+	}
+
+	diff := amount - o.locked
+	b := e.balance(coin)
+	if diff > b.free {
+		return fmt.Errorf("%w: %s available: %f required: %f", bybitHttp.ErrorInsuficcientBalance, coin, b.free, diff)
+	}
+
+	b.free -= diff
+	b.locked += diff
+	o.locked = amount
+
+	return nil
+}
+
+// reduce limits the quantity of a reduce only order to the size of the position.
+func (e *Exchange) reduce(o *order) error {
+	p, ok := e.positions[o.key()]
+	if o.spot() || !ok || p.size == 0 || (p.size > 0) == (o.side == bybitHttp.BuyDirection) {
+		return fmt.Errorf("%w: reduce only order without position to reduce", ErrorInvalidOrder)
+	}
+
+	o.qty = math.Min(o.qty, abs(p.size))
+
+	return nil
+}
+
+// execute the order against the book as taker, the remaining quantity
+// rests in the book or is cancelled depending on the time in force.
+func (e *Exchange) execute(o *order, ev *events) {
+	defer func() { ev.orders = append(ev.orders, o.data()) }()
+
+	b, ok := e.books[o.key()]
+	if !ok {
+		b = &book{}
+		e.books[o.key()] = b
+	}
+
+	levels := b.opposite(o.side)
+	switch o.timeInForce {
+	case bybitHttp.PostOnly:
+		if len(levels) > 0 && o.crosses(levels[0].Price) {
+			e.finish(o, bybitHttp.OrderStatusCancelled, rejectPostOnly)
+		}
+
+		return
+	case bybitHttp.FillOrKill:
+		if available(levels, o) < o.remaining() {
+			e.finish(o, bybitHttp.OrderStatusCancelled, rejectNoFill)
+
+			return
+		}
+	}
+
+	for len(levels) > 0 && o.remaining() > 0 && o.crosses(levels[0].Price) {
+		qty := math.Min(o.remaining(), levels[0].Size)
+		e.fill(o, levels[0].Price, qty, false, ev)
+
+		if levels[0].Size != unlimited {
+			levels[0].Size -= qty
+		}
+
+		if levels[0].Size <= 0 {
+			levels = levels[1:]
+		}
+	}
+	b.setOpposite(o.side, levels)
+
+	switch {
+	case !o.open():
+	case o.timeInForce == bybitHttp.ImmediateOrCancel || o.timeInForce == bybitHttp.FillOrKill:
+		reason := rejectNoLiquidity
+		if o.cumQty > 0 {
+			reason = ""
+		}
+
+		e.finish(o, bybitHttp.OrderStatusCancelled, reason)
+	default:
+		// the lock of a partially filled order follows its remaining quantity.
+		_ = e.lock(o, e.required(o))
+	}
+}
+
+// matchResting executes the resting orders of key crossing its book as maker.
+func (e *Exchange) matchResting(key instrument, ev *events) {
+	b := e.books[key]
+
+	resting := make([]*order, 0)
+	for _, o := range e.orders {
+		if o.key() == key && o.open() {
+			resting = append(resting, o)
+		}
+	}
+	sort.Slice(resting, func(i, j int) bool { return resting[i].created.Before(resting[j].created) })
+
+	for _, o := range resting {
+		levels := b.opposite(o.side)
+		filled := false
+		for len(levels) > 0 && o.remaining() > 0 && o.crosses(levels[0].Price) {
+			qty := math.Min(o.remaining(), levels[0].Size)
+			e.fill(o, o.price, qty, true, ev)
+			filled = true
+
+			if levels[0].Size != unlimited {
+				levels[0].Size -= qty
+			}
+
+			if levels[0].Size <= 0 {
+				levels = levels[1:]
+			}
+		}
+		b.setOpposite(o.side, levels)
+
+		if filled {
+			ev.orders = append(ev.orders, o.data())
+		}
+	}
+}
+
+// fill settles the execution of qty at price.
+func (e *Exchange) fill(o *order, price, qty float64, maker bool, ev *events) {
+	rate := e.fees.Taker
+	if maker {
+		rate = e.fees.Maker
+	}
+
+	value := price * qty
+	fee, pnl := 0.0, 0.0
+	switch {
+	case o.spot() && o.side == bybitHttp.BuyDirection:
+		e.settle(o, o.quote, value)
+		fee = qty * rate
+		e.balance(o.base).free += qty - fee
+	case o.spot():
+		e.settle(o, o.base, qty)
+		fee = value * rate
+		e.balance(o.quote).free += value - fee
+	default:
+		fee = value * rate
+		pnl = e.updatePosition(o, price, qty)
+		e.balance(o.quote).free += pnl - fee
+	}
+
+	now := e.now()
+	o.cumQty += qty
+	o.cumValue += value
+	o.cumFee += fee
+	o.updated = now
+
+	if !o.spot() {
+		// the margin of the filled quantity is held by the position.
+		_ = e.lock(o, e.required(o))
+	}
+
+	status := bybitHttp.OrderStatusPartiallyFilled
+	if o.remaining() <= 0 {
+		status = bybitHttp.OrderStatusFilled
+	}
+
+	if b, ok := e.books[o.key()]; ok {
+		b.last = price
+	}
+
+	e.execSeq++
+	ev.executions = append(ev.executions, bybitWs.ExecutionData{
+		Category:    o.category,
+		Symbol:      o.symbol,
+		OrderID:     o.orderID,
+		OrderLinkID: o.orderLinkID,
+		Side:        o.side,
+		OrderPrice:  formatFloat(o.price),
+		OrderQty:    formatFloat(o.qty),
+		LeavesQty:   formatFloat(o.remaining()),
+		OrderType:   o.orderType,
+		ExecFee:     formatFloat(fee),
+		ExecID:      uuid.New().String(),
+		ExecPrice:   formatFloat(price),
+		ExecQty:     formatFloat(qty),
+		ExecPnl:     formatFloat(pnl),
+		ExecType:    "Trade",
+		ExecValue:   formatFloat(value),
+		ExecTime:    formatTime(now),
+		IsMaker:     maker,
+		FeeRate:     formatFloat(rate),
+		Seq:         e.execSeq,
+	})
+
+	if status == bybitHttp.OrderStatusFilled {
+		e.finish(o, status, "")
+	} else {
+		o.status = status
+	}
+}
+
+// settle takes amount of coin from the locked balance of the order, any
+// shortfall of an estimated lock is taken from the available balance.
+func (e *Exchange) settle(o *order, coin string, amount float64) {
+	b := e.balance(coin)
+	fromLock := math.Min(amount, o.locked)
+	o.locked -= fromLock
+	b.locked -= fromLock
+	b.free -= amount - fromLock
+}
+
+// updatePosition applies the execution to the position of the category and
+// symbol of the order, moves its margin and returns the realised pnl.
+func (e *Exchange) updatePosition(o *order, price, qty float64) float64 {
+	now := e.now()
+	p, ok := e.positions[o.key()]
+	if !ok || p.size == 0 {
+		p = &position{created: now}
+		e.positions[o.key()] = p
+	}
+	p.updated = now
+	defer e.holdMargin(p, o.quote)
+
+	signed := qty
+	if o.side == bybitHttp.SellDirection {
+		signed = -qty
+	}
+
+	if p.size == 0 || (p.size > 0) == (signed > 0) {
+		size := abs(p.size)
+		p.avgPrice = (p.avgPrice*size + price*qty) / (size + qty)
+		p.size += signed
+
+		return 0
+	}
+
+	closing := math.Min(qty, abs(p.size))
+	pnl := (price - p.avgPrice) * closing
+	if p.size < 0 {
+		pnl = -pnl
+	}
+
+	p.realisedPnl += pnl
+	p.size += signed
+	switch {
+	case math.Abs(p.size) < 1e-12:
+		p.size, p.avgPrice = 0, 0
+	case qty > closing:
+		// the position was reversed.
+		p.avgPrice = price
+	}
+
+	return pnl
+}
+
+// holdMargin sets the margin held by the position to its initial margin,
+// taken from or returned to the available balance of coin.
+func (e *Exchange) holdMargin(p *position, coin string) {
+	margin := abs(p.size) * p.avgPrice / e.leverage
+	b := e.balance(coin)
+	b.free -= margin - p.margin
+	b.margin += margin - p.margin
+	p.margin = margin
+}
+
+// finish closes the order returning its locked balance.
+func (e *Exchange) finish(o *order, status, reason string) {
+	if o.locked > 0 {
+		_ = e.lock(o, 0)
+	}
+
+	o.status = status
+	o.rejectReason = reason
+	o.updated = e.now()
+
+	delete(e.orders, o.orderID)
+	if o.orderLinkID != "" {
+		delete(e.linkIDs, o.orderLinkID)
+	}
+}
+
+// find an open order by its id or its order link id
+func (e *Exchange) find(orderID, orderLinkID string) (*order, error) {
+	if orderID == "" {
+		orderID = e.linkIDs[orderLinkID]
+	}
+
+	o, ok := e.orders[orderID]
+	if !ok || !o.open() {
+		return nil, fmt.Errorf("%w: orderId %q orderLinkId %q", ErrorOrderNotFound, orderID, orderLinkID)
+	}
+
+	return o, nil
+}
+
+func (e *Exchange) amend(amend bybitHttp.AmendRequest, ev *events) (*order, error) {
+	o, err := e.find(amend.OrderID, amend.OrderLinkId)
+	if err != nil {
+		return nil, err
+	}
+
+	qty, price := o.qty, o.price
+	if amend.Qty != "" {
+		qty, err = strconv.ParseFloat(amend.Qty, 64)
+		if err != nil || qty <= o.cumQty {
+			return nil, fmt.Errorf("%w: invalid qty %q", ErrorInvalidOrder, amend.Qty)
+		}
+	}
+
+	if amend.Price != "" {
+		price, err = strconv.ParseFloat(amend.Price, 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("%w: invalid price %q", ErrorInvalidOrder, amend.Price)
+		}
+	}
+
+	previousQty, previousPrice := o.qty, o.price
+	o.qty, o.price = qty, price
+	err = e.lock(o, e.required(o))
+	if err != nil {
+		o.qty, o.price = previousQty, previousPrice
+
+		return nil, err
+	}
+
+	o.updated = e.now()
+	e.execute(o, ev)
+
+	return o, nil
+}
+
+func (e *Exchange) cancel(orderID, orderLinkID string, ev *events) (*order, error) {
+	o, err := e.find(orderID, orderLinkID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.finish(o, bybitHttp.OrderStatusCancelled, "")
+	ev.orders = append(ev.orders, o.data())
+
+	return o, nil
+}
+
+// available quantity of levels at prices accepted by the order.
+func available(levels []bybitWs.PriceLevel, o *order) float64 {
+	total := 0.0
+	for _, level := range levels {
+		if !o.crosses(level.Price) {
+			break
+		}
+
+		total += level.Size
+	}
+
+	return total
+}
+
+// opposite levels to an order of side.
+func (b *book) opposite(side string) []bybitWs.PriceLevel {
+	if side == bybitHttp.BuyDirection {
+		return b.asks
+	}
+
+	return b.bids
+}
+
+func (b *book) setOpposite(side string, levels []bybitWs.PriceLevel) {
+	if side == bybitHttp.BuyDirection {
+		b.asks = levels
+	} else {
+		b.bids = levels
+	}
+}
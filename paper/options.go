@@ -0,0 +1,69 @@
+package paper
+
+import (
+	"time"
+
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// Option allows to customize the Exchange created with New
+type Option func(*Exchange)
+
+// WithFees sets the fee rates, by default DefaultMakerFee and DefaultTakerFee
+func WithFees(fees Fees) Option {
+	return func(e *Exchange) {
+		e.fees = fees
+	}
+}
+
+// WithBalance sets the initial available balance of coin
+func WithBalance(coin string, amount float64) Option {
+	return func(e *Exchange) {
+		e.balance(coin).free = amount
+	}
+}
+
+// WithDispatcher emits the order and execution events through dispatcher,
+// as the websocket client does with the messages of the private channel.
+func WithDispatcher(dispatcher *bybitWs.Dispatcher) Option {
+	return func(e *Exchange) {
+		e.dispatcher = dispatcher
+	}
+}
+
+// WithOrderBooks matches the orders against the books maintained by books,
+// every update of a book is applied with SetOrderBook in its category. The order book
+// messages passed to ProcessMsg are applied to books too, so register
+// either books or the exchange as the handler of the orderbook topics.
+func WithOrderBooks(books *bybitWs.OrderBooks) Option {
+	return func(e *Exchange) {
+		e.orderBooks = books
+	}
+}
+
+// WithLeverage sets the leverage of linear positions, by default
+// DefaultLeverage. Linear orders reserve the value of the positions they
+// open divided by leverage as initial margin, non positive values are ignored.
+func WithLeverage(leverage float64) Option {
+	return func(e *Exchange) {
+		if leverage > 0 {
+			e.leverage = leverage
+		}
+	}
+}
+
+// WithQuoteCoins sets the coins used to split symbols into base and quote
+// coins, by default DefaultQuoteCoins.
+func WithQuoteCoins(coins ...string) Option {
+	return func(e *Exchange) {
+		e.quotes = coins
+	}
+}
+
+// WithClock sets the source of the time of orders and executions, e.g. the
+// time of the market data replayed in a backtest.
+func WithClock(now func() time.Time) Option {
+	return func(e *Exchange) {
+		e.now = now
+	}
+}
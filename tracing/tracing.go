@@ -0,0 +1,284 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	InstrumentationName = "github.com/Gealber/bybit"
+
+	// MaxLinkedOrders number of orders whose span is remembered to link
+	// websocket messages with the REST call that created the order.
+	MaxLinkedOrders = 10000
+)
+
+// attributes set in the spans.
+const (
+	EndpointKey    = attribute.Key("bybit.endpoint")
+	CategoryKey    = attribute.Key("bybit.category")
+	SymbolKey      = attribute.Key("bybit.symbol")
+	OrderLinkIDKey = attribute.Key("bybit.order_link_id")
+	OrderIDKey     = attribute.Key("bybit.order_id")
+	RetCodeKey     = attribute.Key("bybit.ret_code")
+	RetMsgKey      = attribute.Key("bybit.ret_msg")
+	TopicKey       = attribute.Key("bybit.topic")
+	StatusCodeKey  = attribute.Key("http.status_code")
+	MethodKey      = attribute.Key("http.method")
+)
+
+// OrderLinked is implemented by websocket messages related with orders,
+// its span is linked with the span of the REST call that created the order,
+// found by the orderLinkId sent or the orderId returned.
+type OrderLinked interface {
+	OrderLinkIDs() []string
+	OrderIDs() []string
+}
+
+// orderKey identifies an order by its orderLinkId or its orderId.
+type orderKey struct {
+	id   string
+	link bool
+}
+
+// Tracer creates spans for REST calls and websocket messages.
+// Use Middleware with http.WithMiddleware and wrap websocket handlers with Handler.
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu         sync.Mutex
+	orderSpans map[orderKey]trace.SpanContext
+	orderQueue []orderKey
+}
+
+// New creates a Tracer using the provider tp
+func New(tp trace.TracerProvider) *Tracer {
+	return &Tracer{
+		tracer:     tp.Tracer(InstrumentationName),
+		orderSpans: make(map[orderKey]trace.SpanContext),
+	}
+}
+
+// requestParams fields used as attributes of the span, present in the query or the body.
+type requestParams struct {
+	Category    string `json:"category"`
+	Symbol      string `json:"symbol"`
+	OrderLinkId string `json:"orderLinkId"`
+}
+
+// Middleware creates a span for every REST call, child of the span in the
+// context given to http.Client.WithContext.
+func (t *Tracer) Middleware() bybitHttp.Middleware {
+	return func(next bybitHttp.Invoker) bybitHttp.Invoker {
+		return func(call *bybitHttp.Call, objResp any) error {
+			ctx, span := t.tracer.Start(
+				call.Request.Context(),
+				"bybit "+call.Endpoint,
+				trace.WithSpanKind(trace.SpanKindClient),
+			)
+			defer span.End()
+
+			params := extractParams(call)
+			span.SetAttributes(
+				EndpointKey.String(call.Endpoint),
+				MethodKey.String(call.Request.Method),
+				CategoryKey.String(params.Category),
+				SymbolKey.String(params.Symbol),
+				OrderLinkIDKey.String(params.OrderLinkId),
+			)
+
+			if params.OrderLinkId != "" {
+				t.rememberOrder(orderKey{id: params.OrderLinkId, link: true}, span.SpanContext())
+			}
+
+			call.Request = call.Request.WithContext(ctx)
+			err := next(call, objResp)
+
+			// orders without orderLinkId are found by the orderId returned.
+			for _, order := range responseOrders(objResp) {
+				if order.OrderId != "" {
+					t.rememberOrder(orderKey{id: order.OrderId}, span.SpanContext())
+				}
+
+				if order.OrderLinkId != "" {
+					t.rememberOrder(orderKey{id: order.OrderLinkId, link: true}, span.SpanContext())
+				}
+			}
+
+			span.SetAttributes(
+				StatusCodeKey.Int(call.StatusCode),
+				RetCodeKey.Int(call.Envelope.RetCode),
+				RetMsgKey.String(call.Envelope.RetMsg),
+			)
+
+			switch {
+			case err != nil:
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			case call.Envelope.RetCode != bybitHttp.RetCodeOK:
+				span.SetStatus(codes.Error, "retCode "+strconv.Itoa(call.Envelope.RetCode))
+			}
+
+			return err
+		}
+	}
+}
+
+// Handler wraps next creating a span for every message processed, the ctx
+// given to next carries the span. Messages implementing OrderLinked are
+// linked to the span of the REST call that created the order.
+func (t *Tracer) Handler(next bybitWs.Handler) bybitWs.Handler {
+	return &tracedHandler{tracer: t, next: next}
+}
+
+type tracedHandler struct {
+	tracer *Tracer
+	next   bybitWs.Handler
+}
+
+func (h *tracedHandler) ProcessMsg(ctx context.Context, obj any) error {
+	topic := bybitWs.TopicFromContext(ctx)
+
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(TopicKey.String(topic)),
+	}
+
+	if linked, ok := obj.(OrderLinked); ok {
+		opts = append(opts, trace.WithLinks(h.tracer.links(linked)...))
+	}
+
+	ctx, span := h.tracer.tracer.Start(ctx, "bybit.ws "+topic, opts...)
+	defer span.End()
+
+	err := h.next.ProcessMsg(ctx, obj)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+// ProcessEvent forwards connection events when the wrapped handler is a
+// bybitWs.EventHandler.
+func (h *tracedHandler) ProcessEvent(ctx context.Context, event bybitWs.Event) error {
+	eventHandler, ok := h.next.(bybitWs.EventHandler)
+	if !ok {
+		return nil
+	}
+
+	return eventHandler.ProcessEvent(ctx, event)
+}
+
+func (t *Tracer) rememberOrder(key orderKey, spanCtx trace.SpanContext) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.orderSpans[key]; !ok {
+		t.orderQueue = append(t.orderQueue, key)
+	}
+	t.orderSpans[key] = spanCtx
+
+	if len(t.orderQueue) > MaxLinkedOrders {
+		delete(t.orderSpans, t.orderQueue[0])
+		t.orderQueue = t.orderQueue[1:]
+	}
+}
+
+// links to the spans of the orders of linked, each span is linked once.
+func (t *Tracer) links(linked OrderLinked) []trace.Link {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	links := make([]trace.Link, 0)
+	linkedSpans := make(map[trace.SpanID]struct{})
+	add := func(key orderKey, attr attribute.KeyValue) {
+		spanCtx, ok := t.orderSpans[key]
+		if !ok {
+			return
+		}
+
+		if _, ok := linkedSpans[spanCtx.SpanID()]; ok {
+			return
+		}
+
+		linkedSpans[spanCtx.SpanID()] = struct{}{}
+		links = append(links, trace.Link{SpanContext: spanCtx, Attributes: []attribute.KeyValue{attr}})
+	}
+
+	for _, orderLinkID := range linked.OrderLinkIDs() {
+		if orderLinkID != "" {
+			add(orderKey{id: orderLinkID, link: true}, OrderLinkIDKey.String(orderLinkID))
+		}
+	}
+
+	for _, orderID := range linked.OrderIDs() {
+		if orderID != "" {
+			add(orderKey{id: orderID}, OrderIDKey.String(orderID))
+		}
+	}
+
+	return links
+}
+
+// responseOrders retrieves the orders created or modified by a call
+func responseOrders(objResp any) []bybitHttp.OrderResponse {
+	switch response := objResp.(type) {
+	case *bybitHttp.PlaceOrderResponse:
+		if response.Result != nil {
+			return []bybitHttp.OrderResponse{*response.Result}
+		}
+	case *bybitHttp.BatchOrderResponse:
+		if response.Result != nil {
+			orders := make([]bybitHttp.OrderResponse, 0, len(response.Result.List))
+			for _, item := range response.Result.List {
+				if item != nil {
+					orders = append(orders, bybitHttp.OrderResponse{OrderId: item.OrderId, OrderLinkId: item.OrderLinkId})
+				}
+			}
+
+			return orders
+		}
+	}
+
+	return nil
+}
+
+func extractParams(call *bybitHttp.Call) requestParams {
+	query := call.Request.URL.Query()
+	params := requestParams{
+		Category:    query.Get("category"),
+		Symbol:      query.Get("symbol"),
+		OrderLinkId: query.Get("orderLinkId"),
+	}
+
+	if call.Request.GetBody == nil {
+		return params
+	}
+
+	body, err := call.Request.GetBody()
+	if err != nil {
+		return params
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return params
+	}
+
+	// in case of failure the params from the query are kept.
+	_ = json.Unmarshal(data, &params)
+
+	return params
+}
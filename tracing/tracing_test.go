@@ -0,0 +1,209 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Gealber/bybit/bybittest"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/tracing"
+	bybitWs "github.com/Gealber/bybit/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTracedClient(t *testing.T) (*bybitHttp.Client, *tracing.Tracer, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := tracing.New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	server := bybittest.NewServer("test-key", "test-secret")
+	t.Cleanup(server.Close)
+
+	client, err := bybitHttp.New(server.Config(), bybitHttp.WithMiddleware(tracer.Middleware()))
+	if err != nil {
+		t.Fatalf("creating client: %v", err)
+	}
+
+	return client, tracer, recorder
+}
+
+// orderMessage is a websocket message related with orders.
+type orderMessage struct {
+	orderLinkIDs []string
+	orderIDs     []string
+}
+
+func (m orderMessage) OrderLinkIDs() []string { return m.orderLinkIDs }
+func (m orderMessage) OrderIDs() []string     { return m.orderIDs }
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	values := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		values[kv.Key] = kv.Value
+	}
+
+	return values
+}
+
+func TestMiddlewareAttributes(t *testing.T) {
+	client, _, recorder := newTracedClient(t)
+
+	_, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT", OrderLinkId: "link"})
+	if err != nil {
+		t.Fatalf("placing order: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "bybit order/create" {
+		t.Fatalf("unexpected spans %v", spans)
+	}
+
+	values := attributes(spans[0])
+	expected := map[attribute.Key]string{
+		tracing.EndpointKey:    "order/create",
+		tracing.MethodKey:      "POST",
+		tracing.CategoryKey:    "spot",
+		tracing.SymbolKey:      "BTCUSDT",
+		tracing.OrderLinkIDKey: "link",
+	}
+	for key, value := range expected {
+		if values[key].AsString() != value {
+			t.Errorf("expected %s %q got %q", key, value, values[key].Emit())
+		}
+	}
+
+	if values[tracing.StatusCodeKey].AsInt64() != 200 || values[tracing.RetCodeKey].AsInt64() != bybitHttp.RetCodeOK {
+		t.Errorf("unexpected status %v retCode %v", values[tracing.StatusCodeKey].Emit(), values[tracing.RetCodeKey].Emit())
+	}
+}
+
+func TestHandlerLinksOrders(t *testing.T) {
+	client, tracer, recorder := newTracedClient(t)
+
+	// one order found by its orderLinkId and another one by its orderId.
+	_, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT", OrderLinkId: "link"})
+	if err != nil {
+		t.Fatalf("placing order: %v", err)
+	}
+
+	response, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+	if err != nil {
+		t.Fatalf("placing order: %v", err)
+	}
+
+	created := recorder.Ended()
+	var processed bool
+	handler := tracer.Handler(processFunc(func(ctx context.Context, obj any) error {
+		processed = true
+
+		return nil
+	}))
+
+	msg := orderMessage{
+		orderLinkIDs: []string{"link", "", ""},
+		// a second execution of an order is linked once.
+		orderIDs: []string{"unknown", response.OrderId, response.OrderId},
+	}
+
+	err = handler.ProcessMsg(bybitWs.ContextWithTopic(context.Background(), "execution"), msg)
+	if err != nil {
+		t.Fatalf("processing: %v", err)
+	}
+
+	spans := recorder.Ended()
+	span := spans[len(spans)-1]
+	if !processed {
+		t.Fatal("expected the message to be processed")
+	}
+
+	if span.Name() != "bybit.ws execution" || attributes(span)[tracing.TopicKey].AsString() != "execution" {
+		t.Fatalf("unexpected span %s %v", span.Name(), span.Attributes())
+	}
+
+	links := span.Links()
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links got %d", len(links))
+	}
+
+	for i, link := range links {
+		if link.SpanContext.SpanID() != created[i].SpanContext().SpanID() {
+			t.Fatalf("link %d to span %s instead of %s", i, link.SpanContext.SpanID(), created[i].SpanContext().SpanID())
+		}
+	}
+
+	if links[0].Attributes[0] != tracing.OrderLinkIDKey.String("link") || links[1].Attributes[0] != tracing.OrderIDKey.String(response.OrderId) {
+		t.Fatalf("unexpected link attributes %v %v", links[0].Attributes, links[1].Attributes)
+	}
+}
+
+func TestHandlerLinksBatchOrders(t *testing.T) {
+	client, tracer, recorder := newTracedClient(t)
+
+	items, err := client.PlaceBatchOrders(bybitHttp.BatchOrderRequest{
+		Category: "linear",
+		Request: []bybitHttp.OrderRequest{
+			{Symbol: "BTCUSDT", OrderLinkId: "a"},
+			{Symbol: "ETHUSDT"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("placing orders: %v", err)
+	}
+
+	batch := recorder.Ended()[0]
+	handler := tracer.Handler(processFunc(func(context.Context, any) error { return nil }))
+
+	// both orders of the batch are linked to the span of the request.
+	for _, msg := range []orderMessage{{orderLinkIDs: []string{"a"}}, {orderIDs: []string{items[1].OrderId}}} {
+		err = handler.ProcessMsg(bybitWs.ContextWithTopic(context.Background(), "order"), msg)
+		if err != nil {
+			t.Fatalf("processing: %v", err)
+		}
+
+		spans := recorder.Ended()
+		links := spans[len(spans)-1].Links()
+		if len(links) != 1 || links[0].SpanContext.SpanID() != batch.SpanContext().SpanID() {
+			t.Fatalf("expected a link to the batch span got %v", links)
+		}
+	}
+}
+
+type processFunc func(ctx context.Context, obj any) error
+
+func (f processFunc) ProcessMsg(ctx context.Context, obj any) error {
+	return f(ctx, obj)
+}
+
+// eventRecorder records the connection events received.
+type eventRecorder struct {
+	processFunc
+	events []bybitWs.Event
+}
+
+func (r *eventRecorder) ProcessEvent(_ context.Context, event bybitWs.Event) error {
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+func TestHandlerForwardsEvents(t *testing.T) {
+	_, tracer, _ := newTracedClient(t)
+
+	recorder := &eventRecorder{}
+	handler, ok := tracer.Handler(recorder).(bybitWs.EventHandler)
+	if !ok {
+		t.Fatal("expected the traced handler to be an EventHandler")
+	}
+
+	err := handler.ProcessEvent(context.Background(), bybitWs.Event{Type: bybitWs.EventDisconnected})
+	if err != nil {
+		t.Fatalf("processing event: %v", err)
+	}
+
+	if len(recorder.events) != 1 || recorder.events[0].Type != bybitWs.EventDisconnected {
+		t.Fatalf("unexpected events %v", recorder.events)
+	}
+}
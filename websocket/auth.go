@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// AuthError returned when bybit rejects the authentication of a private connection
+type AuthError struct {
+	RetMsg string
+	ConnID string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("websocket authentication failed: %s (conn_id %s)", e.RetMsg, e.ConnID)
+}
+
+// authenticate sends the auth operation and waits for its response,
+// it must be performed before any subscription on a private connection.
+func (c *Client) authenticate(conn *websocket.Conn) error {
+	if c.APIKey == "" || c.APISecret == "" {
+		return ErrorMissingCredentials
+	}
+
+	expires := time.Now().Add(AuthExpirationTimeout * time.Second).UnixMilli()
+
+	authReq := Request{
+		Op:   AuthOp,
+		Args: []interface{}{c.APIKey, expires, c.signAuth(expires)},
+	}
+
+	err := conn.WriteJSON(&authReq)
+	if err != nil {
+		return fmt.Errorf("sending auth %w", err)
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(AuthExpirationTimeout * time.Second))
+	if err != nil {
+		return err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	// skip any other message, e.g. the pong of the first ping.
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("reading auth response %w", err)
+		}
+
+		var resp OperationResponse
+		err = json.Unmarshal(message, &resp)
+		if err != nil {
+			return err
+		}
+
+		if resp.Op != AuthOp {
+			continue
+		}
+
+		// the trade channel answers with retCode instead of success.
+		var tradeResp TradeResponse
+		_ = json.Unmarshal(message, &tradeResp)
+
+		if tradeResp.ConnID != "" {
+			resp.Success = tradeResp.RetCode == 0
+			resp.RetMsg = tradeResp.RetMsg
+			resp.ConnID = tradeResp.ConnID
+		}
+
+		if !resp.Success {
+			return &AuthError{RetMsg: resp.RetMsg, ConnID: resp.ConnID}
+		}
+
+		c.logger.Info("authenticated", "connId", resp.ConnID)
+
+		return nil
+	}
+}
+
+func (c *Client) signAuth(expires int64) string {
+	h := hmac.New(sha256.New, []byte(c.APISecret))
+	h.Write([]byte("GET/realtime" + strconv.FormatInt(expires, 10)))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
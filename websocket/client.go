@@ -2,24 +2,59 @@ package websocket
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/url"
-	"os"
-	"os/signal"
+	"sync"
 	"time"
 
 	"github.com/Gealber/bybit/config"
+	"github.com/Gealber/bybit/logging"
 
 	"github.com/gorilla/websocket"
+	"golang.org/x/sync/errgroup"
 )
 
 // Client represents connection with ByBit Websocket API.
 type Client struct {
 	APIKey    string
 	APISecret string
-	logger    *log.Logger
+	logger    *slog.Logger
+	observer  Observer
+	channel   ChannelType
+	category  CoverType
+	// baseURL scheme and host of the websocket api.
+	baseURL string
+	// tradeTimeout default timeout of TradeClient requests.
+	tradeTimeout time.Duration
+	// readTimeout max time waiting for a message, pongs included.
+	readTimeout time.Duration
+	// staleTimeouts max time without messages by topic family.
+	staleTimeouts map[string]time.Duration
+	// dispatch configuration by topic family.
+	dispatch map[string]DispatchConfig
+	recorder *Recorder
+	// shardSize max number of topics of a connection.
+	shardSize int
+
+	mu      sync.Mutex
+	running *running
+}
+
+// running state of RunDispatcher, used to subscribe while running.
+type running struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	done       chan struct{}
+	group      *errgroup.Group
+	dispatcher *Dispatcher
+	queues     *queues
+	// sessions shards of each endpoint.
+	sessions map[Endpoint][]*session
+	// streams fan out handlers of the topics read with Stream.
+	streams map[string]*streamTopic
+	stopped bool
 }
 
 // Handler for processing message
@@ -27,217 +62,367 @@ type Handler interface {
 	ProcessMsg(ctx context.Context, obj any) error
 }
 
-// NewClient creates a new websocket client
-func NewClient(cfg *config.AppConfig) *Client {
-	// bybit WS logger.
-	bybitWSLogger := log.New(os.Stdout, "[bybit-ws]", log.Lshortfile)
-
-	return &Client{
+// NewClient creates a new websocket client, opts allow to customize it
+func NewClient(cfg *config.AppConfig, opts ...ClientOption) *Client {
+	client := &Client{
 		APIKey:    cfg.ByBit.APIKey,
 		APISecret: cfg.ByBit.APISecret,
-		logger:    bybitWSLogger,
+		logger:    logging.Default("bybit-ws"),
+		observer:  nopObserver{},
+		channel:   PublicChannel,
+		category:  Spot,
+		baseURL:   "wss://" + ByBitWebsocketDomain,
+
+		tradeTimeout:  TradeRequestTimeout * time.Second,
+		readTimeout:   ReadTimeout * time.Second,
+		staleTimeouts: make(map[string]time.Duration),
+		dispatch: map[string]DispatchConfig{
+			"": {Policy: DispatchSync},
+		},
+		shardSize: ShardSize,
 	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
 }
 
 func (c *Client) path(channelType ChannelType, operation CoverType) string {
+	// private and trade channels serve every category in the same path.
+	if channelType == PrivateChannel || channelType == TradeChannel {
+		return fmt.Sprintf("/%s/%s", APIVersion, channelType)
+	}
+
 	return fmt.Sprintf("/%s/%s/%s", APIVersion, channelType, operation)
 }
 
-func (c *Client) connect() (*websocket.Conn, error) {
-	spotPath := c.path(PublicChannel, Spot)
+func (c *Client) connect(ctx context.Context, endpoint Endpoint) (*websocket.Conn, error) {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
 
-	u := url.URL{Scheme: "wss", Host: ByBitWebsocketDomain, Path: spotPath}
-	c.logger.Printf("connecting to %s", u.String())
+	u.Path = c.path(endpoint.Channel, endpoint.Category)
+	c.logger.Info("connecting", "url", u.String())
 
-	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
 
 	return conn, err
 }
 
-func sendPing(conn *websocket.Conn) error {
-	pingReq := Request{
-		ReqID: "100001",
-		Op:    "ping",
+// Run connect to bybit websocket and dispatch the messages to handlers,
+// the keys of handlers are topics or patterns accepted by Dispatcher.Handle.
+// Read RunDispatcher for more details.
+func (c *Client) Run(
+	ctx context.Context,
+	subscriptions []Request,
+	handlers map[string]Handler,
+) error {
+	dispatcher, err := NewDispatcherFromMap(handlers)
+	if err != nil {
+		return err
 	}
 
-	return conn.WriteJSON(&pingReq)
+	return c.RunDispatcher(ctx, subscriptions, dispatcher)
 }
 
-// Run connect to bybit websocket, general idea of what it does.
-// 1. Subscribe to tickers
-// 2. Read message from websocket.
-// 3. Send every 20 seconds a ping, to avoid disconnections.
-// 4. In case of abnormal close of connection, performs a reconnection.
-// 5. In case the reconnection exceed the max allowed, shut the program.
-// 6. Also listen to Ctr+C commands to shutdown gratefully.
-func (c *Client) Run(
+// RunDispatcher connect to bybit websocket, general idea of what it does.
+// 0. Validate there is a handler for every topic subscribed.
+// 1. Open connections for each endpoint (channel and category) needed by
+// the subscriptions, read Request.InCategory. The topics of an endpoint are
+// sharded among several connections of at most ShardSize topics, read
+// WithShardSize, each shard reconnects independently.
+// 2. Subscribe to topics in the connection of its endpoint, more topics
+// can be subscribed while running with Subscribe.
+// 3. Read message from websocket, passing them to the handlers according to
+// the dispatch policy of the topic, read WithDispatch.
+// 4. Send every 20 seconds a ping, to avoid disconnections.
+// 5. In case of abnormal close of connection, performs a reconnection
+// authenticating and subscribing again, handlers implementing EventHandler
+// are notified about the disconnection and the reconnection.
+// 6. In case the reconnection exceed the max allowed, shut the program.
+// 7. Shutdown gracefully when ctx is done or Close is called, closing
+// every connection with the close handshake. Signals are not captured,
+// use e.g. signal.NotifyContext to shutdown on Ctrl+C.
+// Messages from every connection are dispatched to the same dispatcher, so
+// handlers of topics in different endpoints may be called concurrently.
+func (c *Client) RunDispatcher(
 	ctx context.Context,
 	subscriptions []Request,
-	handlers map[string]Handler,
+	dispatcher *Dispatcher,
 ) error {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-	pingTicker := time.NewTicker(PingTimeout * time.Second)
-	errChn := make(chan error)
-	done := make(chan struct{})
-	var connections int = 0
-
-CONNECTION:
-	conn, err := c.connect()
+	err := dispatcher.Validate(subscriptions)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		pingTicker.Stop()
-		close(errChn)
-		conn.Close()
-	}()
 
-	connections++
+	c.mu.Lock()
+	if c.running != nil {
+		c.mu.Unlock()
 
-	go func() {
-		defer close(done)
-		err := c.processRead(ctx, done, conn, subscriptions, handlers)
-		if err != nil {
-			errChn <- err
+		return ErrorAlreadyRunning
+	}
+	state := c.start(ctx, dispatcher, subscriptions)
+	c.mu.Unlock()
 
-			return
-		}
-	}()
-
-	// wait for possible errors and interrupt signals.
-	// send ping command every PingTimeout seconds.
-	for {
-		select {
-		case err := <-errChn:
-			if waitTime, ok := retriableError(err, connections); ok {
-				if connections > MaxRetrialConnections {
-					return err
-				}
-
-				c.logger.Println("RECONNECTING....ABNORMAL CLOUSURE....")
-				c.logger.Printf("Sleeping %v milliseconds\n", waitTime)
-				time.Sleep(waitTime)
-				goto CONNECTION
-			}
+	return c.wait(state)
+}
 
-			return err
-		case <-interrupt:
-			return c.handleInterruptSignal(done, conn)
-		case <-pingTicker.C:
-			err := sendPing(conn)
-			if err != nil {
-				return err
-			}
+// start runs the sessions of subscriptions in background, must be called
+// with the lock held and the client not running.
+func (c *Client) start(ctx context.Context, dispatcher *Dispatcher, subscriptions []Request) *running {
+	ctx, cancel := context.WithCancel(ctx)
+	errsGroup, ctx := errgroup.WithContext(ctx)
+	state := &running{
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		group:      errsGroup,
+		dispatcher: dispatcher,
+		queues:     newQueues(ctx, c, dispatcher),
+		sessions:   make(map[Endpoint][]*session),
+		streams:    make(map[string]*streamTopic),
+	}
+	c.running = state
+
+	endpoints, topics := c.groupTopics(subscriptions)
+	for _, endpoint := range endpoints {
+		placed, shards := c.placeTopics(state, endpoint, topics[endpoint])
+		for _, s := range shards {
+			c.startSession(state, s, placed[s])
 		}
 	}
+
+	// keeps running without subscriptions, waiting for Subscribe.
+	errsGroup.Go(func() error {
+		<-ctx.Done()
+
+		c.mu.Lock()
+		state.stopped = true
+		c.mu.Unlock()
+
+		return nil
+	})
+
+	return state
+}
+
+// wait blocks until the sessions of state finished.
+func (c *Client) wait(state *running) error {
+	err := state.group.Wait()
+	state.queues.wait()
+	state.cancel()
+
+	c.mu.Lock()
+	c.running = nil
+	c.mu.Unlock()
+	close(state.done)
+
+	return err
+}
+
+// Close stops Run closing every connection gracefully, it returns once Run
+// returned. It's safe to call when Run is not running.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	state := c.running
+	c.mu.Unlock()
+
+	if state == nil {
+		return nil
+	}
+
+	state.cancel()
+	<-state.done
+
+	return nil
 }
 
-func (c *Client) processMsg(ctx context.Context, data []byte, handlers map[string]Handler) error {
-	var msg PublicResponse
+// Subscribe subscribes to the topics of subscriptions while Run is running,
+// waiting for the acknowledgement of bybit. The topics are subscribed again
+// after reconnections, those rejected by bybit are dropped. Topics are routed
+// to the connection of its endpoint as in Run, opening it when needed.
+func (c *Client) Subscribe(ctx context.Context, subscriptions ...Request) error {
+	c.mu.Lock()
+	state := c.running
+	if state == nil || state.stopped {
+		c.mu.Unlock()
+
+		return ErrorNotRunning
+	}
 
-	err := json.Unmarshal(data, &msg)
+	requests := make([]Request, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscription.Op = SubscribeOp
+		requests = append(requests, subscription)
+	}
+
+	err := state.dispatcher.Validate(requests)
 	if err != nil {
+		c.mu.Unlock()
+
 		return err
 	}
 
-	switch msg.Topic {
-	case TickersTONUSDTTopic:
-		tickersHandler := handlers[TickersTONUSDTTopic]
-		err := processTickerTopic(ctx, data, tickersHandler)
+	endpoints, topics := c.groupTopics(requests)
+	sessions := make([]*session, 0)
+	pending := make(map[*session]subscription)
+	for _, endpoint := range endpoints {
+		placed, shards := c.placeTopics(state, endpoint, topics[endpoint])
+		for _, s := range shards {
+			c.startSession(state, s, placed[s])
+			delete(placed, s)
+		}
+
+		// reserved while holding the lock, so concurrent calls don't overfill shards.
+		for s, topics := range placed {
+			sessions = append(sessions, s)
+			pending[s] = s.add(topics)
+		}
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range sessions {
+		err := s.subscribe(ctx, pending[s])
 		if err != nil {
-			return err
+			errs = append(errs, err)
 		}
 	}
 
-	c.logger.Printf("MSG: %s\n", string(data))
-	return nil
+	return errors.Join(errs...)
 }
 
-func (c *Client) processRead(
-	ctx context.Context,
-	done chan struct{},
-	conn *websocket.Conn,
-	subscriptions []Request,
-	handlers map[string]Handler,
-) error {
-	// first ping to send.
-	sendPing(conn)
+// Unsubscribe unsubscribes from the topics of subscriptions while Run is
+// running, waiting for the acknowledgement of bybit.
+func (c *Client) Unsubscribe(ctx context.Context, subscriptions ...Request) error {
+	c.mu.Lock()
+	state := c.running
+	if state == nil || state.stopped {
+		c.mu.Unlock()
 
-	for _, subscription := range subscriptions {
-		err := conn.WriteJSON(subscription)
+		return ErrorNotRunning
+	}
+
+	endpoints, topics := c.groupTopics(subscriptions)
+	sessions := make([]*session, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		sessions = append(sessions, state.sessions[endpoint]...)
+	}
+	c.mu.Unlock()
+
+	var errs []error
+	for _, s := range sessions {
+		err := s.unsubscribe(ctx, topics[s.endpoint])
 		if err != nil {
-			return fmt.Errorf("sending subscription %w", err)
+			errs = append(errs, err)
 		}
 	}
 
-	for {
-		select {
-		case <-done:
-			return nil
-		default:
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				return fmt.Errorf("reading %w", err)
+	return errors.Join(errs...)
+}
+
+// startSession runs the shard s subscribed to topics in the group of state,
+// must be called with the lock held.
+func (c *Client) startSession(state *running, s *session, topics []string) {
+	s.desired = topics
+	state.group.Go(func() error {
+		return s.run(state.ctx, state.dispatcher)
+	})
+}
+
+// groupTopics groups the topics of subscriptions by endpoint, endpoints are
+// returned in order of appearance.
+func (c *Client) groupTopics(subscriptions []Request) ([]Endpoint, map[Endpoint][]string) {
+	endpoints := make([]Endpoint, 0)
+	topics := make(map[Endpoint][]string)
+
+	for _, subscription := range subscriptions {
+		for _, arg := range subscription.Args {
+			topic, ok := arg.(string)
+			if !ok {
+				continue
+			}
+
+			endpoint := c.endpointFor(subscription, topic)
+			if _, ok := topics[endpoint]; !ok {
+				endpoints = append(endpoints, endpoint)
 			}
 
-			err = c.processMsg(ctx, message, handlers)
-			if err != nil {
-				c.logger.Println("ERR PROCESSING READ: ", err.Error())
+			if !contains(topics[endpoint], topic) {
+				topics[endpoint] = append(topics[endpoint], topic)
 			}
 		}
 	}
+
+	return endpoints, topics
 }
 
-func (c *Client) handleInterruptSignal(
-	done chan struct{},
-	conn *websocket.Conn,
-) error {
-	c.logger.Println("Closing connection...it might take a few seconds")
-	done <- struct{}{}
-	// Cleanly close the connection by sending a close message and then
-	// waiting (with timeout) for the server to close the connection.
-	err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	if err != nil {
-		return err
+// endpointFor resolves the endpoint serving topic, private topics are
+// always served by the private channel.
+func (c *Client) endpointFor(subscription Request, topic string) Endpoint {
+	channel := subscription.Channel
+	if channel == "" {
+		channel = c.channel
+		if isPrivateTopic(topic) {
+			channel = PrivateChannel
+		}
 	}
 
-	select {
-	case <-time.After(time.Second):
+	if channel == PrivateChannel || channel == TradeChannel {
+		return Endpoint{Channel: channel}
 	}
 
-	return nil
-}
+	category := subscription.Category
+	if category == "" {
+		category = c.category
+	}
 
-func processTickerTopic(ctx context.Context, data []byte, handler Handler) error {
-	var tickersMsg TickersResponse
+	return Endpoint{Channel: channel, Category: category}
+}
 
-	err := json.Unmarshal(data, &tickersMsg)
-	if err != nil {
-		return err
-	}
+// staleTimeout of topic, set for the longest family matching the topic.
+func (c *Client) staleTimeout(topic string) (time.Duration, bool) {
+	timeout, ok := matchFamily(c.staleTimeouts, topic)
 
-	return handler.ProcessMsg(ctx, tickersMsg)
+	return timeout, ok && timeout > 0
 }
 
+// retriableError reports whether the session should reconnect after err,
+// and how long to wait before doing it.
 func retriableError(err error, connections int) (time.Duration, bool) {
 	waitTime := time.Duration(connections) * 500 * time.Millisecond
+
+	var authErr *AuthError
+	if errors.As(err, &authErr) || errors.Is(err, ErrorMissingCredentials) {
+		return waitTime, false
+	}
+
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		// network errors, e.g. connection reset by peer or read timeout.
+		return waitTime, true
+	}
+
+	switch closeErr.Code {
 	// 1006 is a reserved value and MUST NOT be set as a status code in a
 	// Close control frame by an endpoint.  It is designated for use in
 	// applications expecting a status code to indicate that the
 	// connection was closed abnormally, e.g., without sending or
 	// receiving a Close control frame
-	isAbnormalClosure := websocket.IsUnexpectedCloseError(err, websocket.CloseAbnormalClosure)
-	// 1001 indicates that an endpoint is "going away", such as a server
-	// going down or a browser having navigated away from a page.
-	isGoingAway := websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway)
-
-	isRetriable := isAbnormalClosure || isGoingAway
-
-	if websocket.IsUnexpectedCloseError(err, websocket.CloseTryAgainLater) {
-		isRetriable = true
-		waitTime = time.Duration(connections) * time.Second
+	case websocket.CloseAbnormalClosure,
+		// 1001 indicates that an endpoint is "going away", such as a server
+		// going down or a browser having navigated away from a page.
+		websocket.CloseGoingAway,
+		websocket.CloseNormalClosure,
+		websocket.CloseInternalServerErr,
+		websocket.CloseServiceRestart:
+		return waitTime, true
+	case websocket.CloseTryAgainLater:
+		return time.Duration(connections) * time.Second, true
 	}
 
-	return waitTime, isRetriable
+	return waitTime, false
 }
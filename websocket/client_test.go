@@ -0,0 +1,944 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Gealber/bybit/bybittest"
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/Gealber/bybit/logging"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+const testTimeout = 5 * time.Second
+
+type testObserver struct {
+	pongs   atomic.Int64
+	dropped atomic.Int64
+}
+
+func (o *testObserver) Connected(bool)                              {}
+func (o *testObserver) MessageReceived(string)                      {}
+func (o *testObserver) MessageHandled(string, time.Duration, error) {}
+func (o *testObserver) Pong(time.Duration)                          { o.pongs.Add(1) }
+func (o *testObserver) MessageDropped(string)                       { o.dropped.Add(1) }
+
+// tickersRecorder receives the tickers and connection events of its topics
+type tickersRecorder struct {
+	messages chan bybitWs.TickersResponse
+	events   chan bybitWs.Event
+}
+
+func newTickersRecorder() *tickersRecorder {
+	return &tickersRecorder{
+		messages: make(chan bybitWs.TickersResponse, 10),
+		events:   make(chan bybitWs.Event, 10),
+	}
+}
+
+func (r *tickersRecorder) Process(_ context.Context, msg bybitWs.TickersResponse) error {
+	r.messages <- msg
+
+	return nil
+}
+
+func (r *tickersRecorder) ProcessEvent(_ context.Context, event bybitWs.Event) error {
+	r.events <- event
+
+	return nil
+}
+
+func newServer(t *testing.T) *bybittest.Server {
+	t.Helper()
+
+	server := bybittest.NewServer("test-key", "test-secret")
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newClient(server *bybittest.Server, opts ...bybitWs.ClientOption) *bybitWs.Client {
+	opts = append([]bybitWs.ClientOption{
+		bybitWs.WithBaseURL(server.WebsocketURL()),
+		bybitWs.WithLogger(logging.Discard()),
+	}, opts...)
+
+	return bybitWs.NewClient(server.Config(), opts...)
+}
+
+// run runs the client in background, the error of Run is checked on cleanup.
+func run(t *testing.T, client *bybitWs.Client, subscriptions []bybitWs.Request, handlers map[string]bybitWs.Handler) {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Run(context.Background(), subscriptions, handlers)
+	}()
+
+	t.Cleanup(func() {
+		_ = client.Close()
+
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Errorf("Run: %v", err)
+			}
+		case <-time.After(testTimeout):
+			t.Error("Run didn't return after Close")
+		}
+	})
+}
+
+func waitSubscribed(t *testing.T, server *bybittest.Server, topics ...string) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, topic := range topics {
+		err := server.WaitSubscribed(ctx, topic)
+		if err != nil {
+			t.Fatalf("waiting subscription of %s: %v", topic, err)
+		}
+	}
+}
+
+func publishTicker(t *testing.T, server *bybittest.Server, symbol string) {
+	t.Helper()
+
+	topic := bybitWs.TickersTopicFor(symbol)
+	err := server.Publish(topic, bybitWs.TickersResponse{
+		Topic: topic,
+		Type:  bybitWs.SnapshotType,
+		TS:    time.Now().UnixMilli(),
+		Data:  &bybitWs.TickersData{Symbol: symbol, LastPrice: "100"},
+	})
+	if err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			t.Fatal("channel closed")
+		}
+
+		return v
+	case <-time.After(testTimeout):
+		t.Fatal("timeout receiving")
+	}
+
+	var zero T
+
+	return zero
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for condition")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func waitEvent(t *testing.T, recorder *tickersRecorder, eventType bybitWs.EventType) bybitWs.Event {
+	t.Helper()
+
+	for {
+		event := receive(t, recorder.events)
+		if event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func TestRunPublicMessagesAndPong(t *testing.T) {
+	server := newServer(t)
+	observer := &testObserver{}
+	client := newClient(server, bybitWs.WithObserver(observer))
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+
+	waitSubscribed(t, server, topic)
+	publishTicker(t, server, "BTCUSDT")
+
+	msg := receive(t, recorder.messages)
+	if msg.Topic != topic || msg.Data.Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// the public pong echoes the ping as an operation response.
+	waitFor(t, func() bool { return observer.pongs.Load() > 0 })
+}
+
+func TestRunPrivateAuthAndPong(t *testing.T) {
+	server := newServer(t)
+	observer := &testObserver{}
+	client := newClient(server, bybitWs.WithObserver(observer), bybitWs.WithChannel(bybitWs.PrivateChannel, ""))
+
+	messages := make(chan bybitWs.OrderMessage, 1)
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe("order")}, map[string]bybitWs.Handler{
+		"order": bybitWs.Typed[bybitWs.OrderMessage](processFunc[bybitWs.OrderMessage](
+			func(_ context.Context, msg bybitWs.OrderMessage) error {
+				messages <- msg
+
+				return nil
+			})),
+	})
+
+	// the server rejects subscriptions of private topics without authentication.
+	waitSubscribed(t, server, "order")
+
+	order := bybitWs.OrderData{Category: "spot"}
+	order.OrderID = "1"
+
+	err := server.PublishPrivate(bybitWs.OrderMessage{Topic: "order", Data: []bybitWs.OrderData{order}})
+	if err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+
+	msg := receive(t, messages)
+	if len(msg.Data) != 1 || msg.Data[0].OrderID != "1" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// the private pong is sent with op pong and the req_id of the ping.
+	waitFor(t, func() bool { return observer.pongs.Load() > 0 })
+}
+
+func TestRunInvalidCredentials(t *testing.T) {
+	server := newServer(t)
+	handlers := map[string]bybitWs.Handler{
+		"order": bybitWs.Typed[bybitWs.OrderMessage](processFunc[bybitWs.OrderMessage](
+			func(context.Context, bybitWs.OrderMessage) error { return nil })),
+	}
+
+	runErr := func(cfg *config.AppConfig) error {
+		client := bybitWs.NewClient(cfg,
+			bybitWs.WithBaseURL(server.WebsocketURL()),
+			bybitWs.WithLogger(logging.Discard()),
+			bybitWs.WithChannel(bybitWs.PrivateChannel, ""),
+		)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- client.Run(context.Background(), []bybitWs.Request{bybitWs.Subscribe("order")}, handlers)
+		}()
+
+		return receive(t, errs)
+	}
+
+	cfg := server.Config()
+	cfg.ByBit.APISecret = "wrong-secret"
+
+	var authErr *bybitWs.AuthError
+	if err := runErr(cfg); !errors.As(err, &authErr) {
+		t.Fatalf("expected AuthError got %v", err)
+	}
+
+	cfg.ByBit.APISecret = ""
+	if err := runErr(cfg); !errors.Is(err, bybitWs.ErrorMissingCredentials) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorMissingCredentials, err)
+	}
+}
+
+func TestRunConnectionPerEndpoint(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	endpoints := make(chan bybitWs.Endpoint, 2)
+	run(t, client, []bybitWs.Request{
+		bybitWs.Subscribe(topic),
+		bybitWs.Subscribe(topic).InCategory(bybitWs.Linear),
+		// private topics use the private channel whatever the default channel.
+		bybitWs.Subscribe(bybitWs.OrderTopic),
+	}, map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](processFunc[bybitWs.TickersResponse](
+			func(ctx context.Context, _ bybitWs.TickersResponse) error {
+				endpoint, _ := bybitWs.EndpointFromContext(ctx)
+				endpoints <- endpoint
+
+				return nil
+			})),
+		bybitWs.OrderTopic: bybitWs.Typed[bybitWs.OrderMessage](processFunc[bybitWs.OrderMessage](
+			func(context.Context, bybitWs.OrderMessage) error { return nil })),
+	})
+
+	waitSubscribed(t, server, topic, bybitWs.OrderTopic)
+	waitFor(t, func() bool { return len(server.Connections()) == 3 })
+
+	paths := make(map[string]bool)
+	for _, c := range server.Connections() {
+		paths[c.Path] = true
+	}
+
+	for _, path := range []string{"/v5/public/spot", "/v5/public/linear", "/v5/private"} {
+		if !paths[path] {
+			t.Fatalf("expected a connection to %s got %v", path, paths)
+		}
+	}
+
+	// both connections must be subscribed before publishing.
+	waitFor(t, func() bool {
+		subscribed := 0
+		for _, c := range server.Connections() {
+			if contains(c.Topics, topic) {
+				subscribed++
+			}
+		}
+
+		return subscribed == 2
+	})
+	publishTicker(t, server, "BTCUSDT")
+
+	received := map[bybitWs.Endpoint]bool{receive(t, endpoints): true, receive(t, endpoints): true}
+	for _, category := range []bybitWs.CoverType{bybitWs.Spot, bybitWs.Linear} {
+		endpoint := bybitWs.Endpoint{Channel: bybitWs.PublicChannel, Category: category}
+		if !received[endpoint] {
+			t.Fatalf("expected a message from %s got %v", endpoint, received)
+		}
+	}
+}
+
+func TestRunShardsTopics(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithShardSize(2))
+
+	symbols := []string{"BTCUSDT", "ETHUSDT", "SOLUSDT", "XRPUSDT", "DOGEUSDT"}
+	topics := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		topics = append(topics, bybitWs.TickersTopicFor(symbol))
+	}
+
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topics...)}, map[string]bybitWs.Handler{
+		"tickers.*": bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+	waitSubscribed(t, server, topics...)
+
+	// a topic subscribed while running goes to the shard with room for it.
+	symbols = append(symbols, "ADAUSDT")
+	topic := bybitWs.TickersTopicFor("ADAUSDT")
+	err := client.Subscribe(context.Background(), bybitWs.Subscribe(topic))
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	waitSubscribed(t, server, topic)
+
+	shards := server.Connections()
+	if len(shards) != 3 {
+		t.Fatalf("expected 3 connections got %d", len(shards))
+	}
+
+	for _, shard := range shards {
+		if len(shard.Topics) != 2 {
+			t.Fatalf("expected 2 topics by connection got %v", shards)
+		}
+	}
+
+	received := make(map[string]bool)
+	for _, symbol := range symbols {
+		publishTicker(t, server, symbol)
+		received[receive(t, recorder.messages).Data.Symbol] = true
+	}
+
+	if len(received) != len(symbols) {
+		t.Fatalf("expected a ticker of every symbol got %v", received)
+	}
+}
+
+func TestReconnectResubscribes(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithDispatch(bybitWs.TickersTopic, bybitWs.DispatchConfig{
+		Policy: bybitWs.DispatchBlock,
+		Size:   10,
+	}))
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+
+	waitSubscribed(t, server, topic)
+	server.Disconnect(0)
+
+	// Typed forwards the events to the handler it wraps, events are delivered
+	// through the queue of the topic.
+	event := waitEvent(t, recorder, bybitWs.EventDisconnected)
+	if len(event.Topics) != 1 || event.Topics[0] != topic || event.Err == nil {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	event = waitEvent(t, recorder, bybitWs.EventReconnected)
+	if event.Endpoint != (bybitWs.Endpoint{Channel: bybitWs.PublicChannel, Category: bybitWs.Spot}) {
+		t.Fatalf("unexpected endpoint %s", event.Endpoint)
+	}
+
+	waitSubscribed(t, server, topic)
+	publishTicker(t, server, "BTCUSDT")
+
+	msg := receive(t, recorder.messages)
+	if msg.Topic != topic {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+func TestDispatchDropOldest(t *testing.T) {
+	server := newServer(t)
+	observer := &testObserver{}
+	client := newClient(server,
+		bybitWs.WithObserver(observer),
+		bybitWs.WithDispatch(bybitWs.TickersTopic, bybitWs.DispatchConfig{
+			Policy: bybitWs.DispatchDropOldest,
+			Size:   2,
+		}),
+	)
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	prices := make(chan string, 10)
+	release := make(chan struct{})
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Func(func(_ context.Context, msg bybitWs.TickersResponse) error {
+			prices <- msg.Data.LastPrice
+			<-release
+
+			return nil
+		}),
+	})
+	waitSubscribed(t, server, topic)
+
+	publish := func(price int) {
+		err := server.Publish(topic, bybitWs.TickersResponse{
+			Topic: topic,
+			Type:  bybitWs.SnapshotType,
+			Data:  &bybitWs.TickersData{Symbol: "BTCUSDT", LastPrice: fmt.Sprint(price)},
+		})
+		if err != nil {
+			t.Fatalf("publishing: %v", err)
+		}
+	}
+
+	// the handler is busy with the first message while the rest are queued.
+	publish(0)
+	if price := receive(t, prices); price != "0" {
+		t.Fatalf("unexpected price %s", price)
+	}
+
+	for i := 1; i <= 5; i++ {
+		publish(i)
+	}
+	waitFor(t, func() bool { return observer.dropped.Load() == 3 })
+	close(release)
+
+	for _, expected := range []string{"4", "5"} {
+		if price := receive(t, prices); price != expected {
+			t.Fatalf("expected price %s got %s", expected, price)
+		}
+	}
+}
+
+func TestUnsubscribeStopsQueues(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithDispatch(bybitWs.TickersTopic, bybitWs.DispatchConfig{
+		Policy: bybitWs.DispatchBlock,
+		Size:   10,
+	}))
+
+	var handled atomic.Int64
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(bybitWs.TickersTopicFor("BTCUSDT"))}, map[string]bybitWs.Handler{
+		"tickers.*": bybitWs.Func(func(context.Context, bybitWs.TickersResponse) error {
+			handled.Add(1)
+
+			return nil
+		}),
+	})
+	waitSubscribed(t, server, bybitWs.TickersTopicFor("BTCUSDT"))
+
+	symbols, topics := make([]string, 0, 20), make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		symbols = append(symbols, fmt.Sprintf("COIN%dUSDT", i))
+		topics = append(topics, bybitWs.TickersTopicFor(symbols[i]))
+	}
+
+	subscription := bybitWs.Subscribe(topics...)
+	publishAll := func() {
+		for _, symbol := range symbols {
+			publishTicker(t, server, symbol)
+		}
+	}
+
+	err := client.Subscribe(context.Background(), subscription)
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	publishAll()
+	waitFor(t, func() bool { return handled.Load() == 20 })
+	goroutines := runtime.NumGoroutine()
+
+	// the worker of each topic is stopped.
+	err = client.Unsubscribe(context.Background(), bybitWs.Unsubscribe(topics...))
+	if err != nil {
+		t.Fatalf("unsubscribing: %v", err)
+	}
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= goroutines-len(symbols) })
+
+	// and started again when subscribed again.
+	err = client.Subscribe(context.Background(), subscription)
+	if err != nil {
+		t.Fatalf("subscribing again: %v", err)
+	}
+
+	publishAll()
+	waitFor(t, func() bool { return handled.Load() == 40 })
+}
+
+func TestStreamsShareTopics(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	// streams require Run.
+	_, err := client.SubscribeTickers(ctx1, "BTCUSDT")
+	if !errors.Is(err, bybitWs.ErrorNotRunning) {
+		t.Fatalf("expected ErrorNotRunning got %v", err)
+	}
+
+	run(t, client, nil, nil)
+
+	var first <-chan bybitWs.TickersResponse
+	waitFor(t, func() bool {
+		first, err = client.SubscribeTickers(ctx1, "BTCUSDT")
+		return !errors.Is(err, bybitWs.ErrorNotRunning)
+	})
+	if err != nil {
+		t.Fatalf("first stream: %v", err)
+	}
+
+	second, err := client.SubscribeTickers(ctx2, "BTCUSDT")
+	if err != nil {
+		t.Fatalf("second stream: %v", err)
+	}
+
+	waitSubscribed(t, server, bybitWs.TickersTopicFor("BTCUSDT"))
+	publishTicker(t, server, "BTCUSDT")
+	receive(t, first)
+	receive(t, second)
+
+	// cancelling the first stream neither stops the client nor unsubscribes the topic.
+	cancel1()
+	waitFor(t, func() bool {
+		_, ok := <-first
+		return !ok
+	})
+
+	publishTicker(t, server, "BTCUSDT")
+	receive(t, second)
+
+	// the streams end with Run.
+	_ = client.Close()
+	waitFor(t, func() bool {
+		_, ok := <-second
+		return !ok
+	})
+}
+
+func TestStreamRejectsTopicsHandledByRun(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Func(func(context.Context, bybitWs.TickersResponse) error { return nil }),
+	})
+
+	waitSubscribed(t, server, topic)
+
+	_, err := client.SubscribeTickers(context.Background(), "BTCUSDT")
+	if !errors.Is(err, bybitWs.ErrorDuplicateHandler) {
+		t.Fatalf("expected ErrorDuplicateHandler got %v", err)
+	}
+}
+
+func TestReconnectBroadcastAfterAck(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+
+	waitSubscribed(t, server, topic)
+	server.Disconnect(0)
+
+	event := waitEvent(t, recorder, bybitWs.EventReconnected)
+	if event.Err != nil || len(event.Topics) != 1 || event.Topics[0] != topic {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// broadcast once acknowledged, the topic is already subscribed.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := server.WaitSubscribed(ctx, topic); err != nil {
+		t.Fatalf("reconnection broadcast before the subscription of %s: %v", topic, err)
+	}
+}
+
+func TestReconnectDropsRejectedTopics(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	valid, invalid := bybitWs.TickersTopicFor("BTCUSDT"), bybitWs.TickersTopicFor("XXXUSDT")
+	recorder := newTickersRecorder()
+	// the invalid topic goes first, so it's dropped once the valid one is subscribed.
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(invalid, valid)}, map[string]bybitWs.Handler{
+		"tickers.*": bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+
+	waitSubscribed(t, server, valid, invalid)
+
+	// rejected in the resubscription, the valid topic of the same request is kept.
+	server.RejectTopic(invalid, "handler not found")
+	server.Disconnect(0)
+
+	// broadcast once acknowledged, without the rejected topic.
+	event := waitEvent(t, recorder, bybitWs.EventReconnected)
+	if !errors.Is(event.Err, bybitWs.ErrorSubscriptionRejected) || len(event.Topics) != 1 || event.Topics[0] != valid {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	// the rejected topic isn't subscribed again.
+	server.RejectTopic(invalid, "")
+	server.Disconnect(0)
+
+	event = waitEvent(t, recorder, bybitWs.EventReconnected)
+	if event.Err != nil || len(event.Topics) != 1 || event.Topics[0] != valid {
+		t.Fatalf("unexpected event %+v", event)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	if err := server.WaitSubscribed(ctx, invalid); err == nil {
+		t.Fatalf("rejected topic %s subscribed again", invalid)
+	}
+}
+
+func TestReconnectStaleTopics(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithStaleTimeout(bybitWs.TickersTopic, 200*time.Millisecond))
+
+	btc, eth := bybitWs.TickersTopicFor("BTCUSDT"), bybitWs.TickersTopicFor("ETHUSDT")
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(btc, eth)}, map[string]bybitWs.Handler{
+		"tickers.*": bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+
+	waitSubscribed(t, server, btc, eth)
+
+	// btc receives messages, eth is silent since connected.
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-recorder.messages:
+			case <-ticker.C:
+				_ = server.Publish(btc, bybitWs.TickersResponse{Topic: btc, Data: &bybitWs.TickersData{Symbol: "BTCUSDT"}})
+			}
+		}
+	}()
+
+	event := waitEvent(t, recorder, bybitWs.EventDisconnected)
+	if !errors.Is(event.Err, bybitWs.ErrorStaleTopic) || !errors.Is(event.Err, bybitWs.ErrorSilentTopic) {
+		t.Fatalf("expected the silent topic to be stale got %v", event.Err)
+	}
+}
+
+func TestReconnectOrderBooks(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithChannel(bybitWs.PublicChannel, bybitWs.Linear))
+
+	topic := bybitWs.OrderbookTopicFor(50, "BTCUSDT")
+	books := bybitWs.NewOrderBooks(nil)
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: books,
+	})
+
+	waitSubscribed(t, server, topic)
+
+	err := server.Publish(topic, bookMsg(bybitWs.SnapshotType, 50, 1, "100"))
+	if err != nil {
+		t.Fatalf("publishing: %v", err)
+	}
+
+	waitFor(t, func() bool { return books.Synced(bybitWs.Linear, "BTCUSDT") })
+
+	// the books of the lost connection are out of sync until the next snapshot.
+	server.Disconnect(0)
+	waitFor(t, func() bool { return !books.Synced(bybitWs.Linear, "BTCUSDT") })
+}
+
+func TestSubscribeWhileRunning(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	btc, eth := bybitWs.TickersTopicFor("BTCUSDT"), bybitWs.TickersTopicFor("ETHUSDT")
+
+	err := client.Subscribe(context.Background(), bybitWs.Subscribe(eth))
+	if !errors.Is(err, bybitWs.ErrorNotRunning) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorNotRunning, err)
+	}
+
+	recorder := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(btc)}, map[string]bybitWs.Handler{
+		"tickers.*": bybitWs.Typed[bybitWs.TickersResponse](recorder),
+	})
+	waitSubscribed(t, server, btc)
+
+	// acknowledged once Subscribe returns.
+	err = client.Subscribe(context.Background(), bybitWs.Subscribe(eth))
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+
+	publishTicker(t, server, "ETHUSDT")
+	if msg := receive(t, recorder.messages); msg.Topic != eth {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// a new endpoint opens its connection, subscribing once connected.
+	err = client.Subscribe(context.Background(), bybitWs.Subscribe(eth).InCategory(bybitWs.Linear))
+	if err != nil {
+		t.Fatalf("subscribing linear: %v", err)
+	}
+
+	waitFor(t, func() bool {
+		subscribed := 0
+		for _, c := range server.Connections() {
+			if contains(c.Topics, eth) {
+				subscribed++
+			}
+		}
+
+		return subscribed == 2
+	})
+
+	err = client.Subscribe(context.Background(), bybitWs.Subscribe(bybitWs.KlineTopicFor(bybitWs.Interval1Minute, "BTCUSDT")))
+	if !errors.Is(err, bybitWs.ErrorMissingHandler) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorMissingHandler, err)
+	}
+
+	invalid := bybitWs.TickersTopicFor("XXXUSDT")
+	server.RejectTopic(invalid, "handler not found")
+
+	err = client.Subscribe(context.Background(), bybitWs.Subscribe(invalid))
+	if !errors.Is(err, bybitWs.ErrorSubscriptionRejected) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorSubscriptionRejected, err)
+	}
+
+	err = client.Unsubscribe(context.Background(), bybitWs.Unsubscribe(eth), bybitWs.Unsubscribe(eth).InCategory(bybitWs.Linear))
+	if err != nil {
+		t.Fatalf("unsubscribing: %v", err)
+	}
+
+	for _, c := range server.Connections() {
+		if contains(c.Topics, eth) {
+			t.Fatalf("%s still subscribed in %s", eth, c.Path)
+		}
+	}
+}
+
+func TestCloseStopsRun(t *testing.T) {
+	server := newServer(t)
+	client := newClient(server)
+
+	err := client.Close()
+	if err != nil {
+		t.Fatalf("closing a client not running: %v", err)
+	}
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	handlers := map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](newTickersRecorder()),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Run(context.Background(), []bybitWs.Request{bybitWs.Subscribe(topic)}, handlers)
+	}()
+	waitSubscribed(t, server, topic)
+
+	// Run has returned once Close returns.
+	err = client.Close()
+	if err != nil {
+		t.Fatalf("closing: %v", err)
+	}
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	default:
+		t.Fatal("Close returned before Run")
+	}
+
+	// the server sees the close handshake.
+	waitFor(t, func() bool { return len(server.Connections()) == 0 })
+
+	err = client.Subscribe(context.Background(), bybitWs.Subscribe(topic))
+	if !errors.Is(err, bybitWs.ErrorNotRunning) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorNotRunning, err)
+	}
+
+	// the context stops Run as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		errs <- client.Run(ctx, []bybitWs.Request{bybitWs.Subscribe(topic)}, handlers)
+	}()
+	waitSubscribed(t, server, topic)
+	cancel()
+
+	if err := receive(t, errs); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+}
+
+func newTradeClient(t *testing.T, server *bybittest.Server) *bybitWs.TradeClient {
+	t.Helper()
+
+	client := bybitWs.NewTradeClient(server.Config(), bybitWs.WithBaseURL(server.WebsocketURL()), bybitWs.WithLogger(logging.Discard()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	err := client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("connecting: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestTradeClientOperations(t *testing.T) {
+	server := newServer(t)
+
+	_, err := bybitWs.NewTradeClient(server.Config()).PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+	if !errors.Is(err, bybitWs.ErrorNotConnected) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorNotConnected, err)
+	}
+
+	client := newTradeClient(t, server)
+
+	placed, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT", OrderLinkId: "link"})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	if placed.OrderId == "" || placed.OrderLinkId != "link" {
+		t.Fatalf("unexpected response %+v", placed)
+	}
+
+	cancelled, err := client.CancelOrder(bybitHttp.CancelRequest{Category: "spot", Symbol: "BTCUSDT", OrderID: placed.OrderId})
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+
+	if cancelled.OrderId != placed.OrderId {
+		t.Fatalf("expected order %s cancelled got %+v", placed.OrderId, cancelled)
+	}
+
+	items, err := client.PlaceBatchOrders(bybitHttp.BatchOrderRequest{
+		Category: "linear",
+		Request: []bybitHttp.OrderRequest{
+			{Symbol: "BTCUSDT", OrderLinkId: "a"},
+			{Symbol: "ETHUSDT", OrderLinkId: "b"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PlaceBatchOrders: %v", err)
+	}
+
+	if len(items) != 2 || items[1].OrderLinkId != "b" || items[1].Code != 0 || items[1].Msg != "OK" {
+		t.Fatalf("unexpected batch items %+v", items)
+	}
+}
+
+func TestTradeClientDuplicatedResponses(t *testing.T) {
+	server := newServer(t)
+	server.DuplicateTradeResponses(true)
+
+	client := newTradeClient(t, server)
+
+	// the duplicates must not block the read loop of later responses.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT"})
+			if err != nil {
+				t.Errorf("PlaceOrder: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	response, err := client.PlaceOrder(bybitHttp.OrderRequest{Category: "spot", Symbol: "BTCUSDT", OrderLinkId: "last"})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	if response.OrderLinkId != "last" {
+		t.Fatalf("unexpected response %+v", response)
+	}
+}
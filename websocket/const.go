@@ -6,12 +6,85 @@ type CoverType string
 const (
 	PublicChannel  ChannelType = "public"
 	PrivateChannel ChannelType = "private"
+	TradeChannel   ChannelType = "trade"
 	Spot           CoverType   = "spot"
 	Linear         CoverType   = "linear"
 	Inverse        CoverType   = "inverse"
 	Option         CoverType   = "option"
 )
 
+const (
+	SubscribeOp   = "subscribe"
+	UnsubscribeOp = "unsubscribe"
+	PingOp        = "ping"
+	AuthOp        = "auth"
+)
+
+// operations of the trade channel.
+const (
+	OrderCreateOp      = "order.create"
+	OrderAmendOp       = "order.amend"
+	OrderCancelOp      = "order.cancel"
+	OrderCreateBatchOp = "order.create-batch"
+	OrderAmendBatchOp  = "order.amend-batch"
+	OrderCancelBatchOp = "order.cancel-batch"
+)
+
+// topic families.
+const (
+	TickersTopic     = "tickers"
+	OrderbookTopic   = "orderbook"
+	PublicTradeTopic = "publicTrade"
+	KlineTopic       = "kline"
+	LiquidationTopic = "liquidation"
+	LTTickersTopic   = "tickers_lt"
+	LTNavTopic       = "lt"
+)
+
+// private topic families, topics can be restricted to a category
+// adding it as suffix e.g. order.spot.
+const (
+	OrderTopic         = "order"
+	ExecutionTopic     = "execution"
+	FastExecutionTopic = "execution.fast"
+	PositionTopic      = "position"
+	WalletTopic        = "wallet"
+	GreeksTopic        = "greeks"
+	DCPTopic           = "dcp"
+)
+
+var privateTopics = []string{
+	OrderTopic,
+	ExecutionTopic,
+	PositionTopic,
+	WalletTopic,
+	GreeksTopic,
+	DCPTopic,
+}
+
+// kline intervals.
+const (
+	Interval1Minute  = "1"
+	Interval3Minute  = "3"
+	Interval5Minute  = "5"
+	Interval15Minute = "15"
+	Interval30Minute = "30"
+	Interval1Hour    = "60"
+	Interval2Hour    = "120"
+	Interval4Hour    = "240"
+	Interval6Hour    = "360"
+	Interval12Hour   = "720"
+	IntervalDay      = "D"
+	IntervalWeek     = "W"
+	IntervalMonth    = "M"
+)
+
+// types of public messages.
+const (
+	SnapshotType = "snapshot"
+	DeltaType    = "delta"
+)
+
 const (
 	TickersTONUSDTTopic  = "tickers.TONUSDT"
 	TickersBtcUSDTTopic  = "tickers.BTCUSDT"
@@ -28,7 +101,35 @@ const (
 )
 
 const (
-	PingTimeout           = 20
+	PingTimeout = 20
+	// PongTimeout seconds to wait the pong of a ping before reconnecting.
+	PongTimeout = 10
+	// ReadTimeout default seconds without receiving messages before reconnecting.
+	ReadTimeout = 2 * PingTimeout
+	// HealthCheckInterval seconds between checks of pongs and stale topics.
+	HealthCheckInterval = 1
+	// TickerKeyTimeout suggested seconds without ticker updates before
+	// reconnecting, for liquid symbols, see WithStaleTimeout.
 	TickerKeyTimeout      = 45
 	MaxRetrialConnections = 10
+	// SubscriptionTimeout seconds to wait the acknowledgement of a subscription.
+	SubscriptionTimeout = 10
+	// MaxSpotArgs max number of topics in a single request to the spot channel.
+	MaxSpotArgs = 10
+	// MaxArgsLength max length of the topics in a single request.
+	MaxArgsLength = 21000
+	// CloseTimeout milliseconds to wait the server to close the connection
+	// after sending the close message.
+	CloseTimeout = 500
+	// StableConnectionTimeout seconds after which a connection is considered
+	// stable and the count of reconnections starts again.
+	StableConnectionTimeout = 60
+	// ShardSize default max number of topics subscribed in a connection.
+	ShardSize = 100
+	// RecordFlushInterval seconds between flushes of a Recorder.
+	RecordFlushInterval = 1
+	// StreamBufferSize capacity of the channels returned by Stream.
+	StreamBufferSize      = 100
+	AuthExpirationTimeout = 10
+	TradeRequestTimeout   = 5
 )
@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Decoder turns the raw message of a topic into the typed message given to handlers
+type Decoder func(data []byte) (any, error)
+
+type route struct {
+	pattern string
+	handler Handler
+}
+
+// Dispatcher routes the messages to the handler registered for its topic.
+// Handlers are registered for an exact topic (tickers.BTCUSDT), a pattern
+// (tickers.*, orderbook.50.*) or a prefix (kline.), exact topics take
+// precedence over patterns and patterns over prefixes, the most specific
+// one wins in each group. Messages are decoded by topic family before
+// being passed to the handler.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	exact    map[string]Handler
+	patterns []route
+	prefixes []route
+	fallback Handler
+	decoders map[string]Decoder
+}
+
+// NewDispatcher creates a dispatcher with the decoders of the topics
+// supported by the package
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		exact:    make(map[string]Handler),
+		decoders: make(map[string]Decoder),
+	}
+
+	d.RegisterDecoder(TickersTopic, decode[TickersResponse])
+	d.RegisterDecoder(OrderbookTopic, decode[OrderbookResponse])
+	d.RegisterDecoder(PublicTradeTopic, decode[PublicTradeResponse])
+	d.RegisterDecoder(KlineTopic, decode[KlineResponse])
+	d.RegisterDecoder(LiquidationTopic, decode[LiquidationResponse])
+	d.RegisterDecoder(LTTickersTopic, decode[LTTickersResponse])
+	d.RegisterDecoder(LTNavTopic, decode[LTNavResponse])
+	d.RegisterDecoder(OrderTopic, decode[OrderMessage])
+	d.RegisterDecoder(ExecutionTopic, decode[ExecutionMessage])
+	d.RegisterDecoder(FastExecutionTopic, decode[FastExecutionMessage])
+	d.RegisterDecoder(PositionTopic, decode[PositionMessage])
+	d.RegisterDecoder(WalletTopic, decode[WalletMessage])
+	d.RegisterDecoder(GreeksTopic, decode[GreeksMessage])
+	d.RegisterDecoder(DCPTopic, decode[DCPMessage])
+
+	return d
+}
+
+// NewDispatcherFromMap creates a dispatcher registering every handler with
+// its key as topic or pattern
+func NewDispatcherFromMap(handlers map[string]Handler) (*Dispatcher, error) {
+	d := NewDispatcher()
+	for pattern, handler := range handlers {
+		err := d.Handle(pattern, handler)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+// Handle registers the handler for a topic or a pattern using path.Match syntax
+func (d *Dispatcher) Handle(pattern string, handler Handler) error {
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		return fmt.Errorf("%w: %q", ErrorInvalidPattern, pattern)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !isPattern(pattern) {
+		d.exact[pattern] = handler
+
+		return nil
+	}
+
+	d.patterns = addRoute(d.patterns, route{pattern: pattern, handler: handler})
+
+	return nil
+}
+
+// HandlePrefix registers the handler for every topic starting with prefix
+func (d *Dispatcher) HandlePrefix(prefix string, handler Handler) error {
+	if prefix == "" {
+		return fmt.Errorf("%w: empty prefix", ErrorInvalidPattern)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.prefixes = addRoute(d.prefixes, route{pattern: prefix, handler: handler})
+
+	return nil
+}
+
+// HandleFallback registers the handler for topics without any other handler
+func (d *Dispatcher) HandleFallback(handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.fallback = handler
+}
+
+// RegisterDecoder sets the decoder of a topic family, the family is the
+// beginning of the topic e.g. "tickers" for "tickers.BTCUSDT". The decoder
+// of the longest family matching a topic is used.
+func (d *Dispatcher) RegisterDecoder(family string, decoder Decoder) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.decoders[family] = decoder
+}
+
+// Handler retrieve the handler registered for topic
+func (d *Dispatcher) Handler(topic string) (Handler, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if handler, ok := d.exact[topic]; ok {
+		return handler, true
+	}
+
+	for _, r := range d.patterns {
+		if ok, _ := path.Match(r.pattern, topic); ok {
+			return r.handler, true
+		}
+	}
+
+	for _, r := range d.prefixes {
+		if strings.HasPrefix(topic, r.pattern) {
+			return r.handler, true
+		}
+	}
+
+	if d.fallback != nil {
+		return d.fallback, true
+	}
+
+	return nil, false
+}
+
+// exactHandler retrieve the handler registered for exactly topic
+func (d *Dispatcher) exactHandler(topic string) (Handler, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	handler, ok := d.exact[topic]
+
+	return handler, ok
+}
+
+// Validate checks there is a handler for every topic in the subscriptions
+func (d *Dispatcher) Validate(subscriptions []Request) error {
+	for _, subscription := range subscriptions {
+		if subscription.Op != SubscribeOp {
+			continue
+		}
+
+		for _, arg := range subscription.Args {
+			topic, ok := arg.(string)
+			if !ok {
+				continue
+			}
+
+			if _, ok := d.Handler(topic); !ok {
+				return fmt.Errorf("%w: %s", ErrorMissingHandler, topic)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Dispatch decodes the message and passes it to the handler of topic
+func (d *Dispatcher) Dispatch(ctx context.Context, topic string, data []byte) error {
+	handler, ok := d.Handler(topic)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrorMissingHandler, topic)
+	}
+
+	obj, err := d.decoder(topic)(data)
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", topic, err)
+	}
+
+	return handler.ProcessMsg(ctx, obj)
+}
+
+func (d *Dispatcher) decoder(topic string) Decoder {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var (
+		family  string
+		decoder Decoder = decode[PublicResponse]
+	)
+
+	for f, dec := range d.decoders {
+		if len(f) > len(family) && (topic == f || strings.HasPrefix(topic, f+".")) {
+			family = f
+			decoder = dec
+		}
+	}
+
+	return decoder
+}
+
+func decode[T any](data []byte) (any, error) {
+	var msg T
+
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func isPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+// addRoute adds r keeping the most specific routes, the longest, first
+func addRoute(routes []route, r route) []route {
+	for i := range routes {
+		if routes[i].pattern == r.pattern {
+			routes[i] = r
+
+			return routes
+		}
+	}
+
+	routes = append(routes, r)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].pattern) > len(routes[j].pattern)
+	})
+
+	return routes
+}
@@ -0,0 +1,173 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+// namedHandler records the name of the handler and the message processed.
+type namedHandler struct {
+	name     string
+	received *[]string
+	msgs     *[]any
+}
+
+func (h namedHandler) ProcessMsg(_ context.Context, obj any) error {
+	*h.received = append(*h.received, h.name)
+	*h.msgs = append(*h.msgs, obj)
+
+	return nil
+}
+
+func TestDispatcherPrecedence(t *testing.T) {
+	var (
+		received []string
+		msgs     []any
+	)
+
+	named := func(name string) bybitWs.Handler {
+		return namedHandler{name: name, received: &received, msgs: &msgs}
+	}
+
+	dispatcher := bybitWs.NewDispatcher()
+	for pattern, name := range map[string]string{
+		"tickers.BTCUSDT":  "exact",
+		"tickers.*":        "tickers pattern",
+		"orderbook.*.*":    "orderbook pattern",
+		"orderbook.50.*":   "orderbook 50 pattern",
+		"publicTrade.BTC*": "trade pattern",
+	} {
+		err := dispatcher.Handle(pattern, named(name))
+		if err != nil {
+			t.Fatalf("registering %s: %v", pattern, err)
+		}
+	}
+
+	for prefix, name := range map[string]string{"publicTrade.": "trade prefix", "kline.": "kline prefix"} {
+		err := dispatcher.HandlePrefix(prefix, named(name))
+		if err != nil {
+			t.Fatalf("registering %s: %v", prefix, err)
+		}
+	}
+
+	tests := []struct {
+		topic   string
+		handler string
+	}{
+		{topic: "tickers.BTCUSDT", handler: "exact"},
+		{topic: "tickers.ETHUSDT", handler: "tickers pattern"},
+		{topic: "orderbook.50.BTCUSDT", handler: "orderbook 50 pattern"},
+		{topic: "orderbook.1.BTCUSDT", handler: "orderbook pattern"},
+		{topic: "publicTrade.BTCUSDT", handler: "trade pattern"},
+		{topic: "publicTrade.ETHUSDT", handler: "trade prefix"},
+		{topic: "kline.1.BTCUSDT", handler: "kline prefix"},
+	}
+
+	for _, tt := range tests {
+		received = received[:0]
+
+		err := dispatcher.Dispatch(context.Background(), tt.topic, []byte(`{"topic":"`+tt.topic+`"}`))
+		if err != nil {
+			t.Fatalf("dispatching %s: %v", tt.topic, err)
+		}
+
+		if len(received) != 1 || received[0] != tt.handler {
+			t.Fatalf("expected %s handled by %s got %v", tt.topic, tt.handler, received)
+		}
+	}
+
+	err := dispatcher.Dispatch(context.Background(), "liquidation.BTCUSDT", []byte(`{}`))
+	if !errors.Is(err, bybitWs.ErrorMissingHandler) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorMissingHandler, err)
+	}
+
+	dispatcher.HandleFallback(named("fallback"))
+	received = received[:0]
+
+	err = dispatcher.Dispatch(context.Background(), "liquidation.BTCUSDT", []byte(`{}`))
+	if err != nil || len(received) != 1 || received[0] != "fallback" {
+		t.Fatalf("expected fallback handler got %v %v", received, err)
+	}
+}
+
+func TestDispatcherDecoders(t *testing.T) {
+	var (
+		received []string
+		msgs     []any
+	)
+
+	dispatcher, err := bybitWs.NewDispatcherFromMap(map[string]bybitWs.Handler{
+		"*": namedHandler{received: &received, msgs: &msgs},
+	})
+	if err != nil {
+		t.Fatalf("creating dispatcher: %v", err)
+	}
+
+	dispatcher.RegisterDecoder("custom.family", func(data []byte) (any, error) {
+		return string(data), nil
+	})
+
+	for _, topic := range []string{"tickers.BTCUSDT", "custom.family.BTCUSDT", "custom.familyBTC"} {
+		err = dispatcher.Dispatch(context.Background(), topic, []byte(`{"topic":"`+topic+`"}`))
+		if err != nil {
+			t.Fatalf("dispatching %s: %v", topic, err)
+		}
+	}
+
+	if _, ok := msgs[0].(bybitWs.TickersResponse); !ok {
+		t.Fatalf("expected tickers decoded as TickersResponse got %T", msgs[0])
+	}
+
+	if _, ok := msgs[1].(string); !ok {
+		t.Fatalf("expected the registered decoder of the family got %T", msgs[1])
+	}
+
+	if _, ok := msgs[2].(bybitWs.PublicResponse); !ok {
+		t.Fatalf("expected a topic outside the family decoded as PublicResponse got %T", msgs[2])
+	}
+
+	err = dispatcher.Dispatch(context.Background(), "tickers.BTCUSDT", []byte(`not json`))
+	if err == nil {
+		t.Fatal("expected decoding error")
+	}
+}
+
+func TestDispatcherValidate(t *testing.T) {
+	var (
+		received []string
+		msgs     []any
+	)
+
+	handler := namedHandler{received: &received, msgs: &msgs}
+	dispatcher := bybitWs.NewDispatcher()
+
+	err := dispatcher.Handle("tickers.[", handler)
+	if !errors.Is(err, bybitWs.ErrorInvalidPattern) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorInvalidPattern, err)
+	}
+
+	err = dispatcher.Handle("tickers.*", handler)
+	if err != nil {
+		t.Fatalf("registering handler: %v", err)
+	}
+
+	subscriptions := []bybitWs.Request{
+		{Op: bybitWs.SubscribeOp, Args: []any{"tickers.BTCUSDT"}},
+		{Op: bybitWs.UnsubscribeOp, Args: []any{"kline.1.BTCUSDT"}},
+	}
+
+	err = dispatcher.Validate(subscriptions)
+	if err != nil {
+		t.Fatalf("validating: %v", err)
+	}
+
+	subscriptions = append(subscriptions, bybitWs.Request{Op: bybitWs.SubscribeOp, Args: []any{"kline.1.BTCUSDT"}})
+
+	err = dispatcher.Validate(subscriptions)
+	if !errors.Is(err, bybitWs.ErrorMissingHandler) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorMissingHandler, err)
+	}
+}
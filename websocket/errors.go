@@ -0,0 +1,26 @@
+package websocket
+
+import "errors"
+
+var (
+	ErrorMissingHandler = errors.New("missing handler for topic")
+	ErrorInvalidPattern = errors.New("invalid topic pattern")
+	ErrorInvalidMessage = errors.New("invalid type of message")
+	// ErrorDuplicateHandler Stream doesn't replace the handler registered for a topic.
+	ErrorDuplicateHandler = errors.New("topic already has a handler")
+	ErrorOrderBookGap     = errors.New("gap in order book updates")
+	// ErrorOutdatedSnapshot a snapshot retrieved to resync a book is older than the book.
+	ErrorOutdatedSnapshot = errors.New("outdated order book snapshot")
+	// ErrorMissingCredentials private channels require api key and secret.
+	ErrorMissingCredentials = errors.New("missing api key or secret for private channel")
+	ErrorNotConnected       = errors.New("websocket not connected")
+	ErrorRequestTimeout     = errors.New("websocket request timeout")
+	ErrorNotRunning         = errors.New("websocket client is not running")
+	ErrorAlreadyRunning     = errors.New("websocket client is already running")
+	// ErrorSubscriptionRejected bybit answered the subscription with success false.
+	ErrorSubscriptionRejected = errors.New("subscription rejected")
+	ErrorPongTimeout          = errors.New("missing pong")
+	ErrorStaleTopic           = errors.New("stale topic")
+	// ErrorSilentTopic a stale topic didn't receive any message since connected.
+	ErrorSilentTopic = errors.New("no messages since connected")
+)
@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// EventType kind of connection event
+type EventType string
+
+const (
+	// EventDisconnected the connection was lost, the state built from the
+	// topics of the event may be stale until the reconnection.
+	EventDisconnected EventType = "disconnected"
+	// EventReconnected a new connection was established and bybit
+	// acknowledged the subscription of Topics, the topics rejected are
+	// dropped and reported in Err. The first snapshots of the topics may be
+	// delivered before the event.
+	EventReconnected EventType = "reconnected"
+)
+
+// Event about the connection serving Topics
+type Event struct {
+	Type     EventType
+	Endpoint Endpoint
+	Topics   []string
+	// Err that caused the disconnection.
+	Err error
+}
+
+// EventHandler is implemented by handlers interested in connection events,
+// e.g. to invalidate cached state when the connection is lost. The client
+// delivers the event to the handler of each topic of the connection, with
+// that topic in Topics, through the queue of the topic so it's seen in
+// order with the messages. Typed and the tracing handler forward events to
+// the handlers they wrap.
+type EventHandler interface {
+	ProcessEvent(ctx context.Context, event Event) error
+}
+
+// Broadcast delivers event to every registered handler implementing EventHandler
+func (d *Dispatcher) Broadcast(ctx context.Context, event Event) error {
+	var errs []error
+	for _, handler := range d.handlers() {
+		eventHandler, ok := handler.(EventHandler)
+		if !ok {
+			continue
+		}
+
+		err := eventHandler.ProcessEvent(ctx, event)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DispatchEvent delivers event to the handler of topic when it implements EventHandler
+func (d *Dispatcher) DispatchEvent(ctx context.Context, topic string, event Event) error {
+	handler, ok := d.Handler(topic)
+	if !ok {
+		return nil
+	}
+
+	eventHandler, ok := handler.(EventHandler)
+	if !ok {
+		return nil
+	}
+
+	return eventHandler.ProcessEvent(ctx, event)
+}
+
+// handlers list every handler registered once.
+func (d *Dispatcher) handlers() []Handler {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	seen := make(map[Handler]struct{})
+	handlers := make([]Handler, 0)
+	add := func(handler Handler) {
+		if handler == nil {
+			return
+		}
+
+		// handlers of non comparable types can't be deduplicated.
+		if !reflect.TypeOf(handler).Comparable() {
+			handlers = append(handlers, handler)
+
+			return
+		}
+
+		if _, ok := seen[handler]; ok {
+			return
+		}
+
+		seen[handler] = struct{}{}
+		handlers = append(handlers, handler)
+	}
+
+	for _, handler := range d.exact {
+		add(handler)
+	}
+
+	for _, r := range d.patterns {
+		add(r.handler)
+	}
+
+	for _, r := range d.prefixes {
+		add(r.handler)
+	}
+
+	add(d.fallback)
+
+	return handlers
+}
@@ -3,17 +3,127 @@ package websocket
 import (
 	"context"
 	"errors"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+// TypedHandler processes messages already decoded into T
+type TypedHandler[T any] interface {
+	Process(ctx context.Context, msg T) error
+}
+
+// typed handlers of the public streams.
+type (
+	TickersTypedHandler = TypedHandler[TickersResponse]
+	OrderbookHandler    = TypedHandler[OrderbookResponse]
+	PublicTradeHandler  = TypedHandler[PublicTradeResponse]
+	KlineHandler        = TypedHandler[KlineResponse]
+	LiquidationHandler  = TypedHandler[LiquidationResponse]
+	LTTickersHandler    = TypedHandler[LTTickersResponse]
+	LTNavHandler        = TypedHandler[LTNavResponse]
 )
 
+// typed handlers of the private streams.
+type (
+	OrderHandler         = TypedHandler[OrderMessage]
+	ExecutionHandler     = TypedHandler[ExecutionMessage]
+	FastExecutionHandler = TypedHandler[FastExecutionMessage]
+	PositionHandler      = TypedHandler[PositionMessage]
+	WalletHandler        = TypedHandler[WalletMessage]
+	GreeksHandler        = TypedHandler[GreeksMessage]
+	DCPHandler           = TypedHandler[DCPMessage]
+)
+
+// Typed adapts a TypedHandler to be registered as a Handler, messages of
+// a different type are rejected with ErrorInvalidMessage.
+func Typed[T any](handler TypedHandler[T]) Handler {
+	return &typedHandler[T]{handler: handler}
+}
+
+type typedHandler[T any] struct {
+	handler TypedHandler[T]
+}
+
+func (t *typedHandler[T]) ProcessMsg(ctx context.Context, obj any) error {
+	msg, ok := obj.(T)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrorInvalidMessage, obj)
+	}
+
+	return t.handler.Process(ctx, msg)
+}
+
+// ProcessEvent forwards connection events when the wrapped handler is an EventHandler
+func (t *typedHandler[T]) ProcessEvent(ctx context.Context, event Event) error {
+	eventHandler, ok := t.handler.(EventHandler)
+	if !ok {
+		return nil
+	}
+
+	return eventHandler.ProcessEvent(ctx, event)
+}
+
+// HandlerFunc adapts a function to be used as Handler
+type HandlerFunc func(ctx context.Context, obj any) error
+
+// ProcessMsg calls f(ctx, obj)
+func (f HandlerFunc) ProcessMsg(ctx context.Context, obj any) error {
+	return f(ctx, obj)
+}
+
+// TypedFunc adapts a function to be used as TypedHandler
+type TypedFunc[T any] func(ctx context.Context, msg T) error
+
+// Process calls f(ctx, msg)
+func (f TypedFunc[T]) Process(ctx context.Context, msg T) error {
+	return f(ctx, msg)
+}
+
+// Func adapts a function receiving messages of type T to be registered as a
+// Handler, e.g. Func(func(ctx context.Context, msg TickersResponse) error {...}).
+func Func[T any](fn func(ctx context.Context, msg T) error) Handler {
+	return Typed[T](TypedFunc[T](fn))
+}
+
+type topicKey struct{}
+
+// ContextWithTopic returns a copy of ctx carrying the topic of the message
+func ContextWithTopic(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, topicKey{}, topic)
+}
+
+// TopicFromContext retrieve the topic of the message being processed,
+// available in the ctx received by Handler.ProcessMsg.
+func TopicFromContext(ctx context.Context) string {
+	topic, _ := ctx.Value(topicKey{}).(string)
+
+	return topic
+}
+
+type endpointKey struct{}
+
+// ContextWithEndpoint returns a copy of ctx carrying the endpoint that received the message
+func ContextWithEndpoint(ctx context.Context, endpoint Endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey{}, endpoint)
+}
+
+// EndpointFromContext retrieve the endpoint that received the message being
+// processed, e.g. to tell apart the spot and linear messages of a topic.
+func EndpointFromContext(ctx context.Context) (Endpoint, bool) {
+	endpoint, ok := ctx.Value(endpointKey{}).(Endpoint)
+
+	return endpoint, ok
+}
+
 type TickersHandler struct {
-	logger *log.Logger
+	logger *slog.Logger
 }
 
 func NewTickersHandler() *TickersHandler {
 	return &TickersHandler{
-		logger: log.New(os.Stdout, "[ticket-handler]", log.Lshortfile),
+		logger: logging.Default("ticker-handler"),
 	}
 }
 
@@ -23,7 +133,7 @@ func (t *TickersHandler) ProcessMsg(ctx context.Context, obj any) error {
 		return errors.New("invalid type of obj for TickersResponse")
 	}
 
-	t.logger.Printf("%+v\n", msg)
+	t.logger.Info("ticker", "topic", msg.Topic, "data", msg.Data)
 
 	return nil
 }
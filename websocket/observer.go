@@ -0,0 +1,24 @@
+package websocket
+
+import "time"
+
+// Observer receives events of the websocket client, e.g. to export metrics.
+// Methods are called from the read loop, so they should return quickly.
+type Observer interface {
+	// Connected is called every time a connection is established,
+	// reconnect is true for every connection after the first one.
+	Connected(reconnect bool)
+	// MessageReceived is called for every message received on a topic.
+	MessageReceived(topic string)
+	// MessageHandled is called once the handler of topic processed the message.
+	MessageHandled(topic string, elapsed time.Duration, err error)
+	// Pong is called for every pong, rtt is measured since the last ping sent.
+	Pong(rtt time.Duration)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) Connected(bool)                              {}
+func (nopObserver) MessageReceived(string)                      {}
+func (nopObserver) MessageHandled(string, time.Duration, error) {}
+func (nopObserver) Pong(time.Duration)                          {}
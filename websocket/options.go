@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+// ClientOption allows to customize the Client created with NewClient
+type ClientOption func(*Client)
+
+// WithLogger replace the default logger, api keys and signatures are redacted
+// from its output. A nil logger silence the client completely.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logging.Redact(logger)
+	}
+}
+
+// WithObserver sets the observer notified about connections, messages and pongs
+func WithObserver(observer Observer) ClientOption {
+	return func(c *Client) {
+		if observer == nil {
+			observer = nopObserver{}
+		}
+
+		c.observer = observer
+	}
+}
+
+// WithChannel selects the default channel and category of the subscriptions,
+// by default the public spot channel. The category is ignored by the private
+// channel. Subscriptions can use other endpoints with Request.InCategory.
+func WithChannel(channel ChannelType, category CoverType) ClientOption {
+	return func(c *Client) {
+		c.channel = channel
+		c.category = category
+	}
+}
+
+// WithBaseURL sets the scheme and host of the websocket api, by default
+// wss://stream.bybit.com. e.g. wss://stream-testnet.bybit.com for testnet.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithTradeTimeout sets how long TradeClient waits for the response of a
+// request, used when the context of the request has no deadline.
+func WithTradeTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.tradeTimeout = timeout
+	}
+}
+
+// WithReadTimeout sets the max time waiting for a message before
+// reconnecting, pongs included. By default ReadTimeout seconds, a timeout
+// <= 0 disables it.
+func WithReadTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithStaleTimeout reconnects when a topic of family, e.g. TickersTopic,
+// doesn't receive messages during timeout, e.g. TickerKeyTimeout seconds
+// for tickers of liquid symbols. The detection is disabled by default and
+// a timeout <= 0 disables it for family. Reconnections count towards
+// MaxRetrialConnections as any other, the count starts again after a stable
+// connection unless the topic didn't receive any message since connected,
+// so Run fails with ErrorSilentTopic on a dead topic. Choose a timeout
+// longer than the quietest topic of family.
+func WithStaleTimeout(family string, timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.staleTimeouts[family] = timeout
+	}
+}
+
+// WithDispatch sets how messages of topics in family, e.g. TickersTopic, are
+// passed to its handler. The family "" sets the default of every topic,
+// DispatchSync unless changed.
+func WithDispatch(family string, config DispatchConfig) ClientOption {
+	return func(c *Client) {
+		c.dispatch[family] = config
+	}
+}
+
+// WithRecorder records every raw message received by the client with recorder,
+// the recorder is not closed by the client.
+func WithRecorder(recorder *Recorder) ClientOption {
+	return func(c *Client) {
+		c.recorder = recorder
+	}
+}
+
+// WithShardSize sets the max number of topics subscribed in a connection,
+// by default ShardSize. Topics are balanced among the connections of its
+// endpoint, size <= 0 subscribes every topic in a single connection.
+func WithShardSize(size int) ClientOption {
+	return func(c *Client) {
+		c.shardSize = size
+	}
+}
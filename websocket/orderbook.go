@@ -0,0 +1,549 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
+// PriceLevel of an order book
+type PriceLevel struct {
+	Price float64
+	Size  float64
+}
+
+// Resyncer retrieves a snapshot of the order book of symbol in category, it's
+// used to recover from a gap in the sequence of deltas.
+type Resyncer func(ctx context.Context, category CoverType, symbol string, depth int) (*OrderbookData, error)
+
+// RESTResync resyncs order books with GetOrderBook of the REST client,
+// category is used for the books received without an endpoint, e.g. replayed.
+func RESTResync(client *bybitHttp.Client, category string) Resyncer {
+	return func(ctx context.Context, bookCategory CoverType, symbol string, depth int) (*OrderbookData, error) {
+		if bookCategory != "" {
+			category = string(bookCategory)
+		}
+
+		result, err := client.WithContext(ctx).GetOrderBook(bybitHttp.OrderBookParams{
+			Category: category,
+			Symbol:   symbol,
+			Limit:    depth,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if result == nil {
+			return nil, bybitHttp.ErrorUnavailableInformation
+		}
+
+		return &OrderbookData{
+			Symbol:   result.Symbol,
+			Bids:     result.Bids,
+			Asks:     result.Asks,
+			UpdateID: int64(result.UpdateID),
+			Seq:      result.Seq,
+		}, nil
+	}
+}
+
+// symbolKey identifies the books of a symbol in a category.
+type symbolKey struct {
+	category CoverType
+	symbol   string
+}
+
+// bookKey identifies a book, the same symbol can be subscribed with several
+// depths and in several categories.
+type bookKey struct {
+	symbolKey
+	depth int
+}
+
+// orderBook sorted in-memory book of a symbol, bids in descending order
+// and asks in ascending order.
+type orderBook struct {
+	bids      []PriceLevel
+	asks      []PriceLevel
+	updateID  int64
+	seq       int64
+	synced    bool
+	resyncing bool
+}
+
+// OrderBooksOption configures OrderBooks
+type OrderBooksOption func(*OrderBooks)
+
+// WithBooksCategory keeps only the books of category, the books of other
+// categories are ignored. It's also the category of BestPrice, spot by
+// default.
+func WithBooksCategory(category CoverType) OrderBooksOption {
+	return func(o *OrderBooks) {
+		o.category = category
+	}
+}
+
+// OrderBooks maintains local order books from the snapshots and deltas of
+// orderbook.* topics, register it as the Handler of those topics. Books are
+// kept by category, symbol and depth, the category is the one of the
+// endpoint the message was received from, spot when unknown. The methods
+// taking a category and a symbol use the deepest book in sync of the
+// symbol. All the methods are safe for concurrent use.
+type OrderBooks struct {
+	mu        sync.RWMutex
+	books     map[symbolKey]map[int]*orderBook
+	category  CoverType
+	resync    Resyncer
+	listeners []func(category CoverType, symbol string)
+}
+
+// NewOrderBooks creates the order books, resync is used to recover from gaps,
+// when nil the book of the symbol stays out of sync until the next snapshot.
+func NewOrderBooks(resync Resyncer, opts ...OrderBooksOption) *OrderBooks {
+	o := &OrderBooks{
+		books:  make(map[symbolKey]map[int]*orderBook),
+		resync: resync,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// OnUpdate registers fn to be called every time the book of a symbol in a
+// category changes
+func (o *OrderBooks) OnUpdate(fn func(category CoverType, symbol string)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.listeners = append(o.listeners, fn)
+}
+
+// ProcessMsg implements Handler for OrderbookResponse messages
+func (o *OrderBooks) ProcessMsg(ctx context.Context, obj any) error {
+	msg, ok := obj.(OrderbookResponse)
+	if !ok || msg.Data == nil {
+		return fmt.Errorf("%w: %T", ErrorInvalidMessage, obj)
+	}
+
+	category := o.defaultCategory()
+	if endpoint, ok := EndpointFromContext(ctx); ok && endpoint.Category != "" {
+		category = endpoint.Category
+	}
+
+	if o.category != "" && category != o.category {
+		return nil
+	}
+
+	key := bookKey{symbolKey: symbolKey{category: category, symbol: msg.Data.Symbol}, depth: topicDepth(msg.Topic)}
+
+	var err error
+	switch msg.Type {
+	case SnapshotType:
+		err = o.applySnapshot(key, msg.Data)
+	case DeltaType:
+		err = o.applyDelta(ctx, key, msg.Data)
+	default:
+		return fmt.Errorf("%w: unknown type %s", ErrorInvalidMessage, msg.Type)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	o.notify(key.symbolKey)
+
+	return nil
+}
+
+// BestBid retrieve the highest bid of symbol in category
+func (o *OrderBooks) BestBid(category CoverType, symbol string) (PriceLevel, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	book, ok := o.book(symbolKey{category: category, symbol: symbol})
+	if !ok || len(book.bids) == 0 {
+		return PriceLevel{}, false
+	}
+
+	return book.bids[0], true
+}
+
+// BestAsk retrieve the lowest ask of symbol in category
+func (o *OrderBooks) BestAsk(category CoverType, symbol string) (PriceLevel, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	book, ok := o.book(symbolKey{category: category, symbol: symbol})
+	if !ok || len(book.asks) == 0 {
+		return PriceLevel{}, false
+	}
+
+	return book.asks[0], true
+}
+
+// BestPrice implements http.PriceSource with the books of the category set
+// with WithBooksCategory, spot by default. The best bid is returned when
+// selling and the best ask when buying.
+func (o *OrderBooks) BestPrice(side, symbol string) (float64, bool) {
+	var (
+		level PriceLevel
+		ok    bool
+	)
+
+	switch side {
+	case bybitHttp.SellDirection:
+		level, ok = o.BestBid(o.defaultCategory(), symbol)
+	case bybitHttp.BuyDirection:
+		level, ok = o.BestAsk(o.defaultCategory(), symbol)
+	}
+
+	return level.Price, ok
+}
+
+// Depth retrieve a copy of the first n levels of each side of the book of
+// symbol in category, n <= 0 returns all the levels.
+func (o *OrderBooks) Depth(category CoverType, symbol string, n int) ([]PriceLevel, []PriceLevel, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	book, ok := o.book(symbolKey{category: category, symbol: symbol})
+	if !ok {
+		return nil, nil, false
+	}
+
+	return firstLevels(book.bids, n), firstLevels(book.asks, n), true
+}
+
+// Synced reports whether a book of symbol in category is in sync with the exchange
+func (o *OrderBooks) Synced(category CoverType, symbol string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	_, ok := o.book(symbolKey{category: category, symbol: symbol})
+
+	return ok
+}
+
+// Reset drops the books of the symbols provided, or every book when none
+// is provided. They are rebuilt from the next snapshot received.
+func (o *OrderBooks) Reset(symbols ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(symbols) == 0 {
+		o.books = make(map[symbolKey]map[int]*orderBook)
+
+		return
+	}
+
+	for _, symbol := range symbols {
+		for key := range o.books {
+			if key.symbol == symbol {
+				delete(o.books, key)
+			}
+		}
+	}
+}
+
+// ProcessEvent implements EventHandler, the books of the topics served by a
+// lost connection are reset until the snapshot sent after the resubscription.
+func (o *OrderBooks) ProcessEvent(_ context.Context, event Event) error {
+	if event.Type != EventDisconnected {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, topic := range event.Topics {
+		if !strings.HasPrefix(topic, OrderbookTopic+".") {
+			continue
+		}
+
+		parts := strings.Split(topic, ".")
+		for key, depths := range o.books {
+			sameCategory := event.Endpoint.Category == "" || key.category == event.Endpoint.Category
+			if sameCategory && key.symbol == parts[len(parts)-1] {
+				delete(depths, topicDepth(topic))
+			}
+		}
+	}
+
+	return nil
+}
+
+// defaultCategory of the books received without endpoint and of BestPrice
+func (o *OrderBooks) defaultCategory() CoverType {
+	if o.category != "" {
+		return o.category
+	}
+
+	return Spot
+}
+
+// book retrieves the deepest book in sync of key, must be called with the lock held.
+func (o *OrderBooks) book(key symbolKey) (*orderBook, bool) {
+	var (
+		found *orderBook
+		depth int
+	)
+
+	for d, book := range o.books[key] {
+		if book.synced && (found == nil || d > depth) {
+			found, depth = book, d
+		}
+	}
+
+	return found, found != nil
+}
+
+// get the book of key, must be called with the lock held.
+func (o *OrderBooks) get(key bookKey) (*orderBook, bool) {
+	book, ok := o.books[key.symbolKey][key.depth]
+
+	return book, ok
+}
+
+func (o *OrderBooks) applySnapshot(key bookKey, data *OrderbookData) error {
+	bids, asks, err := parseSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	depths, ok := o.books[key.symbolKey]
+	if !ok {
+		depths = make(map[int]*orderBook)
+		o.books[key.symbolKey] = depths
+	}
+
+	depths[key.depth] = &orderBook{
+		bids:     bids,
+		asks:     asks,
+		updateID: data.UpdateID,
+		seq:      data.Seq,
+		synced:   true,
+	}
+
+	return nil
+}
+
+func (o *OrderBooks) applyDelta(ctx context.Context, key bookKey, data *OrderbookData) error {
+	bids, err := parseLevels(data.Bids)
+	if err != nil {
+		return err
+	}
+
+	asks, err := parseLevels(data.Asks)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	book, ok := o.get(key)
+	if !ok {
+		// waiting for the first snapshot.
+		return nil
+	}
+
+	if !book.synced {
+		// retry in case the previous resync failed.
+		o.startResync(ctx, key, book)
+
+		return nil
+	}
+
+	// stale delta, e.g. already included in a snapshot retrieved with REST.
+	if data.UpdateID <= book.updateID {
+		return nil
+	}
+
+	if data.UpdateID != book.updateID+1 {
+		book.synced = false
+		o.startResync(ctx, key, book)
+
+		return fmt.Errorf("%w: %s expected update %d got %d", ErrorOrderBookGap, key.symbol, book.updateID+1, data.UpdateID)
+	}
+
+	for _, level := range bids {
+		book.bids = updateLevel(book.bids, level, func(a, b float64) bool { return a > b })
+	}
+
+	for _, level := range asks {
+		book.asks = updateLevel(book.asks, level, func(a, b float64) bool { return a < b })
+	}
+
+	book.updateID = data.UpdateID
+	book.seq = data.Seq
+
+	return nil
+}
+
+// startResync retrieves a snapshot in background, must be called with the lock held.
+func (o *OrderBooks) startResync(ctx context.Context, key bookKey, book *orderBook) {
+	if o.resync == nil || book.resyncing {
+		return
+	}
+
+	book.resyncing = true
+
+	go func() {
+		snapshot, err := o.resync(ctx, key.category, key.symbol, key.depth)
+		if err == nil {
+			err = o.applyResync(key, book, snapshot)
+		} else {
+			o.mu.Lock()
+			book.resyncing = false
+			o.mu.Unlock()
+		}
+
+		if err == nil {
+			o.notify(key.symbolKey)
+		}
+	}()
+}
+
+// applyResync replaces the levels of book with a snapshot retrieved with
+// REST, unless the book was replaced or resynced by the websocket in the
+// meantime or the snapshot is older than the last update applied.
+func (o *OrderBooks) applyResync(key bookKey, book *orderBook, snapshot *OrderbookData) error {
+	bids, asks, err := parseSnapshot(snapshot)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	book.resyncing = false
+	if err != nil {
+		return err
+	}
+
+	if current, ok := o.get(key); !ok || current != book || book.synced {
+		return ErrorOutdatedSnapshot
+	}
+
+	if snapshot.UpdateID < book.updateID || (snapshot.Seq != 0 && snapshot.Seq < book.seq) {
+		return ErrorOutdatedSnapshot
+	}
+
+	book.bids = bids
+	book.asks = asks
+	book.updateID = snapshot.UpdateID
+	book.seq = snapshot.Seq
+	book.synced = true
+
+	return nil
+}
+
+func (o *OrderBooks) notify(key symbolKey) {
+	o.mu.RLock()
+	listeners := o.listeners
+	o.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(key.category, key.symbol)
+	}
+}
+
+// parseSnapshot parses and sorts the levels of a snapshot
+func parseSnapshot(data *OrderbookData) ([]PriceLevel, []PriceLevel, error) {
+	bids, err := parseLevels(data.Bids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	asks, err := parseLevels(data.Asks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(bids, func(i, j int) bool { return bids[i].Price > bids[j].Price })
+	sort.Slice(asks, func(i, j int) bool { return asks[i].Price < asks[j].Price })
+
+	return bids, asks, nil
+}
+
+// updateLevel inserts, replaces or removes (size 0) the level keeping levels
+// sorted according to less.
+func updateLevel(levels []PriceLevel, level PriceLevel, less func(a, b float64) bool) []PriceLevel {
+	idx := sort.Search(len(levels), func(i int) bool {
+		return !less(levels[i].Price, level.Price)
+	})
+
+	found := idx < len(levels) && levels[idx].Price == level.Price
+
+	switch {
+	case found && level.Size == 0:
+		return append(levels[:idx], levels[idx+1:]...)
+	case found:
+		levels[idx] = level
+	case level.Size != 0:
+		levels = append(levels, PriceLevel{})
+		copy(levels[idx+1:], levels[idx:])
+		levels[idx] = level
+	}
+
+	return levels
+}
+
+func parseLevels(raw [][]string) ([]PriceLevel, error) {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, pair := range raw {
+		if len(pair) < 2 {
+			return nil, fmt.Errorf("%w: price level %v", ErrorInvalidMessage, pair)
+		}
+
+		price, err := strconv.ParseFloat(pair[0], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseFloat(pair[1], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		levels = append(levels, PriceLevel{Price: price, Size: size})
+	}
+
+	return levels, nil
+}
+
+func firstLevels(levels []PriceLevel, n int) []PriceLevel {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+
+	result := make([]PriceLevel, n)
+	copy(result, levels[:n])
+
+	return result
+}
+
+// topicDepth extracts the depth from a topic like orderbook.50.BTCUSDT
+func topicDepth(topic string) int {
+	parts := strings.Split(topic, ".")
+	if len(parts) < 3 {
+		return 0
+	}
+
+	depth, _ := strconv.Atoi(parts[1])
+
+	return depth
+}
+
+var (
+	_ bybitHttp.PriceSource = (*OrderBooks)(nil)
+	_ EventHandler          = (*OrderBooks)(nil)
+)
@@ -0,0 +1,227 @@
+package websocket_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+func bookMsg(msgType string, depth int, updateID int64, bid string) bybitWs.OrderbookResponse {
+	return bybitWs.OrderbookResponse{
+		Topic: bybitWs.OrderbookTopicFor(depth, "BTCUSDT"),
+		Type:  msgType,
+		Data: &bybitWs.OrderbookData{
+			Symbol:   "BTCUSDT",
+			Bids:     [][]string{{bid, "1"}},
+			Asks:     [][]string{{"1000", "1"}},
+			UpdateID: updateID,
+		},
+	}
+}
+
+func endpointCtx(category bybitWs.CoverType) context.Context {
+	return bybitWs.ContextWithEndpoint(context.Background(), bybitWs.Endpoint{
+		Channel:  bybitWs.PublicChannel,
+		Category: category,
+	})
+}
+
+func process(t *testing.T, books *bybitWs.OrderBooks, ctx context.Context, msg bybitWs.OrderbookResponse) {
+	t.Helper()
+
+	err := books.ProcessMsg(ctx, msg)
+	if err != nil {
+		t.Fatalf("processing %s %d: %v", msg.Type, msg.Data.UpdateID, err)
+	}
+}
+
+func bestBid(t *testing.T, books *bybitWs.OrderBooks, category bybitWs.CoverType) float64 {
+	t.Helper()
+
+	level, ok := books.BestBid(category, "BTCUSDT")
+	if !ok {
+		t.Fatal("book out of sync")
+	}
+
+	return level.Price
+}
+
+func TestOrderBooksKeyedByCategoryAndDepth(t *testing.T) {
+	spot, linear := endpointCtx(bybitWs.Spot), endpointCtx(bybitWs.Linear)
+
+	tests := []struct {
+		name   string
+		opts   []bybitWs.OrderBooksOption
+		spot   float64
+		linear float64
+		// best price of http.PriceSource.
+		sell float64
+	}{
+		{name: "every category", spot: 100, linear: 200, sell: 100},
+		{name: "spot", opts: []bybitWs.OrderBooksOption{bybitWs.WithBooksCategory(bybitWs.Spot)}, spot: 100, sell: 100},
+		{name: "linear", opts: []bybitWs.OrderBooksOption{bybitWs.WithBooksCategory(bybitWs.Linear)}, linear: 200, sell: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			books := bybitWs.NewOrderBooks(nil, tt.opts...)
+
+			process(t, books, spot, bookMsg(bybitWs.SnapshotType, 1, 1, "99"))
+			process(t, books, spot, bookMsg(bybitWs.SnapshotType, 50, 100, "100"))
+			process(t, books, linear, bookMsg(bybitWs.SnapshotType, 50, 500, "200"))
+
+			// each book keeps its own sequence, no false gaps.
+			process(t, books, spot, bookMsg(bybitWs.DeltaType, 1, 2, "98"))
+			process(t, books, spot, bookMsg(bybitWs.DeltaType, 50, 101, "100"))
+			process(t, books, linear, bookMsg(bybitWs.DeltaType, 50, 501, "200"))
+
+			for category, expected := range map[bybitWs.CoverType]float64{bybitWs.Spot: tt.spot, bybitWs.Linear: tt.linear} {
+				level, _ := books.BestBid(category, "BTCUSDT")
+				if level.Price != expected {
+					t.Fatalf("expected %s best bid %v got %v", category, expected, level.Price)
+				}
+			}
+
+			if price, _ := books.BestPrice(bybitHttp.SellDirection, "BTCUSDT"); price != tt.sell {
+				t.Fatalf("expected best price %v got %v", tt.sell, price)
+			}
+		})
+	}
+}
+
+func TestOrderBooksWithoutEndpoint(t *testing.T) {
+	books := bybitWs.NewOrderBooks(nil)
+
+	// the messages received without endpoint, e.g. replayed, are spot books.
+	process(t, books, context.Background(), bookMsg(bybitWs.SnapshotType, 50, 1, "100"))
+
+	bids, asks, ok := books.Depth(bybitWs.Spot, "BTCUSDT", 1)
+	if !ok || len(bids) != 1 || len(asks) != 1 || bids[0].Price != 100 || asks[0].Price != 1000 {
+		t.Fatalf("unexpected depth %v %v", bids, asks)
+	}
+}
+
+func TestOrderBooksReset(t *testing.T) {
+	ctx := context.Background()
+	books := bybitWs.NewOrderBooks(nil)
+
+	process(t, books, ctx, bookMsg(bybitWs.SnapshotType, 50, 1, "100"))
+
+	msg := bookMsg(bybitWs.SnapshotType, 50, 1, "200")
+	msg.Topic = bybitWs.OrderbookTopicFor(50, "ETHUSDT")
+	msg.Data.Symbol = "ETHUSDT"
+	process(t, books, ctx, msg)
+
+	books.Reset("ETHUSDT")
+
+	if got := bestBid(t, books, bybitWs.Spot); got != 100 {
+		t.Fatalf("expected the BTCUSDT book to survive, best bid %v", got)
+	}
+
+	if books.Synced(bybitWs.Spot, "ETHUSDT") {
+		t.Fatal("expected the ETHUSDT book to be dropped")
+	}
+
+	books.Reset()
+
+	if books.Synced(bybitWs.Spot, "BTCUSDT") {
+		t.Fatal("expected every book to be dropped")
+	}
+}
+
+// blockingResync returns the snapshots sent to it once the resync is requested
+type blockingResync struct {
+	calls     atomic.Int64
+	snapshots chan *bybitWs.OrderbookData
+}
+
+func (r *blockingResync) resync(context.Context, bybitWs.CoverType, string, int) (*bybitWs.OrderbookData, error) {
+	r.calls.Add(1)
+
+	return <-r.snapshots, nil
+}
+
+func restSnapshot(updateID int64, bid string) *bybitWs.OrderbookData {
+	return &bybitWs.OrderbookData{
+		Symbol:   "BTCUSDT",
+		Bids:     [][]string{{bid, "1"}},
+		Asks:     [][]string{{"1000", "1"}},
+		UpdateID: updateID,
+	}
+}
+
+func TestOrderBooksResync(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("applies a newer snapshot", func(t *testing.T) {
+		resync := &blockingResync{snapshots: make(chan *bybitWs.OrderbookData, 1)}
+		books := bybitWs.NewOrderBooks(resync.resync)
+
+		updated := make(chan string, 10)
+		books.OnUpdate(func(_ bybitWs.CoverType, symbol string) { updated <- symbol })
+
+		process(t, books, ctx, bookMsg(bybitWs.SnapshotType, 50, 10, "100"))
+		<-updated
+
+		if err := books.ProcessMsg(ctx, bookMsg(bybitWs.DeltaType, 50, 12, "101")); err == nil {
+			t.Fatal("expected a gap error")
+		}
+
+		if books.Synced(bybitWs.Spot, "BTCUSDT") {
+			t.Fatal("book in sync after a gap")
+		}
+
+		resync.snapshots <- restSnapshot(12, "102")
+		receive(t, updated)
+
+		if got := bestBid(t, books, bybitWs.Spot); got != 102 {
+			t.Fatalf("expected best bid 102 got %v", got)
+		}
+	})
+
+	t.Run("keeps a websocket snapshot received meanwhile", func(t *testing.T) {
+		resync := &blockingResync{snapshots: make(chan *bybitWs.OrderbookData)}
+		books := bybitWs.NewOrderBooks(resync.resync)
+
+		process(t, books, ctx, bookMsg(bybitWs.SnapshotType, 50, 10, "100"))
+		_ = books.ProcessMsg(ctx, bookMsg(bybitWs.DeltaType, 50, 12, "101"))
+		process(t, books, ctx, bookMsg(bybitWs.SnapshotType, 50, 20, "105"))
+
+		// blocks until the resync received it, then is discarded.
+		resync.snapshots <- restSnapshot(15, "101")
+		process(t, books, ctx, bookMsg(bybitWs.DeltaType, 50, 21, "105"))
+
+		if got := bestBid(t, books, bybitWs.Spot); got != 105 {
+			t.Fatalf("expected best bid 105 got %v", got)
+		}
+	})
+
+	t.Run("discards an older snapshot", func(t *testing.T) {
+		resync := &blockingResync{snapshots: make(chan *bybitWs.OrderbookData)}
+		books := bybitWs.NewOrderBooks(resync.resync)
+
+		process(t, books, ctx, bookMsg(bybitWs.SnapshotType, 50, 10, "100"))
+		_ = books.ProcessMsg(ctx, bookMsg(bybitWs.DeltaType, 50, 12, "101"))
+		resync.snapshots <- restSnapshot(8, "99")
+
+		// the next delta retries the resync once the previous one finished.
+		waitFor(t, func() bool {
+			_ = books.ProcessMsg(ctx, bookMsg(bybitWs.DeltaType, 50, 13, "101"))
+			return resync.calls.Load() == 2
+		})
+
+		if books.Synced(bybitWs.Spot, "BTCUSDT") {
+			t.Fatal("book synced with an older snapshot")
+		}
+
+		resync.snapshots <- restSnapshot(13, "103")
+		waitFor(t, func() bool { return books.Synced(bybitWs.Spot, "BTCUSDT") })
+
+		if got := bestBid(t, books, bybitWs.Spot); got != 103 {
+			t.Fatalf("expected best bid 103 got %v", got)
+		}
+	})
+}
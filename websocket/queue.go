@@ -0,0 +1,327 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DispatchPolicy decides how messages are passed to the handlers
+type DispatchPolicy string
+
+const (
+	// DispatchSync calls the handler in the read loop of the connection,
+	// a slow handler delays every topic of the connection.
+	DispatchSync DispatchPolicy = "sync"
+	// DispatchBlock queues the messages of each topic in order, the read
+	// loop waits when the queue is full.
+	DispatchBlock DispatchPolicy = "block"
+	// DispatchDropOldest queues the messages of each topic in order,
+	// dropping the oldest message when the queue is full.
+	DispatchDropOldest DispatchPolicy = "drop-oldest"
+	// DispatchCoalesce keeps only the latest message of each topic, e.g. the
+	// latest ticker of a symbol. Don't use it for topics sending deltas.
+	DispatchCoalesce DispatchPolicy = "coalesce"
+)
+
+// DispatchConfig policy and size of the queue of each topic, the size
+// is ignored by DispatchSync and DispatchCoalesce.
+type DispatchConfig struct {
+	Policy DispatchPolicy
+	Size   int
+}
+
+// DropObserver is implemented by observers interested in the messages
+// dropped by DispatchDropOldest and DispatchCoalesce.
+type DropObserver interface {
+	MessageDropped(topic string)
+}
+
+// queueKey the same topic of different categories has its own queue.
+type queueKey struct {
+	endpoint Endpoint
+	topic    string
+}
+
+// queuedMsg message, or connection event when event isn't nil, of a topic.
+type queuedMsg struct {
+	ctx   context.Context
+	data  []byte
+	event *Event
+}
+
+// topicQueue messages of a topic waiting for its worker. Events don't count
+// towards the size of the queue and are never dropped.
+type topicQueue struct {
+	config DispatchConfig
+
+	mu       sync.Mutex
+	items    []queuedMsg
+	messages int
+	// ready is signaled when items are added and space when removed.
+	ready chan struct{}
+	space chan struct{}
+	// stop is closed when the topic is unsubscribed.
+	stop chan struct{}
+}
+
+// put appends msg according to the policy of the queue, reporting how many
+// messages were dropped to make room for it.
+func (t *topicQueue) put(ctx context.Context, msg queuedMsg) int {
+	for {
+		t.mu.Lock()
+		if msg.event != nil || t.messages < t.config.Size {
+			t.append(msg)
+			t.mu.Unlock()
+
+			return 0
+		}
+
+		if t.config.Policy == DispatchBlock {
+			t.mu.Unlock()
+
+			select {
+			case <-t.space:
+				continue
+			case <-ctx.Done():
+				return 0
+			}
+		}
+
+		// drop the oldest messages keeping the events in place.
+		dropped := 0
+		kept := t.items[:0]
+		for _, item := range t.items {
+			if item.event == nil && t.messages >= t.config.Size {
+				t.messages--
+				dropped++
+
+				continue
+			}
+
+			kept = append(kept, item)
+		}
+		t.items = kept
+		t.append(msg)
+		t.mu.Unlock()
+
+		return dropped
+	}
+}
+
+// append must be called with the lock held.
+func (t *topicQueue) append(msg queuedMsg) {
+	t.items = append(t.items, msg)
+	if msg.event == nil {
+		t.messages++
+	}
+
+	signal(t.ready)
+}
+
+// pop removes the oldest item of the queue
+func (t *topicQueue) pop() (queuedMsg, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.items) == 0 {
+		return queuedMsg{}, false
+	}
+
+	msg := t.items[0]
+	t.items[0] = queuedMsg{}
+	t.items = t.items[1:]
+	if msg.event == nil {
+		t.messages--
+	}
+
+	signal(t.space)
+
+	return msg, true
+}
+
+// queues passes the messages of every session to the dispatcher, each topic
+// has its own queue and worker so messages of a topic are handled in order.
+type queues struct {
+	client     *Client
+	dispatcher *Dispatcher
+	ctx        context.Context
+
+	mu     sync.Mutex
+	topics map[queueKey]*topicQueue
+	wg     sync.WaitGroup
+}
+
+func newQueues(ctx context.Context, client *Client, dispatcher *Dispatcher) *queues {
+	return &queues{
+		client:     client,
+		dispatcher: dispatcher,
+		ctx:        ctx,
+		topics:     make(map[queueKey]*topicQueue),
+	}
+}
+
+// push passes the message of topic to its handler according to the policy of topic
+func (q *queues) push(ctx context.Context, topic string, data []byte) error {
+	config, _ := matchFamily(q.client.dispatch, topic)
+	if config.Policy == DispatchSync || config.Policy == "" {
+		return q.handle(ctx, topic, data)
+	}
+
+	endpoint, _ := EndpointFromContext(ctx)
+	queue := q.queue(queueKey{endpoint: endpoint, topic: topic}, config)
+
+	for dropped := queue.put(ctx, queuedMsg{ctx: ctx, data: data}); dropped > 0; dropped-- {
+		q.dropped(topic)
+	}
+
+	return nil
+}
+
+// event passes a connection event to the handler of each topic of the
+// event, queued behind the messages of the topic already queued so
+// handlers see events and messages in order. Every handler receives the
+// event with its topic in Topics.
+func (q *queues) event(ctx context.Context, event Event) error {
+	var errs []error
+	for _, topic := range event.Topics {
+		topicEvent := event
+		topicEvent.Topics = []string{topic}
+
+		config, _ := matchFamily(q.client.dispatch, topic)
+		if config.Policy == DispatchSync || config.Policy == "" {
+			err := q.dispatcher.DispatchEvent(ContextWithTopic(ctx, topic), topic, topicEvent)
+			if err != nil {
+				errs = append(errs, err)
+			}
+
+			continue
+		}
+
+		queue := q.queue(queueKey{endpoint: event.Endpoint, topic: topic}, config)
+		queue.put(ctx, queuedMsg{ctx: ctx, event: &topicEvent})
+	}
+
+	return errors.Join(errs...)
+}
+
+// remove stops the workers of the topics of endpoint once the messages
+// already queued are handled, e.g. after unsubscribing the topics.
+func (q *queues) remove(endpoint Endpoint, topics []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, topic := range topics {
+		key := queueKey{endpoint: endpoint, topic: topic}
+		if queue, ok := q.topics[key]; ok {
+			close(queue.stop)
+			delete(q.topics, key)
+		}
+	}
+}
+
+// wait blocks until the workers finished, after the context of q is done.
+func (q *queues) wait() {
+	q.wg.Wait()
+}
+
+// queue of the topic of an endpoint, starting its worker the first time.
+func (q *queues) queue(key queueKey, config DispatchConfig) *topicQueue {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	queue, ok := q.topics[key]
+	if ok {
+		return queue
+	}
+
+	if config.Size <= 0 || config.Policy == DispatchCoalesce {
+		config.Size = 1
+	}
+
+	queue = &topicQueue{
+		config: config,
+		ready:  make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	q.topics[key] = queue
+
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+
+		for {
+			select {
+			case <-q.ctx.Done():
+				return
+			case <-queue.ready:
+				q.drain(key.topic, queue)
+			case <-queue.stop:
+				q.drain(key.topic, queue)
+
+				return
+			}
+		}
+	}()
+
+	return queue
+}
+
+// drain passes the queued messages of topic to its handler
+func (q *queues) drain(topic string, queue *topicQueue) {
+	for msg, ok := queue.pop(); ok && q.ctx.Err() == nil; msg, ok = queue.pop() {
+		var err error
+		if msg.event != nil {
+			err = q.dispatcher.DispatchEvent(ContextWithTopic(msg.ctx, topic), topic, *msg.event)
+		} else {
+			err = q.handle(msg.ctx, topic, msg.data)
+		}
+
+		if err != nil {
+			q.client.logger.Error("processing message", "topic", topic, "error", err)
+		}
+	}
+}
+
+func (q *queues) handle(ctx context.Context, topic string, data []byte) error {
+	start := time.Now()
+	err := q.dispatcher.Dispatch(ContextWithTopic(ctx, topic), topic, data)
+	q.client.observer.MessageHandled(topic, time.Since(start), err)
+
+	return err
+}
+
+func (q *queues) dropped(topic string) {
+	q.client.logger.Debug("message dropped", "topic", topic)
+
+	if observer, ok := q.client.observer.(DropObserver); ok {
+		observer.MessageDropped(topic)
+	}
+}
+
+// signal wakes up the receiver of ch without blocking
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// matchFamily retrieves the value of the longest topic family in values
+// matching topic, the family "" matches every topic.
+func matchFamily[T any](values map[string]T, topic string) (T, bool) {
+	family, found := "", false
+	for f := range values {
+		matches := f == "" || topic == f || strings.HasPrefix(topic, f+".")
+		if matches && (!found || len(f) > len(family)) {
+			family, found = f, true
+		}
+	}
+
+	value, ok := values[family]
+
+	return value, found && ok
+}
@@ -0,0 +1,247 @@
+package websocket
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Gealber/bybit/logging"
+)
+
+// Record raw message received from an endpoint, stored as a JSON line.
+type Record struct {
+	// TS unix nano time when the message was received.
+	TS       int64           `json:"ts"`
+	Endpoint string          `json:"endpoint"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// Recorder appends the raw messages received by the client to a gzip
+// file, register it with WithRecorder. Every time the file is opened a new
+// gzip member is appended, which gzip readers handle transparently.
+// Records are flushed to the file every RecordFlushInterval seconds and on
+// Close, a crash loses at most the records of the last interval.
+type Recorder struct {
+	mu    sync.Mutex
+	file  *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	dirty bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewRecorder opens, or creates, the file at path to append messages
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(file)
+	r := &Recorder{
+		file: file,
+		gz:   gz,
+		enc:  json.NewEncoder(gz),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go r.flushLoop()
+
+	return r, nil
+}
+
+// Record appends the message received at receivedAt from endpoint
+func (r *Recorder) Record(receivedAt time.Time, endpoint Endpoint, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dirty = true
+
+	return r.enc.Encode(Record{
+		TS:       receivedAt.UnixNano(),
+		Endpoint: endpoint.String(),
+		Data:     data,
+	})
+}
+
+// Flush writes the pending records to the file
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.dirty {
+		return nil
+	}
+
+	r.dirty = false
+
+	return r.gz.Flush()
+}
+
+// Close flushes the pending records, finishes the gzip member and closes
+// the file. Further calls return the error of the first one.
+func (r *Recorder) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.closeErr = errors.Join(r.gz.Close(), r.file.Close())
+	})
+
+	return r.closeErr
+}
+
+func (r *Recorder) flushLoop() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(RecordFlushInterval * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			// errors are returned again by Record and Close.
+			_ = r.Flush()
+		}
+	}
+}
+
+// ReplayStats summary of a replay
+type ReplayStats struct {
+	// Messages dispatched to handlers, operations like pongs are skipped.
+	Messages int
+	// Errors returned by the handlers.
+	Errors int
+}
+
+// Replayer feeds the messages of a file written by Recorder to a Dispatcher
+type Replayer struct {
+	path   string
+	speed  float64
+	logger *slog.Logger
+}
+
+// ReplayOption configures a Replayer
+type ReplayOption func(*Replayer)
+
+// WithReplayLogger sets the logger of the replayer
+func WithReplayLogger(logger *slog.Logger) ReplayOption {
+	return func(r *Replayer) {
+		r.logger = logging.Redact(logger)
+	}
+}
+
+// NewReplayer creates a replayer of the file at path. speed 1 replays the
+// messages at the original pace, 2 twice as fast and so on, speed <= 0
+// replays them as fast as possible.
+func NewReplayer(path string, speed float64, opts ...ReplayOption) *Replayer {
+	r := &Replayer{
+		path:   path,
+		speed:  speed,
+		logger: logging.Default("bybit-replay"),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Replay dispatches every recorded message, in order, to the handler of its
+// topic. Errors of handlers are logged and counted, the replay stops on
+// errors reading the file or when ctx is done.
+func (r *Replayer) Replay(ctx context.Context, dispatcher *Dispatcher) (ReplayStats, error) {
+	var stats ReplayStats
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return stats, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return stats, err
+	}
+	defer gz.Close()
+
+	var (
+		dec   = json.NewDecoder(gz)
+		first int64
+		start = time.Now()
+	)
+
+	for {
+		var record Record
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		}
+
+		if err != nil {
+			return stats, err
+		}
+
+		if first == 0 {
+			first = record.TS
+		}
+
+		err = r.wait(ctx, start, time.Duration(record.TS-first))
+		if err != nil {
+			return stats, err
+		}
+
+		var msg PublicResponse
+		if err := json.Unmarshal(record.Data, &msg); err != nil || msg.Topic == "" {
+			continue
+		}
+
+		stats.Messages++
+		msgCtx := ContextWithEndpoint(ContextWithTopic(ctx, msg.Topic), parseEndpoint(record.Endpoint))
+		err = dispatcher.Dispatch(msgCtx, msg.Topic, record.Data)
+		if err != nil {
+			stats.Errors++
+			r.logger.Error("processing message", "topic", msg.Topic, "error", err)
+		}
+	}
+}
+
+// wait sleeps until the moment, scaled by speed, the message was received.
+func (r *Replayer) wait(ctx context.Context, start time.Time, offset time.Duration) error {
+	if r.speed <= 0 {
+		return ctx.Err()
+	}
+
+	delay := time.Until(start.Add(time.Duration(float64(offset) / r.speed)))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
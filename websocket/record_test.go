@@ -0,0 +1,138 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Gealber/bybit/logging"
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl.gz")
+
+	recorder, err := bybitWs.NewRecorder(path)
+	if err != nil {
+		t.Fatalf("creating recorder: %v", err)
+	}
+
+	spot := bybitWs.Endpoint{Channel: bybitWs.PublicChannel, Category: bybitWs.Spot}
+	start := time.Now()
+	for i, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"} {
+		data, err := json.Marshal(bybitWs.TickersResponse{
+			Topic: bybitWs.TickersTopicFor(symbol),
+			Type:  bybitWs.SnapshotType,
+			Data:  &bybitWs.TickersData{Symbol: symbol},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = recorder.Record(start.Add(time.Duration(i)*time.Millisecond), spot, data)
+		if err != nil {
+			t.Fatalf("recording: %v", err)
+		}
+	}
+
+	// operations like pongs are recorded but not replayed.
+	err = recorder.Record(start, spot, []byte(`{"success":true,"ret_msg":"pong","op":"ping"}`))
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+
+	err = recorder.Close()
+	if err != nil {
+		t.Fatalf("closing recorder: %v", err)
+	}
+
+	// closing again doesn't panic, e.g. a deferred Close.
+	err = recorder.Close()
+	if err != nil {
+		t.Fatalf("closing recorder again: %v", err)
+	}
+
+	symbols := make([]string, 0)
+	dispatcher := bybitWs.NewDispatcher()
+	_ = dispatcher.Handle("tickers.*", bybitWs.Func(func(ctx context.Context, msg bybitWs.TickersResponse) error {
+		endpoint, ok := bybitWs.EndpointFromContext(ctx)
+		if !ok || endpoint != spot {
+			t.Errorf("unexpected endpoint %v", endpoint)
+		}
+
+		symbols = append(symbols, msg.Data.Symbol)
+
+		return nil
+	}))
+
+	replayer := bybitWs.NewReplayer(path, 0, bybitWs.WithReplayLogger(logging.Discard()))
+
+	stats, err := replayer.Replay(context.Background(), dispatcher)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+
+	if stats.Messages != 3 || stats.Errors != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	if len(symbols) != 3 || symbols[0] != "BTCUSDT" || symbols[2] != "SOLUSDT" {
+		t.Fatalf("unexpected symbols %v", symbols)
+	}
+}
+
+func TestRunRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.jsonl.gz")
+
+	recorder, err := bybitWs.NewRecorder(path)
+	if err != nil {
+		t.Fatalf("creating recorder: %v", err)
+	}
+	defer recorder.Close()
+
+	server := newServer(t)
+	client := newClient(server, bybitWs.WithRecorder(recorder))
+
+	topic := bybitWs.TickersTopicFor("BTCUSDT")
+	recorded := newTickersRecorder()
+	run(t, client, []bybitWs.Request{bybitWs.Subscribe(topic)}, map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](recorded),
+	})
+
+	waitSubscribed(t, server, topic)
+	publishTicker(t, server, "BTCUSDT")
+	receive(t, recorded.messages)
+
+	// the client stops recording once closed.
+	_ = client.Close()
+	err = recorder.Close()
+	if err != nil {
+		t.Fatalf("closing recorder: %v", err)
+	}
+
+	replayed := newTickersRecorder()
+	dispatcher, err := bybitWs.NewDispatcherFromMap(map[string]bybitWs.Handler{
+		topic: bybitWs.Typed[bybitWs.TickersResponse](replayed),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer := bybitWs.NewReplayer(path, 0, bybitWs.WithReplayLogger(logging.Discard()))
+
+	stats, err := replayer.Replay(context.Background(), dispatcher)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+
+	if stats.Messages != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	msg := receive(t, replayed.messages)
+	if msg.Topic != topic || msg.Data.Symbol != "BTCUSDT" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
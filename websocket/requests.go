@@ -1,7 +1,21 @@
 package websocket
 
+// Request sent to bybit websocket. Channel and Category are not sent, they
+// select the connection used for a subscription, by default the channel
+// and category of the client or the private channel for private topics.
 type Request struct {
 	ReqID string        `json:"req_id,omitempty"`
 	Op    string        `json:"op,omitempty"`
 	Args  []interface{} `json:"args,omitempty"`
+
+	Channel  ChannelType `json:"-"`
+	Category CoverType   `json:"-"`
+}
+
+// InCategory returns a copy of the request sent to the public channel of category
+func (r Request) InCategory(category CoverType) Request {
+	r.Channel = PublicChannel
+	r.Category = category
+
+	return r
 }
@@ -1,5 +1,11 @@
 package websocket
 
+import (
+	"encoding/json"
+
+	bybitHttp "github.com/Gealber/bybit/http"
+)
+
 type PublicResponse struct {
 	Topic string      `json:"topic"`
 	Type  string      `json:"type"`
@@ -7,11 +13,13 @@ type PublicResponse struct {
 	Data  interface{} `json:"data"`
 }
 
+// PrivateResponse envelope of the messages of private topics, the typed
+// messages like OrderMessage are delivered to the handlers instead.
 type PrivateResponse struct {
-	ID           string                   `json:"id"`
-	Topic        string                   `json:"topic"`
-	CreationTime int64                    `json:"creationTime"`
-	Data         []map[string]interface{} `json:"data"`
+	ID           string          `json:"id"`
+	Topic        string          `json:"topic"`
+	CreationTime int64           `json:"creationTime"`
+	Data         json.RawMessage `json:"data"`
 }
 
 type TickersResponse struct {
@@ -43,3 +51,344 @@ type TickersData struct {
 	Ask1Price         string `json:"ask1Price"`
 	Ask1Size          string `json:"ask1Size"`
 }
+
+// OperationResponse response to operations like ping, subscribe or auth
+type OperationResponse struct {
+	Success bool   `json:"success"`
+	RetMsg  string `json:"ret_msg"`
+	ConnID  string `json:"conn_id"`
+	ReqID   string `json:"req_id"`
+	Op      string `json:"op"`
+}
+
+func (o OperationResponse) isPong() bool {
+	return o.Op == "pong" || o.RetMsg == "pong"
+}
+
+type OrderbookResponse struct {
+	Topic string         `json:"topic"`
+	Type  string         `json:"type"`
+	TS    int64          `json:"ts"`
+	Data  *OrderbookData `json:"data"`
+	CTS   int64          `json:"cts"`
+}
+
+// OrderbookData bids and asks are pairs of [price, size], size 0 in a
+// delta means the level should be removed.
+type OrderbookData struct {
+	Symbol   string     `json:"s"`
+	Bids     [][]string `json:"b"`
+	Asks     [][]string `json:"a"`
+	UpdateID int64      `json:"u"`
+	Seq      int64      `json:"seq"`
+}
+
+type PublicTradeResponse struct {
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	TS    int64       `json:"ts"`
+	Data  []TradeData `json:"data"`
+}
+
+type TradeData struct {
+	Timestamp     int64  `json:"T"`
+	Symbol        string `json:"s"`
+	Side          string `json:"S"`
+	Size          string `json:"v"`
+	Price         string `json:"p"`
+	TickDirection string `json:"L"`
+	TradeID       string `json:"i"`
+	BlockTrade    bool   `json:"BT"`
+}
+
+type KlineResponse struct {
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	TS    int64       `json:"ts"`
+	Data  []KlineData `json:"data"`
+}
+
+type KlineData struct {
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	Interval  string `json:"interval"`
+	Open      string `json:"open"`
+	Close     string `json:"close"`
+	High      string `json:"high"`
+	Low       string `json:"low"`
+	Volume    string `json:"volume"`
+	Turnover  string `json:"turnover"`
+	Confirm   bool   `json:"confirm"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type LiquidationResponse struct {
+	Topic string           `json:"topic"`
+	Type  string           `json:"type"`
+	TS    int64            `json:"ts"`
+	Data  *LiquidationData `json:"data"`
+}
+
+type LiquidationData struct {
+	UpdatedTime int64  `json:"updatedTime"`
+	Symbol      string `json:"symbol"`
+	Side        string `json:"side"`
+	Size        string `json:"size"`
+	Price       string `json:"price"`
+}
+
+type LTTickersResponse struct {
+	Topic string         `json:"topic"`
+	Type  string         `json:"type"`
+	TS    int64          `json:"ts"`
+	Data  *LTTickersData `json:"data"`
+}
+
+type LTTickersData struct {
+	Symbol       string `json:"symbol"`
+	Price24HPcnt string `json:"price24hPcnt"`
+	LastPrice    string `json:"lastPrice"`
+	PrevPrice24H string `json:"prevPrice24h"`
+	HighPrice24H string `json:"highPrice24h"`
+	LowPrice24H  string `json:"lowPrice24h"`
+}
+
+type LTNavResponse struct {
+	Topic string     `json:"topic"`
+	Type  string     `json:"type"`
+	TS    int64      `json:"ts"`
+	Data  *LTNavData `json:"data"`
+}
+
+type LTNavData struct {
+	Time           int64  `json:"time"`
+	Symbol         string `json:"symbol"`
+	Nav            string `json:"nav"`
+	BasketPosition string `json:"basketPosition"`
+	Leverage       string `json:"leverage"`
+	BasketLoan     string `json:"basketLoan"`
+	Circulation    string `json:"circulation"`
+	Basket         string `json:"basket"`
+}
+
+type OrderMessage struct {
+	ID           string      `json:"id"`
+	Topic        string      `json:"topic"`
+	CreationTime int64       `json:"creationTime"`
+	Data         []OrderData `json:"data"`
+}
+
+// OrderLinkIDs of the orders in the message
+func (m OrderMessage) OrderLinkIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, order := range m.Data {
+		ids = append(ids, order.OrderLinkID)
+	}
+
+	return ids
+}
+
+// OrderIDs of the orders in the message
+func (m OrderMessage) OrderIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, order := range m.Data {
+		ids = append(ids, order.OrderID)
+	}
+
+	return ids
+}
+
+// OrderData same order returned by the REST api with the fields only
+// present in the websocket stream.
+type OrderData struct {
+	Category string `json:"category"`
+	bybitHttp.Order
+	FeeCurrency  string `json:"feeCurrency"`
+	ClosedPnl    string `json:"closedPnl"`
+	PlaceType    string `json:"placeType"`
+	SmpType      string `json:"smpType"`
+	SmpGroup     int    `json:"smpGroup"`
+	SmpOrderID   string `json:"smpOrderId"`
+	CreateType   string `json:"createType"`
+	MarketUnit   string `json:"marketUnit"`
+	OcoTriggerBy string `json:"ocoTriggerBy"`
+}
+
+type ExecutionMessage struct {
+	ID           string          `json:"id"`
+	Topic        string          `json:"topic"`
+	CreationTime int64           `json:"creationTime"`
+	Data         []ExecutionData `json:"data"`
+}
+
+// OrderLinkIDs of the orders executed in the message
+func (m ExecutionMessage) OrderLinkIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, execution := range m.Data {
+		ids = append(ids, execution.OrderLinkID)
+	}
+
+	return ids
+}
+
+// OrderIDs of the orders executed in the message
+func (m ExecutionMessage) OrderIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, execution := range m.Data {
+		ids = append(ids, execution.OrderID)
+	}
+
+	return ids
+}
+
+type ExecutionData struct {
+	Category        string `json:"category"`
+	Symbol          string `json:"symbol"`
+	IsLeverage      string `json:"isLeverage"`
+	OrderID         string `json:"orderId"`
+	OrderLinkID     string `json:"orderLinkId"`
+	Side            string `json:"side"`
+	OrderPrice      string `json:"orderPrice"`
+	OrderQty        string `json:"orderQty"`
+	LeavesQty       string `json:"leavesQty"`
+	CreateType      string `json:"createType"`
+	OrderType       string `json:"orderType"`
+	StopOrderType   string `json:"stopOrderType"`
+	ExecFee         string `json:"execFee"`
+	ExecID          string `json:"execId"`
+	ExecPrice       string `json:"execPrice"`
+	ExecQty         string `json:"execQty"`
+	ExecPnl         string `json:"execPnl"`
+	ExecType        string `json:"execType"`
+	ExecValue       string `json:"execValue"`
+	ExecTime        string `json:"execTime"`
+	IsMaker         bool   `json:"isMaker"`
+	FeeRate         string `json:"feeRate"`
+	TradeIv         string `json:"tradeIv"`
+	MarkIv          string `json:"markIv"`
+	MarkPrice       string `json:"markPrice"`
+	IndexPrice      string `json:"indexPrice"`
+	UnderlyingPrice string `json:"underlyingPrice"`
+	BlockTradeID    string `json:"blockTradeId"`
+	ClosedSize      string `json:"closedSize"`
+	Seq             int64  `json:"seq"`
+}
+
+type FastExecutionMessage struct {
+	Topic        string              `json:"topic"`
+	CreationTime int64               `json:"creationTime"`
+	Data         []FastExecutionData `json:"data"`
+}
+
+// OrderLinkIDs of the orders executed in the message
+func (m FastExecutionMessage) OrderLinkIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, execution := range m.Data {
+		ids = append(ids, execution.OrderLinkID)
+	}
+
+	return ids
+}
+
+// OrderIDs of the orders executed in the message
+func (m FastExecutionMessage) OrderIDs() []string {
+	ids := make([]string, 0, len(m.Data))
+	for _, execution := range m.Data {
+		ids = append(ids, execution.OrderID)
+	}
+
+	return ids
+}
+
+type FastExecutionData struct {
+	Category    string `json:"category"`
+	Symbol      string `json:"symbol"`
+	ExecID      string `json:"execId"`
+	ExecPrice   string `json:"execPrice"`
+	ExecQty     string `json:"execQty"`
+	OrderID     string `json:"orderId"`
+	IsMaker     bool   `json:"isMaker"`
+	OrderLinkID string `json:"orderLinkId"`
+	Side        string `json:"side"`
+	ExecTime    string `json:"execTime"`
+	Seq         int64  `json:"seq"`
+}
+
+type PositionMessage struct {
+	ID           string         `json:"id"`
+	Topic        string         `json:"topic"`
+	CreationTime int64          `json:"creationTime"`
+	Data         []PositionData `json:"data"`
+}
+
+type PositionData struct {
+	Category               string `json:"category"`
+	Symbol                 string `json:"symbol"`
+	Side                   string `json:"side"`
+	Size                   string `json:"size"`
+	PositionIdx            int    `json:"positionIdx"`
+	TradeMode              int    `json:"tradeMode"`
+	PositionValue          string `json:"positionValue"`
+	RiskID                 int    `json:"riskId"`
+	RiskLimitValue         string `json:"riskLimitValue"`
+	EntryPrice             string `json:"entryPrice"`
+	MarkPrice              string `json:"markPrice"`
+	Leverage               string `json:"leverage"`
+	PositionBalance        string `json:"positionBalance"`
+	AutoAddMargin          int    `json:"autoAddMargin"`
+	PositionMM             string `json:"positionMM"`
+	PositionIM             string `json:"positionIM"`
+	LiqPrice               string `json:"liqPrice"`
+	BustPrice              string `json:"bustPrice"`
+	TpslMode               string `json:"tpslMode"`
+	TakeProfit             string `json:"takeProfit"`
+	StopLoss               string `json:"stopLoss"`
+	TrailingStop           string `json:"trailingStop"`
+	UnrealisedPnl          string `json:"unrealisedPnl"`
+	CurRealisedPnl         string `json:"curRealisedPnl"`
+	CumRealisedPnl         string `json:"cumRealisedPnl"`
+	SessionAvgPrice        string `json:"sessionAvgPrice"`
+	PositionStatus         string `json:"positionStatus"`
+	AdlRankIndicator       int    `json:"adlRankIndicator"`
+	IsReduceOnly           bool   `json:"isReduceOnly"`
+	MmrSysUpdatedTime      string `json:"mmrSysUpdatedTime"`
+	LeverageSysUpdatedTime string `json:"leverageSysUpdatedTime"`
+	CreatedTime            string `json:"createdTime"`
+	UpdatedTime            string `json:"updatedTime"`
+	Seq                    int64  `json:"seq"`
+}
+
+type WalletMessage struct {
+	ID           string                    `json:"id"`
+	Topic        string                    `json:"topic"`
+	CreationTime int64                     `json:"creationTime"`
+	Data         []bybitHttp.WalletBalance `json:"data"`
+}
+
+type GreeksMessage struct {
+	ID           string       `json:"id"`
+	Topic        string       `json:"topic"`
+	CreationTime int64        `json:"creationTime"`
+	Data         []GreeksData `json:"data"`
+}
+
+type GreeksData struct {
+	BaseCoin   string `json:"baseCoin"`
+	TotalDelta string `json:"totalDelta"`
+	TotalGamma string `json:"totalGamma"`
+	TotalVega  string `json:"totalVega"`
+	TotalTheta string `json:"totalTheta"`
+}
+
+type DCPMessage struct {
+	ID           string    `json:"id"`
+	Topic        string    `json:"topic"`
+	CreationTime int64     `json:"creationTime"`
+	Data         []DCPData `json:"data"`
+}
+
+type DCPData struct {
+	Product    string `json:"product"`
+	DcpStatus  string `json:"dcpStatus"`
+	TimeWindow int    `json:"timeWindow"`
+}
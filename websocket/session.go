@@ -0,0 +1,661 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Endpoint identifies a websocket connection, the category is empty for
+// the private and trade channels which serve every category.
+type Endpoint struct {
+	Channel  ChannelType
+	Category CoverType
+}
+
+func (e Endpoint) String() string {
+	if e.Category == "" {
+		return string(e.Channel)
+	}
+
+	return fmt.Sprintf("%s/%s", e.Channel, e.Category)
+}
+
+// parseEndpoint parses the result of Endpoint.String
+func parseEndpoint(value string) Endpoint {
+	channel, category, _ := strings.Cut(value, "/")
+
+	return Endpoint{Channel: ChannelType(channel), Category: CoverType(category)}
+}
+
+// session connection with a single endpoint and its subscriptions.
+type session struct {
+	client   *Client
+	endpoint Endpoint
+	queues   *queues
+	// pingSeq used to generate the req_id of pings.
+	pingSeq atomic.Int64
+
+	// writeMu serializes writes, the connection supports one writer at a time.
+	writeMu sync.Mutex
+
+	mu sync.Mutex
+	// desired topics in order of subscription, subscribed on every connection.
+	desired []string
+	conn    *websocket.Conn
+	done    chan struct{}
+	pending map[string]chan OperationResponse
+	// pings sent waiting for its pong, by req_id.
+	pings map[string]time.Time
+	// lastMsg time of the last message of each topic.
+	lastMsg map[string]time.Time
+}
+
+func newSession(client *Client, endpoint Endpoint, queues *queues) *session {
+	return &session{
+		client:   client,
+		endpoint: endpoint,
+		queues:   queues,
+		pending:  make(map[string]chan OperationResponse),
+		pings:    make(map[string]time.Time),
+		lastMsg:  make(map[string]time.Time),
+	}
+}
+
+// run keeps the session connected until ctx is done, every connection is
+// torn down completely before reconnecting.
+func (s *session) run(ctx context.Context, dispatcher *Dispatcher) error {
+	c := s.client
+
+	connections := 0
+	for {
+		start := time.Now()
+		err := s.serve(ctx, dispatcher, connections > 0)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// the connection was stable, start counting again. A topic silent
+		// since connected is likely dead, its reconnections keep counting so
+		// the session gives up after MaxRetrialConnections.
+		if time.Since(start) > StableConnectionTimeout*time.Second && !errors.Is(err, ErrorSilentTopic) {
+			connections = 0
+		}
+		connections++
+
+		waitTime, ok := retriableError(err, connections)
+		if !ok || connections > MaxRetrialConnections {
+			return err
+		}
+
+		s.broadcast(ctx, EventDisconnected, err)
+
+		c.logger.Warn("reconnecting after abnormal closure", "endpoint", s.endpoint, "error", err, "wait", waitTime)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(waitTime):
+		}
+	}
+}
+
+// serve runs a single connection until it fails or ctx is done, returning
+// once the connection is closed and its reader finished.
+func (s *session) serve(ctx context.Context, dispatcher *Dispatcher, reconnect bool) error {
+	c := s.client
+
+	conn, err := c.connect(ctx, s.endpoint)
+	if err != nil {
+		return err
+	}
+
+	readErr := make(chan error, 1)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer conn.Close()
+
+	// authenticate on every connection, including reconnections.
+	if s.endpoint.Channel == PrivateChannel {
+		// the connection is closed to interrupt the authentication.
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		err := c.authenticate(conn)
+		stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	// topics subscribed from now on are sent by Subscribe.
+	done := make(chan struct{})
+	connectedAt := time.Now()
+	s.mu.Lock()
+	s.conn, s.done = conn, done
+	s.pings = make(map[string]time.Time)
+	topics := append([]string(nil), s.desired...)
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn, s.done = nil, nil
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	// first ping to send.
+	err = s.sendPing(conn)
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		readErr <- s.processRead(ctx, conn)
+	}()
+
+	// the acknowledgements are awaited in background, the topics rejected
+	// are dropped so they aren't reported as stale. The reconnection is
+	// broadcast once acknowledged, unless the connection was lost meanwhile.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		err := s.subscribe(ctx, subscription{topics: topics, conn: conn, done: done})
+		if err != nil {
+			c.logger.Error("resubscribing", "endpoint", s.endpoint, "error", err)
+		}
+
+		select {
+		case <-done:
+		default:
+			if reconnect {
+				s.broadcast(ctx, EventReconnected, err)
+			}
+		}
+	}()
+
+	c.observer.Connected(reconnect)
+
+	pingTicker := time.NewTicker(PingTimeout * time.Second)
+	defer pingTicker.Stop()
+
+	healthTicker := time.NewTicker(HealthCheckInterval * time.Second)
+	defer healthTicker.Stop()
+
+	// wait for possible errors and cancellation.
+	// send ping command every PingTimeout seconds.
+	// reconnect when a pong is missing or a topic is stale.
+	for {
+		select {
+		case err := <-readErr:
+			return err
+		case <-ctx.Done():
+			return s.closeConn(conn, readErr)
+		case <-pingTicker.C:
+			err := s.sendPing(conn)
+			if err != nil {
+				return err
+			}
+		case <-healthTicker.C:
+			err := s.checkHealth(connectedAt)
+			if err != nil {
+				c.logger.Warn("unhealthy connection", "endpoint", s.endpoint, "error", err)
+
+				return err
+			}
+		}
+	}
+}
+
+// broadcast notifies the handlers of the topics of the session about an
+// event, in order with the messages of each topic.
+func (s *session) broadcast(ctx context.Context, eventType EventType, cause error) {
+	event := Event{
+		Type:     eventType,
+		Endpoint: s.endpoint,
+		Topics:   s.topics(),
+		Err:      cause,
+	}
+
+	err := s.queues.event(ctx, event)
+	if err != nil {
+		s.client.logger.Error("processing event", "endpoint", s.endpoint, "event", eventType, "error", err)
+	}
+}
+
+// topics subscribed in the session
+func (s *session) topics() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.desired...)
+}
+
+// subscription topics added to a session, pending to be sent in conn.
+type subscription struct {
+	topics []string
+	conn   *websocket.Conn
+	done   chan struct{}
+}
+
+// add adds topics to the session, returning those not present yet. They are
+// sent with subscribe, or once connected when the session is not connected.
+func (s *session) add(topics []string) subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if !contains(s.desired, topic) && !contains(added, topic) {
+			added = append(added, topic)
+		}
+	}
+	s.desired = append(s.desired, added...)
+
+	return subscription{topics: added, conn: s.conn, done: s.done}
+}
+
+// subscribe sends the topics added waiting for the acknowledgement of bybit,
+// rejected topics are dropped.
+func (s *session) subscribe(ctx context.Context, sub subscription) error {
+	// not connected yet, topics are subscribed once connected.
+	if sub.conn == nil || len(sub.topics) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, args := range chunkTopics(s.endpoint, sub.topics) {
+		err := s.request(ctx, sub.conn, sub.done, SubscribeOp, args)
+		if errors.Is(err, ErrorSubscriptionRejected) && len(args) > 1 {
+			// bybit rejects the whole request, retried one by one so only
+			// the topics rejected are dropped.
+			err = s.subscribeEach(ctx, sub, args)
+		} else if errors.Is(err, ErrorSubscriptionRejected) {
+			s.queues.remove(s.endpoint, s.remove(args))
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// subscribeEach subscribes every topic of args in its own request, dropping
+// the topics rejected.
+func (s *session) subscribeEach(ctx context.Context, sub subscription, args []interface{}) error {
+	var errs []error
+	for _, arg := range args {
+		topic := []interface{}{arg}
+
+		err := s.request(ctx, sub.conn, sub.done, SubscribeOp, topic)
+		if errors.Is(err, ErrorSubscriptionRejected) {
+			s.queues.remove(s.endpoint, s.remove(topic))
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// has reports whether topic is subscribed in the session
+func (s *session) has(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return contains(s.desired, topic)
+}
+
+// unsubscribe removes topics from the session, when connected they are
+// unsubscribed waiting for the acknowledgement of bybit. The queues of the
+// topics are removed afterwards.
+func (s *session) unsubscribe(ctx context.Context, topics []string) error {
+	removed := s.remove(topicArgs(topics))
+	defer s.queues.remove(s.endpoint, removed)
+
+	s.mu.Lock()
+	conn, done := s.conn, s.done
+	s.mu.Unlock()
+
+	if conn == nil || len(removed) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, args := range chunkTopics(s.endpoint, removed) {
+		err := s.request(ctx, conn, done, UnsubscribeOp, args)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// remove drops args from the desired topics, returning the topics removed.
+func (s *session) remove(args []interface{}) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := make([]string, 0, len(args))
+	desired := make([]string, 0, len(s.desired))
+	for _, topic := range s.desired {
+		if contains(args, interface{}(topic)) {
+			removed = append(removed, topic)
+			delete(s.lastMsg, topic)
+
+			continue
+		}
+
+		desired = append(desired, topic)
+	}
+	s.desired = desired
+
+	return removed
+}
+
+// request sends op with args waiting for its acknowledgement. A lost
+// connection is not an error, the desired topics are sent on reconnection.
+func (s *session) request(
+	ctx context.Context,
+	conn *websocket.Conn,
+	done chan struct{},
+	op string,
+	args []interface{},
+) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, SubscriptionTimeout*time.Second)
+		defer cancel()
+	}
+
+	reqID := uuid.New().String()
+	ack := make(chan OperationResponse, 1)
+
+	s.mu.Lock()
+	s.pending[reqID] = ack
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, reqID)
+		s.mu.Unlock()
+	}()
+
+	err := s.write(conn, &Request{ReqID: reqID, Op: op, Args: args})
+	if err != nil {
+		return fmt.Errorf("sending %s %w", op, err)
+	}
+
+	select {
+	case response := <-ack:
+		if !response.Success {
+			return fmt.Errorf("%w: %s %v %s", ErrorSubscriptionRejected, op, args, response.RetMsg)
+		}
+
+		return nil
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %s %v %v", ErrorRequestTimeout, op, args, ctx.Err())
+	}
+}
+
+func (s *session) write(conn *websocket.Conn, v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return conn.WriteJSON(v)
+}
+
+func (s *session) sendPing(conn *websocket.Conn) error {
+	pingReq := Request{
+		ReqID: strconv.FormatInt(s.pingSeq.Add(1), 10),
+		Op:    PingOp,
+	}
+
+	s.mu.Lock()
+	s.pings[pingReq.ReqID] = time.Now()
+	s.mu.Unlock()
+
+	return s.write(conn, &pingReq)
+}
+
+// pong matches the pong with its ping, reporting the round trip time.
+// Pongs without req_id acknowledge every ping sent.
+func (s *session) pong(reqID string) {
+	now := time.Now()
+
+	s.mu.Lock()
+	var sent time.Time
+	if reqID == "" {
+		for id, t := range s.pings {
+			if sent.IsZero() || t.Before(sent) {
+				sent = t
+			}
+			delete(s.pings, id)
+		}
+	} else {
+		sent = s.pings[reqID]
+		delete(s.pings, reqID)
+	}
+	s.mu.Unlock()
+
+	if sent.IsZero() {
+		return
+	}
+
+	rtt := now.Sub(sent)
+	s.client.observer.Pong(rtt)
+	s.client.logger.Debug("pong", "endpoint", s.endpoint, "rtt", rtt)
+}
+
+// checkHealth fails when a ping was not answered in PongTimeout seconds or a
+// topic didn't receive messages in its stale timeout, set with WithStaleTimeout.
+func (s *session) checkHealth(connectedAt time.Time) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, sent := range s.pings {
+		if now.Sub(sent) > PongTimeout*time.Second {
+			return fmt.Errorf("%w: ping %s sent %s ago", ErrorPongTimeout, id, now.Sub(sent))
+		}
+	}
+
+	for _, topic := range s.desired {
+		timeout, ok := s.client.staleTimeout(topic)
+		if !ok {
+			continue
+		}
+
+		last, ok := s.lastMsg[topic]
+		if !ok || last.Before(connectedAt) {
+			if now.Sub(connectedAt) > timeout {
+				return fmt.Errorf("%w: %w: %s for %s", ErrorStaleTopic, ErrorSilentTopic, topic, now.Sub(connectedAt))
+			}
+
+			continue
+		}
+
+		if now.Sub(last) > timeout {
+			return fmt.Errorf("%w: %s without messages for %s", ErrorStaleTopic, topic, now.Sub(last))
+		}
+	}
+
+	return nil
+}
+
+func (s *session) processMsg(ctx context.Context, data []byte) error {
+	c := s.client
+
+	var msg PublicResponse
+
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return err
+	}
+
+	if msg.Topic == "" {
+		return s.processOperation(data)
+	}
+
+	c.observer.MessageReceived(msg.Topic)
+
+	s.mu.Lock()
+	s.lastMsg[msg.Topic] = time.Now()
+	s.mu.Unlock()
+
+	c.logger.Debug("message", "topic", msg.Topic, "data", string(data))
+
+	return s.queues.push(ContextWithEndpoint(ctx, s.endpoint), msg.Topic, data)
+}
+
+func (s *session) processOperation(data []byte) error {
+	var op OperationResponse
+
+	err := json.Unmarshal(data, &op)
+	if err != nil {
+		return err
+	}
+
+	if op.isPong() {
+		s.pong(op.ReqID)
+
+		return nil
+	}
+
+	s.mu.Lock()
+	ack, ok := s.pending[op.ReqID]
+	s.mu.Unlock()
+
+	// duplicated acknowledgements are dropped instead of blocking the read loop.
+	if ok {
+		select {
+		case ack <- op:
+		default:
+		}
+	}
+
+	if !op.Success && (op.Op == SubscribeOp || op.Op == UnsubscribeOp) {
+		s.client.logger.Error("operation rejected", "endpoint", s.endpoint, "op", op.Op, "reqId", op.ReqID, "retMsg", op.RetMsg)
+	}
+
+	s.client.logger.Debug("operation", "endpoint", s.endpoint, "op", op.Op, "success", op.Success, "retMsg", op.RetMsg)
+
+	return nil
+}
+
+func (s *session) processRead(ctx context.Context, conn *websocket.Conn) error {
+	for {
+		if s.client.readTimeout > 0 {
+			err := conn.SetReadDeadline(time.Now().Add(s.client.readTimeout))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("reading %w", err)
+		}
+
+		if s.client.recorder != nil {
+			err := s.client.recorder.Record(time.Now(), s.endpoint, message)
+			if err != nil {
+				s.client.logger.Error("recording message", "endpoint", s.endpoint, "error", err)
+			}
+		}
+
+		err = s.processMsg(ctx, message)
+		if err != nil {
+			s.client.logger.Error("processing message", "endpoint", s.endpoint, "error", err)
+		}
+	}
+}
+
+// closeConn cleanly close the connection by sending a close message and then
+// waiting (with timeout) for the server to close the connection.
+func (s *session) closeConn(conn *websocket.Conn, readErr chan error) error {
+	s.client.logger.Info("closing connection", "endpoint", s.endpoint)
+
+	s.writeMu.Lock()
+	err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	s.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-readErr:
+	case <-time.After(CloseTimeout * time.Millisecond):
+	}
+
+	return nil
+}
+
+// chunkTopics splits topics in the args of several requests, respecting the
+// limits of bybit for a single request.
+func chunkTopics(endpoint Endpoint, topics []string) [][]interface{} {
+	maxArgs := 0
+	if endpoint.Category == Spot {
+		maxArgs = MaxSpotArgs
+	}
+
+	chunks := make([][]interface{}, 0)
+	args := make([]interface{}, 0)
+	length := 0
+	for _, topic := range topics {
+		full := maxArgs > 0 && len(args) == maxArgs
+		if len(args) > 0 && (full || length+len(topic) > MaxArgsLength) {
+			chunks = append(chunks, args)
+			args = make([]interface{}, 0)
+			length = 0
+		}
+
+		args = append(args, topic)
+		length += len(topic)
+	}
+
+	if len(args) > 0 {
+		chunks = append(chunks, args)
+	}
+
+	return chunks
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPrivateTopic reports whether topic belongs to the private channel
+func isPrivateTopic(topic string) bool {
+	for _, family := range privateTopics {
+		if topic == family || strings.HasPrefix(topic, family+".") {
+			return true
+		}
+	}
+
+	return false
+}
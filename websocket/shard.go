@@ -0,0 +1,63 @@
+package websocket
+
+// placeTopics distributes topics not subscribed yet among the shards of
+// endpoint, each topic goes to the shard with less topics and room for it.
+// Shards are created when every shard is full, those are returned to be
+// started with its topics. Must be called with the lock held.
+func (c *Client) placeTopics(state *running, endpoint Endpoint, topics []string) (map[*session][]string, []*session) {
+	shards := state.sessions[endpoint]
+	placed := make(map[*session][]string)
+	created := make([]*session, 0)
+
+	load := make(map[*session]int, len(shards))
+	for _, s := range shards {
+		load[s] = len(s.topics())
+	}
+
+	for _, topic := range topics {
+		if subscribedIn(shards, topic) {
+			continue
+		}
+
+		s := c.leastLoaded(shards, load)
+		if s == nil {
+			s = newSession(c, endpoint, state.queues)
+			shards = append(shards, s)
+			created = append(created, s)
+		}
+
+		load[s]++
+		placed[s] = append(placed[s], topic)
+	}
+
+	state.sessions[endpoint] = shards
+
+	return placed, created
+}
+
+// leastLoaded retrieves the shard with less topics and room for one more,
+// nil when every shard is full.
+func (c *Client) leastLoaded(shards []*session, load map[*session]int) *session {
+	var best *session
+	for _, s := range shards {
+		if c.shardSize > 0 && load[s] >= c.shardSize {
+			continue
+		}
+
+		if best == nil || load[s] < load[best] {
+			best = s
+		}
+	}
+
+	return best
+}
+
+func subscribedIn(shards []*session, topic string) bool {
+	for _, s := range shards {
+		if s.has(topic) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,322 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// SubscribeTickers subscribes to the tickers of symbols, read Stream.
+func (c *Client) SubscribeTickers(ctx context.Context, symbols ...string) (<-chan TickersResponse, error) {
+	return Stream[TickersResponse](ctx, c, topicsFor(TickersTopicFor, symbols)...)
+}
+
+// SubscribeOrderbook subscribes to the order books of symbols, read Stream.
+func (c *Client) SubscribeOrderbook(ctx context.Context, depth int, symbols ...string) (<-chan OrderbookResponse, error) {
+	return Stream[OrderbookResponse](ctx, c, topicsFor(func(symbol string) string {
+		return OrderbookTopicFor(depth, symbol)
+	}, symbols)...)
+}
+
+// SubscribePublicTrades subscribes to the trades of symbols, read Stream.
+func (c *Client) SubscribePublicTrades(ctx context.Context, symbols ...string) (<-chan PublicTradeResponse, error) {
+	return Stream[PublicTradeResponse](ctx, c, topicsFor(PublicTradeTopicFor, symbols)...)
+}
+
+// SubscribeKlines subscribes to the klines of symbols, read Stream.
+func (c *Client) SubscribeKlines(ctx context.Context, interval string, symbols ...string) (<-chan KlineResponse, error) {
+	return Stream[KlineResponse](ctx, c, topicsFor(func(symbol string) string {
+		return KlineTopicFor(interval, symbol)
+	}, symbols)...)
+}
+
+// SubscribeOrders subscribes to the orders of every category, read Stream.
+func (c *Client) SubscribeOrders(ctx context.Context) (<-chan OrderMessage, error) {
+	return Stream[OrderMessage](ctx, c, OrderTopic)
+}
+
+// SubscribeExecutions subscribes to the executions of every category, read Stream.
+func (c *Client) SubscribeExecutions(ctx context.Context) (<-chan ExecutionMessage, error) {
+	return Stream[ExecutionMessage](ctx, c, ExecutionTopic)
+}
+
+// SubscribePositions subscribes to the positions of every category, read Stream.
+func (c *Client) SubscribePositions(ctx context.Context) (<-chan PositionMessage, error) {
+	return Stream[PositionMessage](ctx, c, PositionTopic)
+}
+
+// SubscribeWallet subscribes to the wallet, read Stream.
+func (c *Client) SubscribeWallet(ctx context.Context) (<-chan WalletMessage, error) {
+	return Stream[WalletMessage](ctx, c, WalletTopic)
+}
+
+// Stream subscribes to topics delivering its messages, decoded as T, in the
+// channel returned. The client must be running, started with Run or
+// RunDispatcher, e.g. without subscriptions when only streams are used,
+// otherwise ErrorNotRunning is returned. The channel is closed once ctx is
+// done, or Run returns. Several streams can read the same topic, each one
+// receives every message and the topic is unsubscribed once the last of
+// them is done. Topics with a handler registered by Run are rejected with
+// ErrorDuplicateHandler.
+// Messages are delivered in the read loop unless the topics are configured
+// with WithDispatch, a slow consumer delays the rest of topics.
+func Stream[T any](ctx context.Context, c *Client, topics ...string) (<-chan T, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("%w: no topics", ErrorInvalidPattern)
+	}
+
+	for _, topic := range topics {
+		if isPattern(topic) {
+			return nil, fmt.Errorf("%w: %q", ErrorInvalidPattern, topic)
+		}
+	}
+
+	handler := &streamHandler[T]{
+		messages: make(chan T, StreamBufferSize),
+		done:     make(chan struct{}),
+	}
+
+	state, streams, err := c.streamTopics(topics)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.acquireStream(ctx, streams, handler)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.releaseStream(streams, handler)
+		case <-state.done:
+		}
+
+		handler.close()
+	}()
+
+	return handler.messages, nil
+}
+
+// streamTopics retrieves the fan out handler of each topic registered in
+// the dispatcher of the running client.
+func (c *Client) streamTopics(topics []string) (*running, map[string]*streamTopic, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	state := c.running
+	if state == nil || state.stopped {
+		return nil, nil, ErrorNotRunning
+	}
+
+	for _, topic := range topics {
+		_, ok := state.streams[topic]
+		if ok {
+			continue
+		}
+
+		if _, ok := state.dispatcher.exactHandler(topic); ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrorDuplicateHandler, topic)
+		}
+	}
+
+	streams := make(map[string]*streamTopic, len(topics))
+	for _, topic := range topics {
+		stream, ok := state.streams[topic]
+		if !ok {
+			stream = &streamTopic{}
+			state.streams[topic] = stream
+
+			err := state.dispatcher.Handle(topic, stream)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		streams[topic] = stream
+	}
+
+	return state, streams, nil
+}
+
+// acquireStream adds handler to streams, subscribing the topics without
+// other streams.
+func (c *Client) acquireStream(ctx context.Context, streams map[string]*streamTopic, handler Handler) error {
+	topics := lockStreams(streams)
+	defer unlockStreams(streams)
+
+	subscribe := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		// added before subscribing so the first messages, e.g. snapshots, aren't lost.
+		if streams[topic].add(handler) == 1 {
+			subscribe = append(subscribe, topic)
+		}
+	}
+
+	if len(subscribe) == 0 {
+		return nil
+	}
+
+	err := c.Subscribe(ctx, Subscribe(subscribe...))
+	if err != nil {
+		c.removeStream(topics, streams, handler)
+
+		return err
+	}
+
+	return nil
+}
+
+// releaseStream removes handler from streams, unsubscribing the topics
+// without other streams.
+func (c *Client) releaseStream(streams map[string]*streamTopic, handler Handler) {
+	topics := lockStreams(streams)
+	defer unlockStreams(streams)
+
+	c.removeStream(topics, streams, handler)
+}
+
+// removeStream must be called with the streams locked.
+func (c *Client) removeStream(topics []string, streams map[string]*streamTopic, handler Handler) {
+	unsubscribe := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if streams[topic].remove(handler) == 0 {
+			unsubscribe = append(unsubscribe, topic)
+		}
+	}
+
+	if len(unsubscribe) == 0 {
+		return
+	}
+
+	err := c.Unsubscribe(context.Background(), Unsubscribe(unsubscribe...))
+	if err != nil && !errors.Is(err, ErrorNotRunning) {
+		c.logger.Error("unsubscribing stream", "topics", unsubscribe, "error", err)
+	}
+}
+
+// lockStreams locks the subscription of every stream in order of topic,
+// so concurrent calls don't deadlock, returning the topics sorted.
+func lockStreams(streams map[string]*streamTopic) []string {
+	topics := make([]string, 0, len(streams))
+	for topic := range streams {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		streams[topic].op.Lock()
+	}
+
+	return topics
+}
+
+func unlockStreams(streams map[string]*streamTopic) {
+	for _, stream := range streams {
+		stream.op.Unlock()
+	}
+}
+
+// streamTopic fans out the messages of a topic to every stream reading it,
+// op serializes subscribing and unsubscribing the topic as streams come and go.
+type streamTopic struct {
+	op sync.Mutex
+
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func (t *streamTopic) ProcessMsg(ctx context.Context, obj any) error {
+	t.mu.RLock()
+	handlers := t.handlers
+	t.mu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		err := handler.ProcessMsg(ctx, obj)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// add returns the number of handlers after adding handler
+func (t *streamTopic) add(handler Handler) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.handlers = append(t.handlers, handler)
+
+	return len(t.handlers)
+}
+
+// remove returns the number of handlers left after removing handler
+func (t *streamTopic) remove(handler Handler) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	handlers := make([]Handler, 0, len(t.handlers))
+	for _, h := range t.handlers {
+		if h != handler {
+			handlers = append(handlers, h)
+		}
+	}
+	t.handlers = handlers
+
+	return len(t.handlers)
+}
+
+// streamHandler delivers the messages in a channel until closed.
+type streamHandler[T any] struct {
+	mu       sync.RWMutex
+	messages chan T
+	done     chan struct{}
+	closed   bool
+}
+
+func (h *streamHandler[T]) ProcessMsg(ctx context.Context, obj any) error {
+	msg, ok := obj.(T)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrorInvalidMessage, obj)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.closed {
+		return nil
+	}
+
+	select {
+	case h.messages <- msg:
+	case <-h.done:
+	case <-ctx.Done():
+	}
+
+	return nil
+}
+
+func (h *streamHandler[T]) close() {
+	// releases the senders waiting for the consumer.
+	close(h.done)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	close(h.messages)
+}
+
+func topicsFor(topicFor func(symbol string) string, symbols []string) []string {
+	topics := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		topics = append(topics, topicFor(symbol))
+	}
+
+	return topics
+}
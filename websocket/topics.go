@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TickersTopicFor topic of the tickers of symbol
+func TickersTopicFor(symbol string) string {
+	return topicFor(TickersTopic, symbol)
+}
+
+// OrderbookTopicFor topic of the order book of symbol with the given depth,
+// bybit supports different depths for each category e.g. 1, 50, 200 for spot.
+func OrderbookTopicFor(depth int, symbol string) string {
+	return topicFor(OrderbookTopic, fmt.Sprint(depth), symbol)
+}
+
+// PublicTradeTopicFor topic of the trades of symbol
+func PublicTradeTopicFor(symbol string) string {
+	return topicFor(PublicTradeTopic, symbol)
+}
+
+// KlineTopicFor topic of the klines of symbol, interval is one of the
+// IntervalX constants e.g. Interval1Minute.
+func KlineTopicFor(interval, symbol string) string {
+	return topicFor(KlineTopic, interval, symbol)
+}
+
+// LiquidationTopicFor topic of the liquidations of symbol
+func LiquidationTopicFor(symbol string) string {
+	return topicFor(LiquidationTopic, symbol)
+}
+
+// LTTickersTopicFor topic of the tickers of the leveraged token symbol
+func LTTickersTopicFor(symbol string) string {
+	return topicFor(LTTickersTopic, symbol)
+}
+
+// LTNavTopicFor topic of the net asset value of the leveraged token symbol
+func LTNavTopicFor(symbol string) string {
+	return topicFor(LTNavTopic, symbol)
+}
+
+// Subscribe builds the request to subscribe to topics
+func Subscribe(topics ...string) Request {
+	return Request{
+		Op:   SubscribeOp,
+		Args: topicArgs(topics),
+	}
+}
+
+// Unsubscribe builds the request to unsubscribe from topics
+func Unsubscribe(topics ...string) Request {
+	return Request{
+		Op:   UnsubscribeOp,
+		Args: topicArgs(topics),
+	}
+}
+
+func topicFor(parts ...string) string {
+	return strings.Join(parts, ".")
+}
+
+func topicArgs(topics []string) []interface{} {
+	args := make([]interface{}, 0, len(topics))
+	for _, topic := range topics {
+		args = append(args, topic)
+	}
+
+	return args
+}
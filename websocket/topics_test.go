@@ -0,0 +1,180 @@
+package websocket_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	bybitWs "github.com/Gealber/bybit/websocket"
+)
+
+func TestTopicBuilders(t *testing.T) {
+	tests := []struct {
+		topic    string
+		expected string
+	}{
+		{topic: bybitWs.TickersTopicFor("BTCUSDT"), expected: "tickers.BTCUSDT"},
+		{topic: bybitWs.OrderbookTopicFor(50, "BTCUSDT"), expected: "orderbook.50.BTCUSDT"},
+		{topic: bybitWs.PublicTradeTopicFor("BTCUSDT"), expected: "publicTrade.BTCUSDT"},
+		{topic: bybitWs.KlineTopicFor(bybitWs.Interval1Hour, "BTCUSDT"), expected: "kline.60.BTCUSDT"},
+		{topic: bybitWs.LiquidationTopicFor("BTCUSDT"), expected: "liquidation.BTCUSDT"},
+		{topic: bybitWs.LTTickersTopicFor("BTC3LUSDT"), expected: "tickers_lt.BTC3LUSDT"},
+		{topic: bybitWs.LTNavTopicFor("BTC3LUSDT"), expected: "lt.BTC3LUSDT"},
+	}
+
+	for _, tt := range tests {
+		if tt.topic != tt.expected {
+			t.Errorf("expected %s got %s", tt.expected, tt.topic)
+		}
+	}
+
+	subscribe := bybitWs.Subscribe("tickers.BTCUSDT", "kline.60.BTCUSDT")
+	expected := bybitWs.Request{Op: bybitWs.SubscribeOp, Args: []any{"tickers.BTCUSDT", "kline.60.BTCUSDT"}}
+	if !reflect.DeepEqual(subscribe, expected) {
+		t.Errorf("expected %+v got %+v", expected, subscribe)
+	}
+
+	unsubscribe := bybitWs.Unsubscribe("tickers.BTCUSDT")
+	expected = bybitWs.Request{Op: bybitWs.UnsubscribeOp, Args: []any{"tickers.BTCUSDT"}}
+	if !reflect.DeepEqual(unsubscribe, expected) {
+		t.Errorf("expected %+v got %+v", expected, unsubscribe)
+	}
+}
+
+// processFunc adapts a function to a TypedHandler.
+type processFunc[T any] func(ctx context.Context, msg T) error
+
+func (f processFunc[T]) Process(ctx context.Context, msg T) error {
+	return f(ctx, msg)
+}
+
+func TestTypedPublicStreams(t *testing.T) {
+	var (
+		book   bybitWs.OrderbookResponse
+		klines bybitWs.KlineResponse
+		lt     bybitWs.LTTickersResponse
+	)
+
+	dispatcher := bybitWs.NewDispatcher()
+	handlers := map[string]bybitWs.Handler{
+		bybitWs.OrderbookTopicFor(50, "BTCUSDT"): bybitWs.Typed[bybitWs.OrderbookResponse](processFunc[bybitWs.OrderbookResponse](
+			func(_ context.Context, msg bybitWs.OrderbookResponse) error {
+				book = msg
+
+				return nil
+			})),
+		bybitWs.KlineTopicFor(bybitWs.Interval1Minute, "BTCUSDT"): bybitWs.Typed[bybitWs.KlineResponse](processFunc[bybitWs.KlineResponse](
+			func(_ context.Context, msg bybitWs.KlineResponse) error {
+				klines = msg
+
+				return nil
+			})),
+		// leveraged token tickers aren't decoded as tickers.
+		bybitWs.LTTickersTopicFor("BTC3LUSDT"): bybitWs.Typed[bybitWs.LTTickersResponse](processFunc[bybitWs.LTTickersResponse](
+			func(_ context.Context, msg bybitWs.LTTickersResponse) error {
+				lt = msg
+
+				return nil
+			})),
+	}
+
+	for topic, handler := range handlers {
+		err := dispatcher.Handle(topic, handler)
+		if err != nil {
+			t.Fatalf("registering %s: %v", topic, err)
+		}
+	}
+
+	messages := map[string]string{
+		"orderbook.50.BTCUSDT": `{"topic":"orderbook.50.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"]],"a":[["101","2"]],"u":7,"seq":9}}`,
+		"kline.1.BTCUSDT":      `{"topic":"kline.1.BTCUSDT","type":"snapshot","ts":1,"data":[{"start":60000,"end":119999,"interval":"1","close":"100.5","confirm":true}]}`,
+		"tickers_lt.BTC3LUSDT": `{"topic":"tickers_lt.BTC3LUSDT","type":"snapshot","ts":1,"data":{"symbol":"BTC3LUSDT","lastPrice":"2.5"}}`,
+	}
+
+	for topic, data := range messages {
+		err := dispatcher.Dispatch(context.Background(), topic, []byte(data))
+		if err != nil {
+			t.Fatalf("dispatching %s: %v", topic, err)
+		}
+	}
+
+	if book.Type != bybitWs.SnapshotType || book.Data == nil || book.Data.UpdateID != 7 || book.Data.Asks[0][0] != "101" {
+		t.Errorf("unexpected order book %+v", book)
+	}
+
+	if len(klines.Data) != 1 || klines.Data[0].Close != "100.5" || !klines.Data[0].Confirm {
+		t.Errorf("unexpected klines %+v", klines)
+	}
+
+	if lt.Data == nil || lt.Data.LastPrice != "2.5" {
+		t.Errorf("unexpected leveraged token tickers %+v", lt)
+	}
+}
+
+func TestTypedRejectsOtherMessages(t *testing.T) {
+	handler := bybitWs.Typed[bybitWs.KlineResponse](processFunc[bybitWs.KlineResponse](
+		func(context.Context, bybitWs.KlineResponse) error { return nil }))
+
+	err := handler.ProcessMsg(context.Background(), bybitWs.TickersResponse{})
+	if !errors.Is(err, bybitWs.ErrorInvalidMessage) {
+		t.Fatalf("expected %v got %v", bybitWs.ErrorInvalidMessage, err)
+	}
+}
+
+func TestTypedPrivateStreams(t *testing.T) {
+	var (
+		orders     bybitWs.OrderMessage
+		executions bybitWs.FastExecutionMessage
+		wallet     bybitWs.WalletMessage
+	)
+
+	dispatcher, err := bybitWs.NewDispatcherFromMap(map[string]bybitWs.Handler{
+		bybitWs.OrderTopic: bybitWs.Typed[bybitWs.OrderMessage](processFunc[bybitWs.OrderMessage](
+			func(_ context.Context, msg bybitWs.OrderMessage) error {
+				orders = msg
+
+				return nil
+			})),
+		bybitWs.FastExecutionTopic: bybitWs.Typed[bybitWs.FastExecutionMessage](processFunc[bybitWs.FastExecutionMessage](
+			func(_ context.Context, msg bybitWs.FastExecutionMessage) error {
+				executions = msg
+
+				return nil
+			})),
+		bybitWs.WalletTopic: bybitWs.Typed[bybitWs.WalletMessage](processFunc[bybitWs.WalletMessage](
+			func(_ context.Context, msg bybitWs.WalletMessage) error {
+				wallet = msg
+
+				return nil
+			})),
+	})
+	if err != nil {
+		t.Fatalf("creating dispatcher: %v", err)
+	}
+
+	messages := map[string]string{
+		bybitWs.OrderTopic:         `{"id":"1","topic":"order","creationTime":1,"data":[{"category":"spot","orderId":"10","orderLinkId":"a","orderStatus":"New","placeType":"","smpGroup":0}]}`,
+		bybitWs.FastExecutionTopic: `{"topic":"execution.fast","creationTime":1,"data":[{"category":"linear","orderId":"11","orderLinkId":"b","execPrice":"100","execQty":"1"}]}`,
+		bybitWs.WalletTopic:        `{"id":"2","topic":"wallet","creationTime":1,"data":[{"accountType":"UNIFIED","totalEquity":"10"}]}`,
+	}
+
+	for topic, data := range messages {
+		err = dispatcher.Dispatch(context.Background(), topic, []byte(data))
+		if err != nil {
+			t.Fatalf("dispatching %s: %v", topic, err)
+		}
+	}
+
+	if !reflect.DeepEqual(orders.OrderIDs(), []string{"10"}) || !reflect.DeepEqual(orders.OrderLinkIDs(), []string{"a"}) {
+		t.Errorf("unexpected orders %+v", orders)
+	}
+
+	if !reflect.DeepEqual(executions.OrderIDs(), []string{"11"}) || executions.Data[0].ExecPrice != "100" {
+		t.Errorf("unexpected executions %+v", executions)
+	}
+
+	if len(wallet.Data) != 1 || wallet.Data[0].AccountType != "UNIFIED" {
+		t.Errorf("unexpected wallet %+v", wallet)
+	}
+}
@@ -0,0 +1,372 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Gealber/bybit/config"
+	bybitHttp "github.com/Gealber/bybit/http"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// TradeRequest request sent to the trade channel
+type TradeRequest struct {
+	ReqID  string            `json:"reqId"`
+	Header map[string]string `json:"header"`
+	Op     string            `json:"op"`
+	Args   []interface{}     `json:"args"`
+}
+
+// TradeResponse response of the trade channel, correlated with the
+// request through ReqID
+type TradeResponse struct {
+	ReqID      string            `json:"reqId"`
+	RetCode    int               `json:"retCode"`
+	RetMsg     string            `json:"retMsg"`
+	Op         string            `json:"op"`
+	Data       json.RawMessage   `json:"data"`
+	RetExtInfo json.RawMessage   `json:"retExtInfo"`
+	Header     map[string]string `json:"header"`
+	ConnID     string            `json:"connId"`
+}
+
+// TradeClient places orders through the websocket trade channel, which has
+// less latency than the REST api. It implements http.OrderSender so it can
+// be used in place of the REST client, e.g. with http.WithOrderSender.
+type TradeClient struct {
+	session *tradeSession
+	ctx     context.Context
+}
+
+// tradeSession connection shared by the copies of a TradeClient.
+type tradeSession struct {
+	client *Client
+
+	// writeMu serializes writes, the connection supports one writer at a time.
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	done    chan struct{}
+	err     error
+	pending map[string]chan TradeResponse
+}
+
+// NewTradeClient creates a client of the trade channel, opts are the same
+// used by NewClient
+func NewTradeClient(cfg *config.AppConfig, opts ...ClientOption) *TradeClient {
+	client := NewClient(cfg, opts...)
+
+	return &TradeClient{
+		session: &tradeSession{
+			client:  client,
+			pending: make(map[string]chan TradeResponse),
+		},
+	}
+}
+
+// WithContext returns a shallow copy of the client, sharing the connection,
+// whose requests are bounded to ctx. The deadline of ctx, if any, replaces
+// the default timeout of the requests.
+func (t *TradeClient) WithContext(ctx context.Context) *TradeClient {
+	return &TradeClient{
+		session: t.session,
+		ctx:     ctx,
+	}
+}
+
+// Connect establish and authenticate the connection with the trade channel,
+// the connection is closed when ctx is done or Close is called.
+func (t *TradeClient) Connect(ctx context.Context) error {
+	s := t.session
+
+	conn, err := s.client.connect(ctx, Endpoint{Channel: TradeChannel})
+	if err != nil {
+		return err
+	}
+
+	err = s.client.authenticate(conn)
+	if err != nil {
+		conn.Close()
+
+		return err
+	}
+
+	done := make(chan struct{})
+
+	s.mu.Lock()
+	s.conn = conn
+	s.done = done
+	s.err = nil
+	s.mu.Unlock()
+
+	go s.readLoop(conn, done)
+	go s.pingLoop(ctx, conn, done)
+
+	return nil
+}
+
+// Close closes the connection, pending requests fail with ErrorNotConnected
+func (t *TradeClient) Close() error {
+	s := t.session
+
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	s.writeMu.Lock()
+	err := conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	s.writeMu.Unlock()
+
+	return errors.Join(err, conn.Close())
+}
+
+// PlaceOrder place an order in the exchange
+func (t *TradeClient) PlaceOrder(order bybitHttp.OrderRequest) (*bybitHttp.OrderResponse, error) {
+	var response bybitHttp.OrderResponse
+
+	_, err := t.send(OrderCreateOp, &order, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// AmendOrder modify an open order in the exchange
+func (t *TradeClient) AmendOrder(amend bybitHttp.AmendRequest) (*bybitHttp.OrderResponse, error) {
+	var response bybitHttp.OrderResponse
+
+	_, err := t.send(OrderAmendOp, &amend, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// CancelOrder cancel an order in the exchange
+func (t *TradeClient) CancelOrder(cancel bybitHttp.CancelRequest) (*bybitHttp.OrderResponse, error) {
+	var response bybitHttp.OrderResponse
+
+	_, err := t.send(OrderCancelOp, &cancel, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// PlaceBatchOrders place several orders with a single request,
+// check the Code of each item to know if the order was placed
+func (t *TradeClient) PlaceBatchOrders(batch bybitHttp.BatchOrderRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	return t.sendBatch(OrderCreateBatchOp, &batch)
+}
+
+// AmendBatchOrders modify several open orders with a single request,
+// check the Code of each item to know if the order was modified
+func (t *TradeClient) AmendBatchOrders(batch bybitHttp.BatchAmendRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	return t.sendBatch(OrderAmendBatchOp, &batch)
+}
+
+// CancelBatchOrders cancel several orders with a single request,
+// check the Code of each item to know if the order was cancelled
+func (t *TradeClient) CancelBatchOrders(batch bybitHttp.BatchCancelRequest) ([]*bybitHttp.BatchOrderItem, error) {
+	return t.sendBatch(OrderCancelBatchOp, &batch)
+}
+
+func (t *TradeClient) sendBatch(op string, batch any) ([]*bybitHttp.BatchOrderItem, error) {
+	var result bybitHttp.BatchOrderResult
+
+	response, err := t.send(op, batch, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	var extInfo bybitHttp.BatchExtInfo
+	if len(response.RetExtInfo) > 0 {
+		err = json.Unmarshal(response.RetExtInfo, &extInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return bybitHttp.MergeBatchResult(&result, &extInfo), nil
+}
+
+// send performs the operation waiting for the response with the same
+// request id, the data of the response is stored in out.
+func (t *TradeClient) send(op string, arg any, out any) (*TradeResponse, error) {
+	s := t.session
+
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.client.tradeTimeout)
+		defer cancel()
+	}
+
+	reqID := uuid.New().String()
+	responseChn := make(chan TradeResponse, 1)
+
+	s.mu.Lock()
+	conn, done := s.conn, s.done
+	if conn == nil {
+		s.mu.Unlock()
+
+		return nil, ErrorNotConnected
+	}
+	s.pending[reqID] = responseChn
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, reqID)
+		s.mu.Unlock()
+	}()
+
+	request := TradeRequest{
+		ReqID: reqID,
+		Header: map[string]string{
+			"X-BAPI-TIMESTAMP":   strconv.FormatInt(time.Now().UnixMilli(), 10),
+			"X-BAPI-RECV-WINDOW": bybitHttp.RecvWindow,
+		},
+		Op:   op,
+		Args: []interface{}{arg},
+	}
+
+	err := s.write(conn, &request)
+	if err != nil {
+		return nil, fmt.Errorf("sending %s %w", op, err)
+	}
+
+	select {
+	case response := <-responseChn:
+		if response.RetCode != bybitHttp.RetCodeOK {
+			return nil, errors.New(response.RetMsg)
+		}
+
+		if len(response.Data) > 0 {
+			err = json.Unmarshal(response.Data, out)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &response, nil
+	case <-done:
+		return nil, s.closedErr()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %s %v", ErrorRequestTimeout, op, ctx.Err())
+	}
+}
+
+func (s *tradeSession) write(conn *websocket.Conn, v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	return conn.WriteJSON(v)
+}
+
+func (s *tradeSession) readLoop(conn *websocket.Conn, done chan struct{}) {
+	logger := s.client.logger
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			if s.conn == conn {
+				s.conn = nil
+				s.err = err
+			}
+			s.mu.Unlock()
+			close(done)
+
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				logger.Error("trade connection closed", "error", err)
+			}
+
+			return
+		}
+
+		var response TradeResponse
+		err = json.Unmarshal(message, &response)
+		if err != nil {
+			logger.Error("decoding trade response", "error", err)
+
+			continue
+		}
+
+		if response.ReqID == "" {
+			logger.Debug("trade message", "op", response.Op, "data", string(message))
+
+			continue
+		}
+
+		// the entry is removed before sending, a duplicated or late response
+		// with the same req_id is dropped instead of blocking the read loop.
+		s.mu.Lock()
+		responseChn, ok := s.pending[response.ReqID]
+		delete(s.pending, response.ReqID)
+		s.mu.Unlock()
+
+		if !ok {
+			logger.Debug("trade response without request", "reqId", response.ReqID, "op", response.Op)
+
+			continue
+		}
+
+		responseChn <- response
+	}
+}
+
+func (s *tradeSession) pingLoop(ctx context.Context, conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(PingTimeout * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			s.writeMu.Lock()
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			s.writeMu.Unlock()
+			conn.Close()
+
+			return
+		case <-ticker.C:
+			err := s.write(conn, &Request{Op: PingOp})
+			if err != nil {
+				s.client.logger.Error("sending ping", "error", err)
+			}
+		}
+	}
+}
+
+func (s *tradeSession) closedErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return fmt.Errorf("%w: %v", ErrorNotConnected, s.err)
+	}
+
+	return ErrorNotConnected
+}
+
+var _ bybitHttp.OrderSender = (*TradeClient)(nil)